@@ -1,13 +1,27 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// OIDCProviderConfig describes an OpenID Connect provider users can sign in with
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
 type Config struct {
 	// API Configuration
 	MovieAPIKey     string
@@ -18,11 +32,47 @@ type Config struct {
 	DBName   string
 
 	// Security Configuration
-	JWTSecret string
+	JWTSigningAlgorithm    string
+	JWTPrivateKeyFile      string
+	JWTKeyRotationInterval time.Duration
+	JWTKeyVerifyGrace      time.Duration
+	JWTKeyEncryptionKey    []byte // AES key for private keys stored in MongoDB, none stores them as plain PEM
+	AdminEmails            []string
+
+	// Privacy Configuration
+	AccountDeletionGrace time.Duration
+
+	// Guest Configuration
+	GuestSessionTTL  time.Duration
+	GuestCreateLimit int // Guest sessions one IP address may start per hour
+
+	// Leaderboard Configuration
+	LeaderboardRefreshInterval time.Duration
+	LeaderboardMinMatches      int
+
+	// Recommendation Configuration
+	RecommendationRefreshInterval time.Duration
+
+	// Daily Challenge Configuration
+	DailyChallengePairs int
+
+	// OpenID Connect Configuration
+	OIDCProviders         []OIDCProviderConfig
+	OIDCPostLoginRedirect string
+
+	// Email Configuration
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
 
 	// Server Configuration
-	Port string
-	Env  string
+	Port       string
+	Env        string
+	AppBaseURL string
+	// Public URL of this API, used in share links and Open Graph tags
+	PublicBaseURL string
 }
 
 // LoadConfig loads the configuration from environment variables
@@ -30,11 +80,102 @@ func LoadConfig() (*Config, error) {
 	// Load environment file based on GO_ENV
 	env := getEnvOrDefault("GO_ENV", "development")
 	envFile := filepath.Join("environments", fmt.Sprintf(".env.%s", env))
-	
+
 	if err := godotenv.Load(envFile); err != nil {
 		return nil, fmt.Errorf("error loading env file %s: %v", envFile, err)
 	}
 
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		return nil, err
+	}
+
+	signingAlgorithm := getEnvOrDefault("JWT_SIGNING_ALG", "RS256")
+	if signingAlgorithm != "RS256" && signingAlgorithm != "EdDSA" {
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %s, use RS256 or EdDSA", signingAlgorithm)
+	}
+
+	rotationInterval, err := getDurationOrDefault("JWT_KEY_ROTATION_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retired keys must keep verifying for at least the 24h lifetime of an access token
+	verifyGrace, err := getDurationOrDefault("JWT_KEY_VERIFY_GRACE", 48*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if verifyGrace < 24*time.Hour {
+		return nil, fmt.Errorf("JWT_KEY_VERIFY_GRACE must be at least 24h")
+	}
+
+	deletionGrace, err := getDurationOrDefault("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Guest tokens live as long as the guest, so they must stay verifiable that long
+	guestTTL, err := getDurationOrDefault("GUEST_SESSION_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if guestTTL > verifyGrace {
+		return nil, fmt.Errorf("GUEST_SESSION_TTL must not be longer than JWT_KEY_VERIFY_GRACE")
+	}
+
+	guestCreateLimit, err := getIntOrDefault("GUEST_CREATE_LIMIT", 5)
+	if err != nil {
+		return nil, err
+	}
+	if guestCreateLimit < 1 {
+		return nil, fmt.Errorf("GUEST_CREATE_LIMIT must be at least 1")
+	}
+
+	leaderboardRefresh, err := getDurationOrDefault("LEADERBOARD_REFRESH_INTERVAL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if leaderboardRefresh <= 0 {
+		return nil, fmt.Errorf("LEADERBOARD_REFRESH_INTERVAL must be positive")
+	}
+	leaderboardMinMatches, err := getIntOrDefault("LEADERBOARD_MIN_MATCHES", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendationRefresh, err := getDurationOrDefault("RECOMMENDATION_REFRESH_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if recommendationRefresh <= 0 {
+		return nil, fmt.Errorf("RECOMMENDATION_REFRESH_INTERVAL must be positive")
+	}
+
+	challengePairs, err := getIntOrDefault("DAILY_CHALLENGE_PAIRS", 5)
+	if err != nil {
+		return nil, err
+	}
+	if challengePairs < 1 {
+		return nil, fmt.Errorf("DAILY_CHALLENGE_PAIRS must be at least 1")
+	}
+
+	privateKeyFile := getEnvOrDefault("JWT_PRIVATE_KEY_FILE", "")
+	if privateKeyFile == "" && rotationInterval <= 0 {
+		return nil, fmt.Errorf("no JWT signing key configured, set JWT_PRIVATE_KEY_FILE or JWT_KEY_ROTATION_INTERVAL")
+	}
+	// A key file is always the signing key, so there is nothing to rotate
+	if privateKeyFile != "" && rotationInterval > 0 {
+		return nil, fmt.Errorf("set either JWT_PRIVATE_KEY_FILE or JWT_KEY_ROTATION_INTERVAL, not both")
+	}
+
+	keyEncryptionKey, err := getBase64OrDefault("JWT_KEY_ENCRYPTION_KEY", nil)
+	if err != nil {
+		return nil, err
+	}
+	if keyEncryptionKey != nil && len(keyEncryptionKey) != 32 {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must be 32 bytes, base64 encoded")
+	}
+
 	return &Config{
 		// API Configuration
 		MovieAPIKey:     getEnvOrDefault("MOVIE_API_KEY", ""),
@@ -45,17 +186,132 @@ func LoadConfig() (*Config, error) {
 		DBName:   getEnvOrDefault("DB_NAME", "movieVsdb"),
 
 		// Security Configuration
-		JWTSecret: getEnvOrDefault("JWT_SECRET", ""),
+		JWTSigningAlgorithm:    signingAlgorithm,
+		JWTPrivateKeyFile:      privateKeyFile,
+		JWTKeyRotationInterval: rotationInterval,
+		JWTKeyVerifyGrace:      verifyGrace,
+		JWTKeyEncryptionKey:    keyEncryptionKey,
+		AdminEmails:            getListOrDefault("ADMIN_EMAILS", nil),
+
+		// Privacy Configuration
+		AccountDeletionGrace: deletionGrace,
+
+		// Guest Configuration
+		GuestSessionTTL:  guestTTL,
+		GuestCreateLimit: guestCreateLimit,
+
+		// Leaderboard Configuration
+		LeaderboardRefreshInterval: leaderboardRefresh,
+		LeaderboardMinMatches:      leaderboardMinMatches,
+
+		// Recommendation Configuration
+		RecommendationRefreshInterval: recommendationRefresh,
+
+		// Daily Challenge Configuration
+		DailyChallengePairs: challengePairs,
+
+		// OpenID Connect Configuration
+		OIDCProviders:         oidcProviders,
+		OIDCPostLoginRedirect: getEnvOrDefault("OIDC_POST_LOGIN_REDIRECT_URL", ""),
+
+		// Email Configuration
+		SMTPHost:     getEnvOrDefault("SMTP_HOST", ""),
+		SMTPPort:     getEnvOrDefault("SMTP_PORT", "587"),
+		SMTPUsername: getEnvOrDefault("SMTP_USERNAME", ""),
+		SMTPPassword: getEnvOrDefault("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnvOrDefault("SMTP_FROM", "no-reply@movievs.local"),
 
 		// Server Configuration
-		Port: getEnvOrDefault("PORT", "8080"),
-		Env:  env,
+		Port:          getEnvOrDefault("PORT", "8080"),
+		Env:           env,
+		AppBaseURL:    getEnvOrDefault("APP_BASE_URL", "http://localhost:3000"),
+		PublicBaseURL: getEnvOrDefault("PUBLIC_BASE_URL", "http://localhost:8080"),
 	}, nil
 }
 
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS. Each provider is configured
+// with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and optionally _SCOPES.
+func loadOIDCProviders() ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	for _, name := range getListOrDefault("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         strings.ToLower(name),
+			Issuer:       getEnvOrDefault(prefix+"ISSUER", ""),
+			ClientID:     getEnvOrDefault(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnvOrDefault(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnvOrDefault(prefix+"REDIRECT_URL", ""),
+			Scopes:       getListOrDefault(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %s needs %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// getIntOrDefault reads an integer environment variable
+func getIntOrDefault(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number for %s: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// getDurationOrDefault reads an environment variable in time.ParseDuration format
+func getDurationOrDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration for %s: %v", key, err)
+	}
+	return duration, nil
+}
+
+// getBase64OrDefault reads a base64 encoded environment variable
+func getBase64OrDefault(key string, defaultValue []byte) ([]byte, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 for %s: %v", key, err)
+	}
+	return decoded, nil
+}
+
+// getListOrDefault reads a comma-separated environment variable into a slice
+func getListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
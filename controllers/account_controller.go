@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	accountService *services.AccountService
+}
+
+func NewAccountController(accountService *services.AccountService) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+	}
+}
+
+// Export downloads a zip archive with all of the user's data
+func (c *AccountController) Export(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	// Build the archive in memory so a failure can still be reported as an error response
+	var archive bytes.Buffer
+	if err := c.accountService.WriteExport(ctx.Request.Context(), userID, &archive); err != nil {
+		if err == services.ErrUserNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	filename := fmt.Sprintf("movie-vs-export-%s.zip", time.Now().Format("2006-01-02"))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+func (c *AccountController) DeleteAccount(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	// The body is optional for accounts without a password
+	var req models.DeleteAccountRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	scheduledAt, err := c.accountService.RequestDeletion(ctx.Request.Context(), userID, &req)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidPassword:
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case services.ErrUserNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, models.DeleteAccountResponse{
+		Message:     "Account scheduled for deletion, cancel before the scheduled time to keep it",
+		ScheduledAt: scheduledAt,
+	})
+}
+
+func (c *AccountController) CancelDeletion(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.accountService.CancelDeletion(ctx.Request.Context(), userID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
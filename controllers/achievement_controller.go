@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AchievementController struct {
+	achievementService *services.AchievementService
+}
+
+func NewAchievementController(achievementService *services.AchievementService) *AchievementController {
+	return &AchievementController{
+		achievementService: achievementService,
+	}
+}
+
+// GetAchievements lists the user's earned achievements and progress on the others
+func (c *AchievementController) GetAchievements(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.achievementService.GetAchievements(ctx.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
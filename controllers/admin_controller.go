@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminController struct {
+	adminService *services.AdminService
+}
+
+func NewAdminController(adminService *services.AdminService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+	}
+}
+
+// getPagination reads the page and page_size query parameters, falling back to sane defaults
+func getPagination(ctx *gin.Context, defaultPageSize, maxPageSize int) (int, int) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+func (c *AdminController) ListUsers(ctx *gin.Context) {
+	page, pageSize := getPagination(ctx, 50, 200)
+
+	response, err := c.adminService.ListUsers(ctx.Request.Context(), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AdminController) SetUserDisabled(ctx *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.SetUserDisabledRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.adminService.SetUserDisabled(ctx.Request.Context(), userID, *req.Disabled); err != nil {
+		if err == services.ErrUserNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+func (c *AdminController) SetUserRole(ctx *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.SetUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.adminService.SetUserRole(ctx.Request.Context(), userID, req.Role); err != nil {
+		if err == services.ErrUserNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
+}
+
+func (c *AdminController) ListCatalog(ctx *gin.Context) {
+	page, pageSize := getPagination(ctx, 50, 200)
+
+	movies, total, err := c.adminService.ListCatalog(ctx.Request.Context(), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch catalog"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"movies":    movies,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (c *AdminController) AddCatalogMovie(ctx *gin.Context) {
+	var req models.CatalogMovieRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movie, err := c.adminService.AddCatalogMovie(ctx.Request.Context(), &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, movie)
+}
+
+func (c *AdminController) DeleteCatalogMovie(ctx *gin.Context) {
+	movieID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+		return
+	}
+
+	if err := c.adminService.DeleteCatalogMovie(ctx.Request.Context(), movieID); err != nil {
+		if err == services.ErrMovieNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete movie"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Movie removed from catalog"})
+}
+
+func (c *AdminController) ImportCatalog(ctx *gin.Context) {
+	imported, err := c.adminService.ImportCatalogFromCSV(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import catalog"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"imported": imported})
+}
+
+func (c *AdminController) Reindex(ctx *gin.Context) {
+	indexes, err := c.adminService.Reindex(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"indexes": indexes})
+}
+
+func (c *AdminController) ListMigrations(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"migrations": c.adminService.Migrations()})
+}
+
+func (c *AdminController) RunMigration(ctx *gin.Context) {
+	result, err := c.adminService.RunMigration(ctx.Request.Context(), ctx.Param("name"))
+	if err != nil {
+		if err == services.ErrUnknownMigration {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChallengeController struct {
+	challengeService *services.ChallengeService
+}
+
+func NewChallengeController(challengeService *services.ChallengeService) *ChallengeController {
+	return &ChallengeController{
+		challengeService: challengeService,
+	}
+}
+
+func (c *ChallengeController) GetToday(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	challenge, err := c.challengeService.GetToday(ctx.Request.Context(), userID)
+	if err != nil {
+		writeChallengeError(ctx, err, "Failed to fetch daily challenge")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, challenge)
+}
+
+func (c *ChallengeController) Vote(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.ChallengeVoteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := c.challengeService.Vote(ctx.Request.Context(), userID, &req)
+	if err != nil {
+		writeChallengeError(ctx, err, "Failed to record vote")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, challenge)
+}
+
+func (c *ChallengeController) GetHistory(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+	page, pageSize := getPagination(ctx, 7, 60)
+
+	history, err := c.challengeService.GetHistory(ctx.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge history"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
+func writeChallengeError(ctx *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrCatalogTooSmall):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "No daily challenge available, the movie catalog is empty"})
+	case errors.Is(err, services.ErrInvalidChallengeVote):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Pair or winner is not part of today's challenge"})
+	case errors.Is(err, services.ErrAlreadyVoted):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "You already picked a side in this pair"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
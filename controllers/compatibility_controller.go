@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CompatibilityController struct {
+	compatibilityService *services.CompatibilityService
+}
+
+func NewCompatibilityController(compatibilityService *services.CompatibilityService) *CompatibilityController {
+	return &CompatibilityController{
+		compatibilityService: compatibilityService,
+	}
+}
+
+// GetCompatibility compares the current user's taste with another user's
+func (c *CompatibilityController) GetCompatibility(ctx *gin.Context) {
+	viewerID, otherID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.compatibilityService.Compare(ctx.Request.Context(), viewerID, otherID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrRankingsPrivate):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "This user's rankings are private"})
+		case errors.Is(err, services.ErrCompareWithSelf):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Cannot compare with yourself"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute compatibility"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetSimilarUsers lists the discoverable users with the most similar taste
+func (c *CompatibilityController) GetSimilarUsers(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	limit, err := intQuery(ctx, "limit", 10)
+	if err != nil || limit < 1 || limit > 50 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+		return
+	}
+	minShared, err := intQuery(ctx, "min_shared", 5)
+	if err != nil || minShared < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_shared"})
+		return
+	}
+
+	response, err := c.compatibilityService.SimilarUsers(ctx.Request.Context(), userID, minShared, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrNotEnoughRatings):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Battle more movies to find similar users"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find similar users"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
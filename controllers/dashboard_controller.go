@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardController struct {
+	dashboardService *services.DashboardService
+}
+
+func NewDashboardController(dashboardService *services.DashboardService) *DashboardController {
+	return &DashboardController{
+		dashboardService: dashboardService,
+	}
+}
+
+// GetDashboard returns the user's personal stats
+func (c *DashboardController) GetDashboard(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.dashboardService.GetDashboard(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
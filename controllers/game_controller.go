@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"movie-vs-backend/models"
 	"movie-vs-backend/services"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -39,8 +44,12 @@ func (c *GameController) GetMovieBattlePair(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.gameService.GetBattlePair(ctx.Request.Context(), userObjectID)
+	response, err := c.gameService.GetBattlePair(ctx.Request.Context(), userObjectID, strings.TrimSpace(ctx.Query("genre")))
 	if err != nil {
+		if errors.Is(err, services.ErrNotEnoughGenreMovies) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Not enough movies in this genre"})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies"})
 		return
 	}
@@ -81,10 +90,273 @@ func (c *GameController) SubmitBattleWinner(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.gameService.SubmitBattle(ctx.Request.Context(), objID, &req); err != nil {
+	achievements, err := c.gameService.SubmitBattle(ctx.Request.Context(), objID, &req)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit battle"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Battle result recorded successfully"})
+	response := gin.H{"message": "Battle result recorded successfully"}
+	if len(achievements) > 0 {
+		response["achievements"] = achievements
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// UndoBattles reverts the user's latest submissions (count, default 1)
+func (c *GameController) UndoBattles(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	count, err := intQuery(ctx, "count", 1)
+	if err != nil || count < 1 || count > 20 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 20"})
+		return
+	}
+
+	response, err := c.gameService.UndoSubmissions(ctx.Request.Context(), userID, count)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNothingToUndo):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "No battles to undo"})
+		case errors.Is(err, services.ErrUndoConflict):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Your rankings changed since these battles, they can no longer be undone"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo battles"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetRatingHistory returns the time series of a movie's rating in the user's list
+func (c *GameController) GetRatingHistory(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	title := strings.TrimSpace(ctx.Query("title"))
+	if title == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	points, err := intQuery(ctx, "points", 0)
+	if err != nil || points < 0 || points > 1000 || (points > 0 && points < 3) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "points must be between 3 and 1000"})
+		return
+	}
+
+	response, err := c.gameService.GetRatingHistory(ctx.Request.Context(), userID, title, points)
+	if err != nil {
+		if errors.Is(err, services.ErrRankingNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Movie not found in your rankings"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rating history"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetRound serves a multi-way round of 3 to 5 movies
+func (c *GameController) GetRound(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	size, err := intQuery(ctx, "size", 4)
+	if err != nil || size < models.MinRoundSize || size > models.MaxRoundSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 3 and 5"})
+		return
+	}
+
+	response, err := c.gameService.GetRound(ctx.Request.Context(), userID, size, strings.TrimSpace(ctx.Query("genre")))
+	if err != nil {
+		if errors.Is(err, services.ErrNotEnoughGenreMovies) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Not enough movies in this genre"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// SubmitRound rates a multi-way round from the user's order or favourite
+func (c *GameController) SubmitRound(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.SubmitRoundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.gameService.SubmitRound(ctx.Request.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRound) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Send either order with every movie title once, or favorite with one of the titles"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit round"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetRankings returns the user's rankings with sorting, filtering and pagination
+func (c *GameController) GetRankings(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	query := models.RankingQuery{
+		Sort:      ctx.DefaultQuery("sort", models.RankingSortELO),
+		Ascending: ctx.Query("order") == "asc",
+		Genre:     strings.TrimSpace(ctx.Query("genre")),
+	}
+
+	var err error
+	if query.Limit, err = intQuery(ctx, "limit", 20); err != nil || query.Limit < 1 || query.Limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	if query.MinMatches, err = intQuery(ctx, "min_matches", 0); err != nil || query.MinMatches < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_matches"})
+		return
+	}
+	if query.YearFrom, err = intQuery(ctx, "year_from", 0); err != nil || query.YearFrom < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year_from"})
+		return
+	}
+	if query.YearTo, err = intQuery(ctx, "year_to", 0); err != nil || query.YearTo < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year_to"})
+		return
+	}
+
+	// A cursor takes precedence over an explicit offset
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		if query.Offset, err = services.DecodeRankingCursor(cursor); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	} else if query.Offset, err = intQuery(ctx, "offset", 0); err != nil || query.Offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	response, err := c.gameService.GetRankings(ctx.Request.Context(), userID, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRankingQuery) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort or year range"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rankings"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ExportRankings streams the user's full ranked list as a download in the requested format
+func (c *GameController) ExportRankings(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	format := ctx.DefaultQuery("format", services.RankingExportCSV)
+	contentType, extension, err := services.RankingExportFile(format)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json, letterboxd or markdown"})
+		return
+	}
+
+	filename := fmt.Sprintf("movie-vs-rankings-%s.%s", time.Now().Format("2006-01-02"), extension)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Header("Content-Type", contentType)
+	ctx.Status(http.StatusOK)
+
+	// The status is already sent once rows are streamed, so a failure can only be logged
+	if err := c.gameService.ExportRankings(ctx.Request.Context(), userID, format, ctx.Writer); err != nil {
+		fmt.Printf("Error exporting rankings of user %s: %v\n", userID.Hex(), err)
+	}
+}
+
+// GetBestByGenre returns the user's best movies in each genre
+func (c *GameController) GetBestByGenre(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	minMatches, perGroup, ok := groupedRankingParams(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.gameService.GetBestByGenre(ctx.Request.Context(), userID, minMatches, perGroup)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rankings"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetBestByDecade returns the user's best movies in each decade
+func (c *GameController) GetBestByDecade(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	minMatches, perGroup, ok := groupedRankingParams(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.gameService.GetBestByDecade(ctx.Request.Context(), userID, minMatches, perGroup)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rankings"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// groupedRankingParams reads min_matches (default 1) and per (default 5, max 20)
+func groupedRankingParams(ctx *gin.Context) (int, int, bool) {
+	minMatches, err := intQuery(ctx, "min_matches", 1)
+	if err != nil || minMatches < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_matches"})
+		return 0, 0, false
+	}
+	perGroup, err := intQuery(ctx, "per", 5)
+	if err != nil || perGroup < 1 || perGroup > 20 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "per must be between 1 and 20"})
+		return 0, 0, false
+	}
+	return minMatches, perGroup, true
+}
+
+// intQuery parses an optional integer query parameter
+func intQuery(ctx *gin.Context, key string, defaultValue int) (int, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
 }
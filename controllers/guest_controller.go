@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GuestController struct {
+	guestService *services.GuestService
+}
+
+func NewGuestController(guestService *services.GuestService) *GuestController {
+	return &GuestController{
+		guestService: guestService,
+	}
+}
+
+func (c *GuestController) CreateGuest(ctx *gin.Context) {
+	response, err := c.guestService.CreateGuest(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start guest session"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// MergeGuest carries a guest session's rankings into the logged in account
+func (c *GuestController) MergeGuest(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.MergeGuestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "guest_token is required"})
+		return
+	}
+
+	if err := c.guestService.MergeInto(ctx.Request.Context(), req.GuestToken, userID); err != nil {
+		if err == services.ErrInvalidGuestToken {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge guest rankings"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Guest rankings merged into your account"})
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest export file accepted; a ratings export of several thousand films is well below it
+const maxImportFileSize = 10 << 20
+
+type ImportController struct {
+	importService *services.ImportService
+}
+
+func NewImportController(importService *services.ImportService) *ImportController {
+	return &ImportController{
+		importService: importService,
+	}
+}
+
+// Import reads an uploaded Letterboxd or IMDb export from the "file" form field
+func (c *ImportController) Import(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportFileSize)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Upload the export as the \"file\" form field (at most 10 MB)"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	report, err := c.importService.Import(ctx.Request.Context(), userID, ctx.Param("source"), file)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownImportSource):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Unknown import source, use letterboxd or imdb"})
+		case errors.Is(err, services.ErrInvalidImportFile):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import ratings"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
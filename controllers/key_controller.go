@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyController struct {
+	keyService *services.KeyService
+}
+
+func NewKeyController(keyService *services.KeyService) *KeyController {
+	return &KeyController{
+		keyService: keyService,
+	}
+}
+
+// JWKS publishes the public token verification keys for other services
+func (c *KeyController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.keyService.JWKS())
+}
+
+func (c *KeyController) Rotate(ctx *gin.Context) {
+	if err := c.keyService.Rotate(ctx.Request.Context()); err != nil {
+		if errors.Is(err, services.ErrKeyFileConfigured) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Signing key rotated"})
+}
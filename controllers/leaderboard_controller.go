@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"movie-vs-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LeaderboardController struct {
+	leaderboardService *services.LeaderboardService
+	defaultMinMatches  int
+}
+
+func NewLeaderboardController(leaderboardService *services.LeaderboardService, defaultMinMatches int) *LeaderboardController {
+	return &LeaderboardController{
+		leaderboardService: leaderboardService,
+		defaultMinMatches:  defaultMinMatches,
+	}
+}
+
+func (c *LeaderboardController) GetLeaderboard(ctx *gin.Context) {
+	page, pageSize := getPagination(ctx, 20, 100)
+
+	minMatches := c.defaultMinMatches
+	if value := ctx.Query("min_matches"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "min_matches must be a non-negative number"})
+			return
+		}
+		minMatches = parsed
+	}
+
+	response, err := c.leaderboardService.GetLeaderboard(ctx.Request.Context(), page, pageSize, minMatches)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *LeaderboardController) Refresh(ctx *gin.Context) {
+	movies, err := c.leaderboardService.Refresh(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh leaderboard"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"movies": movies})
+}
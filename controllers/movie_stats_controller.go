@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MovieStatsController struct {
+	movieStatsService *services.MovieStatsService
+}
+
+func NewMovieStatsController(movieStatsService *services.MovieStatsService) *MovieStatsController {
+	return &MovieStatsController{
+		movieStatsService: movieStatsService,
+	}
+}
+
+func (c *MovieStatsController) GetStats(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	movieID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+		return
+	}
+
+	stats, err := c.movieStatsService.GetStats(ctx.Request.Context(), userID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRankingNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Movie not found in your rankings"})
+		case errors.Is(err, services.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movie stats"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCController struct {
+	oidcService       *services.OIDCService
+	postLoginRedirect string
+}
+
+func NewOIDCController(oidcService *services.OIDCService, postLoginRedirect string) *OIDCController {
+	return &OIDCController{
+		oidcService:       oidcService,
+		postLoginRedirect: postLoginRedirect,
+	}
+}
+
+func (c *OIDCController) ListProviders(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"providers": c.oidcService.Providers()})
+}
+
+// Login redirects the browser to the provider. With ?redirect=false the URL is returned
+// as JSON instead, for clients that want to open it themselves. A ?guest_token is merged
+// into the account after the login.
+func (c *OIDCController) Login(ctx *gin.Context) {
+	authURL, err := c.oidcService.BeginLogin(ctx.Request.Context(), ctx.Param("provider"), ctx.Query("guest_token"))
+	if err != nil {
+		if err == services.ErrUnknownProvider {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	if ctx.Query("redirect") == "false" {
+		ctx.JSON(http.StatusOK, models.OIDCLoginResponse{AuthorizationURL: authURL})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Link returns the provider URL that links the provider account to the logged-in user once
+// the provider redirects back to the callback
+func (c *OIDCController) Link(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	authURL, err := c.oidcService.BeginLink(ctx.Request.Context(), ctx.Param("provider"), userID)
+	if err != nil {
+		if err == services.ErrUnknownProvider {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.OIDCLoginResponse{AuthorizationURL: authURL})
+}
+
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": providerErr, "error_description": ctx.Query("error_description")})
+		return
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	if code == "" || state == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	response, err := c.oidcService.CompleteLogin(ctx.Request.Context(), ctx.Param("provider"), state, code)
+	if err != nil {
+		switch err {
+		case services.ErrUnknownProvider:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrIdentityLinked, services.ErrUnverifiedEmail:
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Hand the token to the frontend in the fragment so it never reaches server logs. Accounts
+	// with two-factor authentication get the challenge to finish at /api/login/mfa instead.
+	if c.postLoginRedirect != "" {
+		fragment := "#token=" + url.QueryEscape(response.Token)
+		if response.MFARequired {
+			fragment = "#mfa_token=" + url.QueryEscape(response.MFAToken)
+		}
+		ctx.Redirect(http.StatusFound, c.postLoginRedirect+fragment)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
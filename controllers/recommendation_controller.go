@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecommendationController struct {
+	recommendationService *services.RecommendationService
+}
+
+func NewRecommendationController(recommendationService *services.RecommendationService) *RecommendationController {
+	return &RecommendationController{
+		recommendationService: recommendationService,
+	}
+}
+
+// GetRecommendations suggests movies the current user hasn't battled yet
+func (c *RecommendationController) GetRecommendations(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	limit, err := intQuery(ctx, "limit", 20)
+	if err != nil || limit < 1 || limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	response, err := c.recommendationService.GetRecommendations(ctx.Request.Context(), userID, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrModelNotReady):
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recommendations are not available yet, try again shortly"})
+		case errors.Is(err, services.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Evaluate runs an offline evaluation of the recommender on held-out battles
+func (c *RecommendationController) Evaluate(ctx *gin.Context) {
+	k, err := intQuery(ctx, "k", 10)
+	if err != nil || k < 1 || k > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "k must be between 1 and 100"})
+		return
+	}
+	holdout, err := strconv.ParseFloat(ctx.DefaultQuery("holdout", "0.2"), 64)
+	if err != nil || holdout <= 0 || holdout >= 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "holdout must be between 0 and 1"})
+		return
+	}
+
+	evaluation, err := c.recommendationService.Evaluate(ctx.Request.Context(), k, holdout)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate recommendations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, evaluation)
+}
+
+// Refresh rebuilds the recommendation model now
+func (c *RecommendationController) Refresh(ctx *gin.Context) {
+	if err := c.recommendationService.Refresh(ctx.Request.Context()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild recommendation model"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Recommendation model rebuilt"})
+}
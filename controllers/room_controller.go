@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	roomWriteTimeout   = 10 * time.Second
+	roomPongTimeout    = 60 * time.Second
+	roomPingInterval   = 50 * time.Second
+	roomMaxMessageSize = 4096
+)
+
+// Origins are not checked, like the CORS policy every other endpoint uses
+var roomUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+type RoomController struct {
+	roomService *services.RoomService
+}
+
+func NewRoomController(roomService *services.RoomService) *RoomController {
+	return &RoomController{
+		roomService: roomService,
+	}
+}
+
+// CreateRoom opens a new room hosted by the current user
+func (c *RoomController) CreateRoom(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.CreateRoomRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := c.roomService.CreateRoom(userID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, state)
+}
+
+func (c *RoomController) GetRoom(ctx *gin.Context) {
+	state, err := c.roomService.GetRoom(ctx.Param("code"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, state)
+}
+
+// CreateTicket issues the ticket a client passes to Connect. Browsers cannot set headers on
+// a WebSocket handshake, and the access token must not end up in URLs.
+func (c *RoomController) CreateTicket(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	ticket, err := c.roomService.IssueTicket(ctx.Param("code"), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrRoomNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room ticket"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ticket)
+}
+
+// Connect upgrades the request to a WebSocket and joins the room as the user the ?ticket=
+// was issued to
+func (c *RoomController) Connect(ctx *gin.Context) {
+	code := ctx.Param("code")
+	userID, err := c.roomService.RedeemTicket(code, ctx.Query("ticket"))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := c.roomService.Join(ctx.Request.Context(), code, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		case errors.Is(err, services.ErrRoomFull):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Room is full"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
+		}
+		return
+	}
+
+	conn, err := roomUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		c.roomService.Leave(code, member)
+		return
+	}
+
+	go writeRoomMessages(conn, member)
+
+	// Read until the client goes away, then leave the room
+	defer c.roomService.Leave(code, member)
+	conn.SetReadLimit(roomMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(roomPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(roomPongTimeout))
+	})
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.roomService.HandleMessage(code, member, message)
+	}
+}
+
+// writeRoomMessages writes the member's messages and keep-alive pings to the connection
+// until the member's message stream is closed
+func writeRoomMessages(conn *websocket.Conn, member *services.RoomMember) {
+	ticker := time.NewTicker(roomPingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-member.Send:
+			conn.SetWriteDeadline(time.Now().Add(roomWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(roomWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
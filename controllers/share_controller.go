@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareController struct {
+	shareService *services.ShareService
+}
+
+func NewShareController(shareService *services.ShareService) *ShareController {
+	return &ShareController{
+		shareService: shareService,
+	}
+}
+
+// sharePage renders a shared top list for browsers and link preview crawlers
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Share.Title}}</title>
+{{if .Share.OpenGraph}}<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Share.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+<meta name="twitter:card" content="summary_large_image">
+{{else}}<meta name="robots" content="noindex">
+{{end}}</head>
+<body>
+<h1>{{.Share.Title}}</h1>
+{{if .Share.DisplayName}}<p>by {{.Share.DisplayName}}</p>{{end}}
+<ol>
+{{range .Share.Movies}}<li>{{.MovieTitle}}{{if .Year}} ({{.Year}}){{end}} &middot; {{.ELORating}}</li>
+{{end}}</ol>
+</body>
+</html>
+`))
+
+func (c *ShareController) CreateShare(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.CreateShareRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := c.shareService.CreateShare(ctx.Request.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNothingToShare):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Battle some movies before sharing your top list"})
+		case errors.Is(err, services.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, link)
+}
+
+func (c *ShareController) ListShares(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	links, err := c.shareService.ListShares(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shares"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"shares": links})
+}
+
+func (c *ShareController) DeleteShare(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.shareService.DeleteShare(ctx.Request.Context(), userID, ctx.Param("token")); err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete share"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Share deleted"})
+}
+
+// GetShare serves a share as JSON, or as an HTML page with Open Graph tags to browsers
+// and link preview crawlers
+func (c *ShareController) GetShare(ctx *gin.Context) {
+	token := ctx.Param("token")
+	share, err := c.shareService.GetShare(ctx.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share"})
+		return
+	}
+
+	// Snapshots never change, but caches must keep the JSON and HTML versions apart
+	ctx.Header("Cache-Control", "public, max-age=86400")
+	ctx.Header("Vary", "Accept")
+
+	if ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		ctx.JSON(http.StatusOK, share)
+		return
+	}
+
+	var titles []string
+	for _, movie := range share.Movies {
+		if len(titles) == 3 {
+			break
+		}
+		titles = append(titles, fmt.Sprintf("%d. %s", movie.Rank, movie.MovieTitle))
+	}
+
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	err = sharePage.Execute(ctx.Writer, gin.H{
+		"Share":       share,
+		"Description": strings.Join(titles, " · "),
+		"URL":         c.shareService.ShareURL(share.Token),
+		"ImageURL":    c.shareService.CardURL(share.Token),
+	})
+	if err != nil {
+		fmt.Printf("Error rendering share page %s: %v\n", token, err)
+	}
+}
+
+// GetCard serves the PNG image card of a share
+func (c *ShareController) GetCard(ctx *gin.Context) {
+	card, err := c.shareService.GetCard(ctx.Request.Context(), ctx.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render share card"})
+		return
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Data(http.StatusOK, "image/png", card)
+}
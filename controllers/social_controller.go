@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SocialController struct {
+	socialService *services.SocialService
+}
+
+func NewSocialController(socialService *services.SocialService) *SocialController {
+	return &SocialController{
+		socialService: socialService,
+	}
+}
+
+// getUserIDs returns the current user's ID and the user ID in the :id path parameter
+func getUserIDs(ctx *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	otherID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return userID, otherID, true
+}
+
+// writeSocialError maps social service errors to responses
+func writeSocialError(ctx *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrFollowNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Follow not found"})
+	case errors.Is(err, services.ErrFollowSelf):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+	case errors.Is(err, services.ErrRankingsPrivate):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "This user's rankings are private"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func (c *SocialController) Follow(ctx *gin.Context) {
+	userID, followeeID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := c.socialService.Follow(ctx.Request.Context(), userID, followeeID)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to follow user")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *SocialController) Unfollow(ctx *gin.Context) {
+	userID, followeeID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.socialService.Unfollow(ctx.Request.Context(), userID, followeeID); err != nil {
+		writeSocialError(ctx, err, "Failed to unfollow user")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Unfollowed"})
+}
+
+func (c *SocialController) AcceptRequest(ctx *gin.Context) {
+	userID, followerID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.socialService.AcceptRequest(ctx.Request.Context(), userID, followerID); err != nil {
+		writeSocialError(ctx, err, "Failed to accept follow request")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Follow request accepted"})
+}
+
+func (c *SocialController) DeclineRequest(ctx *gin.Context) {
+	userID, followerID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.socialService.DeclineRequest(ctx.Request.Context(), userID, followerID); err != nil {
+		writeSocialError(ctx, err, "Failed to decline follow request")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Follow request declined"})
+}
+
+func (c *SocialController) RemoveFollower(ctx *gin.Context) {
+	userID, followerID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.socialService.RemoveFollower(ctx.Request.Context(), userID, followerID); err != nil {
+		writeSocialError(ctx, err, "Failed to remove follower")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Follower removed"})
+}
+
+func (c *SocialController) ListFollowers(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+	page, pageSize := getPagination(ctx, 20, 100)
+
+	response, err := c.socialService.ListFollowers(ctx.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to fetch followers")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *SocialController) ListFollowing(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+	page, pageSize := getPagination(ctx, 20, 100)
+
+	status := ctx.DefaultQuery("status", models.FollowAccepted)
+	if status != models.FollowAccepted && status != models.FollowPending {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "status must be accepted or pending"})
+		return
+	}
+
+	response, err := c.socialService.ListFollowing(ctx.Request.Context(), userID, status, page, pageSize)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to fetch followed users")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *SocialController) ListRequests(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+	page, pageSize := getPagination(ctx, 20, 100)
+
+	response, err := c.socialService.ListRequests(ctx.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to fetch follow requests")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetTopList returns another user's best movies, subject to their privacy settings
+func (c *SocialController) GetTopList(ctx *gin.Context) {
+	viewerID, ownerID, ok := getUserIDs(ctx)
+	if !ok {
+		return
+	}
+
+	limit, err := intQuery(ctx, "limit", 20)
+	if err != nil || limit < 1 || limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	response, err := c.socialService.GetTopList(ctx.Request.Context(), viewerID, ownerID, limit)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to fetch top movies")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetFriendsConsensus returns the combined ranking of the users the current user follows
+func (c *SocialController) GetFriendsConsensus(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	limit, err := intQuery(ctx, "limit", 20)
+	if err != nil || limit < 1 || limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	minFriends, err := intQuery(ctx, "min_friends", 2)
+	if err != nil || minFriends < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "min_friends must be at least 1"})
+		return
+	}
+
+	response, err := c.socialService.FriendsConsensus(ctx.Request.Context(), userID, minFriends, limit)
+	if err != nil {
+		writeSocialError(ctx, err, "Failed to fetch friends consensus")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"movie-vs-backend/models"
+	"movie-vs-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TournamentController struct {
+	tournamentService *services.TournamentService
+}
+
+func NewTournamentController(tournamentService *services.TournamentService) *TournamentController {
+	return &TournamentController{
+		tournamentService: tournamentService,
+	}
+}
+
+func (c *TournamentController) CreateTournament(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.CreateTournamentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tournament, err := c.tournamentService.CreateTournament(ctx.Request.Context(), userID, &req)
+	if err != nil {
+		writeTournamentError(ctx, err, "Failed to create tournament")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, tournament)
+}
+
+func (c *TournamentController) ListTournaments(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return
+	}
+	page, pageSize := getPagination(ctx, 20, 100)
+
+	response, err := c.tournamentService.ListTournaments(ctx.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tournaments"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *TournamentController) GetTournament(ctx *gin.Context) {
+	userID, tournamentID, ok := getTournamentIDs(ctx)
+	if !ok {
+		return
+	}
+
+	tournament, err := c.tournamentService.GetTournament(ctx.Request.Context(), userID, tournamentID)
+	if err != nil {
+		writeTournamentError(ctx, err, "Failed to fetch tournament")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tournament)
+}
+
+func (c *TournamentController) DeleteTournament(ctx *gin.Context) {
+	userID, tournamentID, ok := getTournamentIDs(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.tournamentService.DeleteTournament(ctx.Request.Context(), userID, tournamentID); err != nil {
+		writeTournamentError(ctx, err, "Failed to delete tournament")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Tournament deleted"})
+}
+
+// GetBattle returns the tournament's next match
+func (c *TournamentController) GetBattle(ctx *gin.Context) {
+	userID, tournamentID, ok := getTournamentIDs(ctx)
+	if !ok {
+		return
+	}
+
+	battle, err := c.tournamentService.NextBattle(ctx.Request.Context(), userID, tournamentID)
+	if err != nil {
+		writeTournamentError(ctx, err, "Failed to get tournament battle")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, battle)
+}
+
+// SubmitBattle records the winner of a tournament match
+func (c *TournamentController) SubmitBattle(ctx *gin.Context) {
+	userID, tournamentID, ok := getTournamentIDs(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.SubmitTournamentBattleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tournament, err := c.tournamentService.SubmitBattle(ctx.Request.Context(), userID, tournamentID, &req)
+	if err != nil {
+		writeTournamentError(ctx, err, "Failed to submit tournament battle")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tournament)
+}
+
+// getTournamentIDs returns the authenticated user's ID and the :id tournament ID. When
+// either is missing or malformed the error response is already written and ok is false.
+func getTournamentIDs(ctx *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	tournamentID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tournament ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return userID, tournamentID, true
+}
+
+func writeTournamentError(ctx *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrTournamentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+	case errors.Is(err, services.ErrInvalidTournament):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Genre tournaments need a genre and decade tournaments a decade such as 1990"})
+	case errors.Is(err, services.ErrNotEnoughTournamentMovies):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Not enough ranked movies for a tournament of that size"})
+	case errors.Is(err, services.ErrTournamentFinished):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Tournament is finished"})
+	case errors.Is(err, services.ErrInvalidTournamentResult):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Match is not ready to play or the winner is not in it"})
+	case errors.Is(err, services.ErrTournamentConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Tournament was updated by another request, reload it and try again"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
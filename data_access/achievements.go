@@ -0,0 +1,66 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AchievementRepository stores the achievements users have unlocked
+type AchievementRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAchievementRepository(db *MongoDB) *AchievementRepository {
+	return &AchievementRepository{collection: db.Collection("achievements")}
+}
+
+// Unlock records an achievement for the user. It reports false when the user had already
+// unlocked it.
+func (r *AchievementRepository) Unlock(ctx context.Context, achievement *models.UnlockedAchievement) (bool, error) {
+	result, err := r.collection.InsertOne(ctx, achievement)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error unlocking achievement: %v", err)
+	}
+	achievement.ID = result.InsertedID.(primitive.ObjectID)
+	return true, nil
+}
+
+// FindForUser returns the achievements the user has unlocked, oldest first
+func (r *AchievementRepository) FindForUser(ctx context.Context, userID primitive.ObjectID) ([]models.UnlockedAchievement, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"unlocked_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("error finding achievements: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	achievements := []models.UnlockedAchievement{}
+	if err = cursor.All(ctx, &achievements); err != nil {
+		return nil, fmt.Errorf("error decoding achievements: %v", err)
+	}
+	return achievements, nil
+}
+
+// DeleteAllForUser removes the user's achievements
+func (r *AchievementRepository) DeleteAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EnsureIndexes creates the indexes the achievements collection relies on
+func (r *AchievementRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "achievement_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+}
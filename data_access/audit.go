@@ -0,0 +1,29 @@
+package data_access
+
+import (
+	"context"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *MongoDB) *AuditRepository {
+	return &AuditRepository{collection: db.Collection("audit_log")}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, record *models.AuditRecord) error {
+	_, err := r.collection.InsertOne(ctx, record)
+	return err
+}
+
+// EnsureIndexes creates the indexes the audit_log collection relies on
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
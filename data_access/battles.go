@@ -0,0 +1,1069 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BattleRepository struct {
+	db *MongoDB
+}
+
+func NewBattleRepository(db *MongoDB) *BattleRepository {
+	return &BattleRepository{db: db}
+}
+
+// SaveBattle stores a submitted battle
+func (r *BattleRepository) SaveBattle(ctx context.Context, battle *models.Battle) error {
+	result, err := r.db.Collection("battles").InsertOne(ctx, battle)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		battle.ID = id
+	}
+	return nil
+}
+
+// SaveBattles stores the battles recorded from one submission
+func (r *BattleRepository) SaveBattles(ctx context.Context, battles []*models.Battle) error {
+	if len(battles) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(battles))
+	for _, battle := range battles {
+		documents = append(documents, battle)
+	}
+
+	result, err := r.db.Collection("battles").InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+	for i, id := range result.InsertedIDs {
+		if objectID, ok := id.(primitive.ObjectID); ok {
+			battles[i].ID = objectID
+		}
+	}
+	return nil
+}
+
+// FindBattlesByUser returns every battle the user submitted, oldest first
+func (r *BattleRepository) FindBattlesByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Battle, error) {
+	cursor, err := r.db.Collection("battles").Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error finding battles: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	battles := []models.Battle{}
+	if err = cursor.All(ctx, &battles); err != nil {
+		return nil, fmt.Errorf("error decoding battles: %v", err)
+	}
+	return battles, nil
+}
+
+// FindBattleDays returns the number of battles the user played on each UTC day, leaving
+// out undone battles, oldest day first
+func (r *BattleRepository) FindBattleDays(ctx context.Context, userID primitive.ObjectID) ([]models.BattleDay, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "undone": bson.M{"$ne": true}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+			"battles": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := r.db.Collection("battles").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	days := []models.BattleDay{}
+	if err = cursor.All(ctx, &days); err != nil {
+		return nil, fmt.Errorf("error decoding battle days: %v", err)
+	}
+	return days, nil
+}
+
+// FindGenreCoverage returns, for each genre in the user's list, how many of its movies they
+// have battled. With genres given only those genres are counted.
+func (r *BattleRepository) FindGenreCoverage(ctx context.Context, userID primitive.ObjectID, genres []string) ([]models.GenreCoverage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$unwind", Value: "$movie_rankings.genres"}},
+	}
+	if genres != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"movie_rankings.genres": bson.M{"$in": genres}}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$group", Value: bson.M{
+		"_id":    "$movie_rankings.genres",
+		"movies": bson.M{"$sum": 1},
+		"battled": bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$movie_rankings.match_count", 0}}, 1, 0,
+		}}},
+	}}})
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	coverage := []models.GenreCoverage{}
+	if err = cursor.All(ctx, &coverage); err != nil {
+		return nil, fmt.Errorf("error decoding genre coverage: %v", err)
+	}
+	return coverage, nil
+}
+
+// FindRatingMovement returns, for the user's latest weeks with battles, the average ELO
+// change of a movie in a battle, newest week first. Only battles with rating snapshots
+// that haven't been undone are counted.
+func (r *BattleRepository) FindRatingMovement(ctx context.Context, userID primitive.ObjectID, weeks int) ([]models.RatingMovement, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":  userID,
+			"undone":   bson.M{"$ne": true},
+			"before.0": bson.M{"$exists": true},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"week": bson.M{"$dateToString": bson.M{"format": "%G-W%V", "date": "$created_at"}},
+			"change": bson.M{"$avg": bson.M{"$map": bson.M{
+				"input": bson.M{"$range": bson.A{0, bson.M{"$size": "$after"}}},
+				"as":    "i",
+				"in": bson.M{"$abs": bson.M{"$subtract": bson.A{
+					bson.M{"$arrayElemAt": bson.A{"$after.elo_rating", "$$i"}},
+					bson.M{"$arrayElemAt": bson.A{"$before.elo_rating", "$$i"}},
+				}}},
+			}}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$week",
+			"battles":        bson.M{"$sum": 1},
+			"average_change": bson.M{"$avg": "$change"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": -1}}},
+		{{Key: "$limit", Value: weeks}},
+	}
+
+	cursor, err := r.db.Collection("battles").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	movement := []models.RatingMovement{}
+	if err = cursor.All(ctx, &movement); err != nil {
+		return nil, fmt.Errorf("error decoding rating movement: %v", err)
+	}
+	return movement, nil
+}
+
+// FindUndoableBattles returns the battles of the user's latest submissions that haven't
+// been undone, newest first, covering at most the given number of submissions. Battles
+// stored before submissions were snapshotted and tournament or room battles end the search,
+// as they can't be undone.
+func (r *BattleRepository) FindUndoableBattles(ctx context.Context, userID primitive.ObjectID, submissions int) ([]models.Battle, error) {
+	cursor, err := r.db.Collection("battles").Find(ctx,
+		bson.M{"user_id": userID, "undone": bson.M{"$ne": true}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error finding battles: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	battles := []models.Battle{}
+	seen := make(map[primitive.ObjectID]bool)
+	for cursor.Next(ctx) {
+		var battle models.Battle
+		if err := cursor.Decode(&battle); err != nil {
+			return nil, fmt.Errorf("error decoding battle: %v", err)
+		}
+		if battle.SubmissionID.IsZero() || len(battle.Before) == 0 || battle.Source != "" {
+			break
+		}
+		if !seen[battle.SubmissionID] {
+			if len(seen) == submissions {
+				break
+			}
+			seen[battle.SubmissionID] = true
+		}
+		battles = append(battles, battle)
+	}
+
+	return battles, cursor.Err()
+}
+
+// FindOpponents returns the movie's record against every movie it has faced in the user's
+// battles that haven't been undone, most played first
+func (r *BattleRepository) FindOpponents(ctx context.Context, userID primitive.ObjectID, title string) ([]models.OpponentRecord, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id": userID,
+			"undone":  bson.M{"$ne": true},
+			"$or":     bson.A{bson.M{"movie_a.title": title}, bson.M{"movie_b.title": title}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"created_at": 1}}},
+		{{Key: "$project", Value: bson.M{
+			"opponent": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$movie_a.title", title}}, "$movie_b.title", "$movie_a.title",
+			}},
+			"won":        bson.M{"$eq": bson.A{"$winner.title", title}},
+			"winner":     "$winner.title",
+			"created_at": 1,
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         "$opponent",
+			"wins":        bson.M{"$sum": bson.M{"$cond": bson.A{"$won", 1, 0}}},
+			"losses":      bson.M{"$sum": bson.M{"$cond": bson.A{"$won", 0, 1}}},
+			"matches":     bson.M{"$sum": 1},
+			"last_played": bson.M{"$last": "$created_at"},
+			"last_winner": bson.M{"$last": "$winner"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "matches", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := r.db.Collection("battles").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	opponents := []models.OpponentRecord{}
+	if err = cursor.All(ctx, &opponents); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+	return opponents, nil
+}
+
+// FindRatingHistory returns the rating snapshots of the user's battles involving the movie
+// that haven't been undone, oldest first. Battles from before snapshots were taken have none.
+func (r *BattleRepository) FindRatingHistory(ctx context.Context, userID primitive.ObjectID, title string) ([]models.Battle, error) {
+	cursor, err := r.db.Collection("battles").Find(ctx,
+		bson.M{"user_id": userID, "undone": bson.M{"$ne": true}, "after.movie_title": title},
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+			SetProjection(bson.M{"created_at": 1, "submission_id": 1, "before": 1, "after": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error finding rating history: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	battles := []models.Battle{}
+	if err = cursor.All(ctx, &battles); err != nil {
+		return nil, fmt.Errorf("error decoding rating history: %v", err)
+	}
+	return battles, nil
+}
+
+// MarkBattlesUndone flags every battle of the user's given submissions as undone
+func (r *BattleRepository) MarkBattlesUndone(ctx context.Context, userID primitive.ObjectID, submissionIDs []primitive.ObjectID, at time.Time) (int64, error) {
+	result, err := r.db.Collection("battles").UpdateMany(ctx,
+		bson.M{"user_id": userID, "submission_id": bson.M{"$in": submissionIDs}},
+		bson.M{"$set": bson.M{"undone": true, "undone_at": at}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// UnmarkBattlesUndone reverts MarkBattlesUndone for the submissions undone at the given time
+func (r *BattleRepository) UnmarkBattlesUndone(ctx context.Context, userID primitive.ObjectID, submissionIDs []primitive.ObjectID, at time.Time) error {
+	_, err := r.db.Collection("battles").UpdateMany(ctx,
+		bson.M{"user_id": userID, "submission_id": bson.M{"$in": submissionIDs}, "undone_at": at},
+		bson.M{"$unset": bson.M{"undone": "", "undone_at": ""}},
+	)
+	return err
+}
+
+// AnonymizeUserBattles detaches the user's battles from their account. The results stay
+// available for community statistics but can no longer be tied to the person.
+func (r *BattleRepository) AnonymizeUserBattles(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.db.Collection("battles").UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$unset": bson.M{"user_id": ""}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ReassignBattles moves every battle of one user to another, used when a guest signs up
+func (r *BattleRepository) ReassignBattles(ctx context.Context, fromUserID, toUserID primitive.ObjectID) (int64, error) {
+	result, err := r.db.Collection("battles").UpdateMany(ctx,
+		bson.M{"user_id": fromUserID},
+		bson.M{"$set": bson.M{"user_id": toUserID}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// FindHeadToHeads returns the user's latest winner for every pair of movies they have battled
+func (r *BattleRepository) FindHeadToHeads(ctx context.Context, userID primitive.ObjectID) ([]models.HeadToHead, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "undone": bson.M{"$ne": true}}}},
+		{{Key: "$sort", Value: bson.M{"created_at": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"low":  bson.M{"$min": bson.A{"$movie_a.title", "$movie_b.title"}},
+				"high": bson.M{"$max": bson.A{"$movie_a.title", "$movie_b.title"}},
+			},
+			"winner": bson.M{"$last": "$winner.title"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":    0,
+			"low":    "$_id.low",
+			"high":   "$_id.high",
+			"winner": 1,
+		}}},
+	}
+
+	cursor, err := r.db.Collection("battles").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	headToHeads := []models.HeadToHead{}
+	if err = cursor.All(ctx, &headToHeads); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+	return headToHeads, nil
+}
+
+// ForEachBattleOutcome streams every battle of every user that hasn't been undone, oldest first
+func (r *BattleRepository) ForEachBattleOutcome(ctx context.Context, fn func(outcome models.BattleOutcome)) error {
+	cursor, err := r.db.Collection("battles").Find(ctx,
+		bson.M{"undone": bson.M{"$ne": true}},
+		options.Find().
+			SetSort(bson.M{"created_at": 1}).
+			SetProjection(bson.M{"movie_a.title": 1, "movie_b.title": 1, "winner.title": 1}),
+	)
+	if err != nil {
+		return fmt.Errorf("error reading battles: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var battle models.Battle
+		if err := cursor.Decode(&battle); err != nil {
+			return fmt.Errorf("error decoding battle: %v", err)
+		}
+
+		loser := battle.MovieA.Title
+		if battle.Winner.Title == battle.MovieA.Title {
+			loser = battle.MovieB.Title
+		}
+		fn(models.BattleOutcome{WinnerTitle: battle.Winner.Title, LoserTitle: loser})
+	}
+
+	return cursor.Err()
+}
+
+// AggregatePersonalStats averages every user's personal ELO per movie, counting only
+// movies a user has battled at least once
+func (r *BattleRepository) AggregatePersonalStats(ctx context.Context) ([]models.MoviePersonalStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$match", Value: bson.M{"movie_rankings.match_count": bson.M{"$gt": 0}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$movie_rankings.movie_title",
+			"average_rating": bson.M{"$avg": "$movie_rankings.elo_rating"},
+			"ranked_by":      bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.MoviePersonalStats
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+
+	return stats, nil
+}
+
+// EnsureIndexes creates the indexes the battles collection relies on
+func (r *BattleRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.db.Collection("battles").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "after.movie_title", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+}
+
+// SaveMovieRanking saves or updates a movie ranking for a user
+func (r *BattleRepository) SaveMovieRanking(ctx context.Context, userID primitive.ObjectID, ranking *models.MovieRanking) error {
+	update := bson.M{
+		"movie_rankings.$.elo_rating":   ranking.ELORating,
+		"movie_rankings.$.match_count":  ranking.MatchCount,
+		"movie_rankings.$.win_count":    ranking.WinCount,
+		"movie_rankings.$.loss_count":   ranking.LossCount,
+		"movie_rankings.$.last_updated": ranking.LastUpdated,
+	}
+	// Only overwrite genre and year when they are known
+	if len(ranking.Genres) > 0 {
+		update["movie_rankings.$.genres"] = ranking.Genres
+	}
+	if ranking.Year > 0 {
+		update["movie_rankings.$.year"] = ranking.Year
+	}
+
+	// First try to find and update an existing ranking
+	result, err := r.db.Collection("users").UpdateOne(
+		ctx,
+		bson.M{
+			"_id":                     userID,
+			"movie_rankings.movie_id": ranking.MovieID,
+		},
+		bson.M{"$set": update},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	// If no existing ranking was found, add it as a new one
+	if result.MatchedCount == 0 {
+		_, err = r.db.Collection("users").UpdateOne(
+			ctx,
+			bson.M{"_id": userID},
+			bson.M{"$push": bson.M{"movie_rankings": ranking}},
+		)
+	}
+
+	return err
+}
+
+// MergeMovieRanking adds a guest's ranking of a movie to the user's ranking with the same
+// title in a single update, so battles submitted meanwhile are kept. The counts are summed
+// and the ELO is averaged by how many matches each side played; a movie the user hasn't
+// played takes the guest's ELO, and a movie the user has no ranking of is added as is. It
+// reports whether the movie had not been battled by the user before.
+func (r *BattleRepository) MergeMovieRanking(ctx context.Context, userID primitive.ObjectID, ranking models.MovieRanking) (bool, error) {
+	users := r.db.Collection("users")
+	merged := bson.M{"$map": bson.M{
+		"input": "$movie_rankings",
+		"as":    "r",
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$ne": bson.A{"$$r.movie_title", ranking.MovieTitle}},
+			"$$r",
+			bson.M{"$mergeObjects": bson.A{"$$r", bson.M{
+				"elo_rating": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$$r.match_count", 0}},
+					ranking.ELORating,
+					bson.M{"$toInt": bson.M{"$trunc": bson.M{"$divide": bson.A{
+						bson.M{"$add": bson.A{
+							bson.M{"$multiply": bson.A{"$$r.elo_rating", "$$r.match_count"}},
+							ranking.ELORating * ranking.MatchCount,
+						}},
+						bson.M{"$add": bson.A{"$$r.match_count", ranking.MatchCount}},
+					}}}},
+				}},
+				"match_count":  bson.M{"$add": bson.A{"$$r.match_count", ranking.MatchCount}},
+				"win_count":    bson.M{"$add": bson.A{"$$r.win_count", ranking.WinCount}},
+				"loss_count":   bson.M{"$add": bson.A{"$$r.loss_count", ranking.LossCount}},
+				"last_updated": bson.M{"$max": bson.A{"$$r.last_updated", ranking.LastUpdated}},
+			}}},
+		}},
+	}}
+
+	// A ranking may be added between the two updates, so try once more in that case
+	for attempt := 0; attempt < 2; attempt++ {
+		var before struct {
+			MovieRankings []models.MovieRanking `bson:"movie_rankings"`
+		}
+		err := users.FindOneAndUpdate(ctx,
+			bson.M{"_id": userID, "movie_rankings.movie_title": ranking.MovieTitle},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{"movie_rankings": merged}}}},
+			options.FindOneAndUpdate().
+				SetReturnDocument(options.Before).
+				SetProjection(bson.M{"movie_rankings": bson.M{"$elemMatch": bson.M{"movie_title": ranking.MovieTitle}}}),
+		).Decode(&before)
+		if err == nil {
+			return len(before.MovieRankings) > 0 && before.MovieRankings[0].MatchCount == 0, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return false, fmt.Errorf("error merging ranking of %s: %v", ranking.MovieTitle, err)
+		}
+
+		result, err := users.UpdateOne(ctx,
+			bson.M{"_id": userID, "movie_rankings.movie_title": bson.M{"$ne": ranking.MovieTitle}},
+			bson.M{"$push": bson.M{"movie_rankings": ranking}},
+		)
+		if err != nil {
+			return false, fmt.Errorf("error adding ranking of %s: %v", ranking.MovieTitle, err)
+		}
+		if result.MatchedCount > 0 {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("error merging ranking of %s: user not found", ranking.MovieTitle)
+}
+
+// RemoveMovieRanking deletes one of the user's movie rankings
+func (r *BattleRepository) RemoveMovieRanking(ctx context.Context, userID primitive.ObjectID, movieID primitive.ObjectID) error {
+	_, err := r.db.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"movie_rankings": bson.M{"movie_id": movieID}}},
+	)
+	return err
+}
+
+// RestoreRankings sets the rating, counts and timestamp of the given rankings and removes
+// the removed ones in a single update. Nothing is written unless every ranking in expected
+// still holds exactly those values, and false is returned in that case.
+func (r *BattleRepository) RestoreRankings(ctx context.Context, userID primitive.ObjectID, expected, restored []models.RankingSnapshot, removed []primitive.ObjectID) (bool, error) {
+	unchanged := make(bson.A, 0, len(expected))
+	for _, snapshot := range expected {
+		unchanged = append(unchanged, bson.M{"$elemMatch": bson.M{
+			"movie_id":     snapshot.MovieID,
+			"elo_rating":   snapshot.ELORating,
+			"match_count":  snapshot.MatchCount,
+			"win_count":    snapshot.WinCount,
+			"loss_count":   snapshot.LossCount,
+			"last_updated": snapshot.LastUpdated,
+		}})
+	}
+	filter := bson.M{"_id": userID}
+	if len(unchanged) > 0 {
+		filter["movie_rankings"] = bson.M{"$all": unchanged}
+	}
+
+	var rankings interface{} = "$movie_rankings"
+	if len(restored) > 0 {
+		branches := make(bson.A, 0, len(restored))
+		for _, snapshot := range restored {
+			branches = append(branches, bson.M{
+				"case": bson.M{"$eq": bson.A{"$$r.movie_id", snapshot.MovieID}},
+				"then": bson.M{"$mergeObjects": bson.A{"$$r", bson.M{
+					"elo_rating":   snapshot.ELORating,
+					"match_count":  snapshot.MatchCount,
+					"win_count":    snapshot.WinCount,
+					"loss_count":   snapshot.LossCount,
+					"last_updated": snapshot.LastUpdated,
+				}}},
+			})
+		}
+		rankings = bson.M{"$map": bson.M{
+			"input": rankings,
+			"as":    "r",
+			"in":    bson.M{"$switch": bson.M{"branches": branches, "default": "$$r"}},
+		}}
+	}
+	if len(removed) > 0 {
+		rankings = bson.M{"$filter": bson.M{
+			"input": rankings,
+			"as":    "r",
+			"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$r.movie_id", removed}}}},
+		}}
+	}
+
+	result, err := r.db.Collection("users").UpdateOne(ctx, filter, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"movie_rankings": rankings}}},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error restoring rankings: %v", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ImportRankings marks the user's existing rankings as seen and adds the new ones. The ELO
+// of an existing ranking is only overwritten while it has not been battled, so battles
+// played during the import are kept.
+func (r *BattleRepository) ImportRankings(ctx context.Context, userID primitive.ObjectID, existing, added []models.MovieRanking) error {
+	writes := make([]mongo.WriteModel, 0, 2*len(existing)+1)
+	for _, ranking := range existing {
+		writes = append(writes,
+			mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": userID}).
+				SetUpdate(bson.M{"$set": bson.M{"movie_rankings.$[r].seen": true}}).
+				SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+					bson.M{"r.movie_id": ranking.MovieID},
+				}}),
+			mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": userID}).
+				SetUpdate(bson.M{"$set": bson.M{"movie_rankings.$[r].elo_rating": ranking.ELORating}}).
+				SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+					bson.M{"r.movie_id": ranking.MovieID, "r.match_count": 0},
+				}}),
+		)
+	}
+	if len(added) > 0 {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": userID}).
+			SetUpdate(bson.M{"$push": bson.M{"movie_rankings": bson.M{"$each": added}}}))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Collection("users").BulkWrite(ctx, writes)
+	if err != nil {
+		return fmt.Errorf("error importing rankings: %v", err)
+	}
+	return nil
+}
+
+// GetMovieRankings returns the user's rankings of the given movies, in the same order.
+// Movie IDs differ between users, so rankings are matched by title first and by ID after
+// that. Movies the user has no ranking for get a new one at the default rating, and are
+// reported as not found.
+func (r *BattleRepository) GetMovieRankings(ctx context.Context, userID primitive.ObjectID, movies []models.Movie) ([]*models.MovieRanking, []bool, error) {
+	var result struct {
+		MovieRankings []models.MovieRanking `bson:"movie_rankings"`
+	}
+
+	err := r.db.Collection("users").FindOne(
+		ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"movie_rankings": 1}),
+	).Decode(&result)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, fmt.Errorf("user not found: %v", err)
+		}
+		return nil, nil, err
+	}
+
+	byTitle := make(map[string]*models.MovieRanking, len(result.MovieRankings))
+	byID := make(map[primitive.ObjectID]*models.MovieRanking, len(result.MovieRankings))
+	for i := range result.MovieRankings {
+		ranking := &result.MovieRankings[i]
+		byTitle[ranking.MovieTitle] = ranking
+		byID[ranking.MovieID] = ranking
+	}
+
+	rankings := make([]*models.MovieRanking, 0, len(movies))
+	found := make([]bool, len(movies))
+	for i, movie := range movies {
+		if ranking, ok := byTitle[movie.Title]; ok {
+			rankings = append(rankings, ranking)
+			found[i] = true
+			continue
+		}
+		if ranking, ok := byID[movie.ID]; ok {
+			rankings = append(rankings, ranking)
+			found[i] = true
+			continue
+		}
+
+		// If no ranking exists, return a new ranking with default values
+		movieID := movie.ID
+		if movieID.IsZero() {
+			movieID = primitive.NewObjectID()
+		}
+		rankings = append(rankings, &models.MovieRanking{
+			MovieID:     movieID,
+			MovieTitle:  movie.Title,
+			ELORating:   1200, // Default ELO rating
+			MatchCount:  0,
+			WinCount:    0,
+			LossCount:   0,
+			LastUpdated: time.Now(),
+		})
+	}
+
+	return rankings, found, nil
+}
+
+// GetTopTwentyByELO returns the top twenty movies for a user based on their ELO ratings
+func (r *BattleRepository) GetTopTwenty(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$sort", Value: bson.M{"movie_rankings.elo_rating": -1}}},
+		{{Key: "$limit", Value: 20}},
+		{{Key: "$project", Value: bson.M{
+			"movie_id":     "$movie_rankings.movie_id",
+			"movie_title":  "$movie_rankings.movie_title",
+			"elo_rating":   "$movie_rankings.elo_rating",
+			"match_count":  "$movie_rankings.match_count",
+			"win_count":    "$movie_rankings.win_count",
+			"loss_count":   "$movie_rankings.loss_count",
+			"last_updated": "$movie_rankings.last_updated",
+			"genres":       "$movie_rankings.genres",
+			"year":         "$movie_rankings.year",
+		}}},
+	}
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rankings []models.MovieRanking
+	if err = cursor.All(ctx, &rankings); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+
+	return rankings, nil
+}
+
+// rankingSortFields maps the sort keys of the ranking endpoint to document fields
+var rankingSortFields = map[string]string{
+	models.RankingSortELO:     "elo_rating",
+	models.RankingSortWins:    "win_count",
+	models.RankingSortMatches: "match_count",
+	models.RankingSortWinRate: "win_rate",
+	models.RankingSortRecent:  "last_updated",
+}
+
+// QueryRankings returns a filtered, sorted page of a user's movie rankings joined with
+// their catalog entries, and the total number of rankings matching the filters
+func (r *BattleRepository) QueryRankings(ctx context.Context, userID primitive.ObjectID, query models.RankingQuery) ([]models.RankedMovie, int64, error) {
+	sortField, ok := rankingSortFields[query.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort key %s", query.Sort)
+	}
+	direction := -1
+	if query.Ascending {
+		direction = 1
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie_rankings"}}},
+		{{Key: "$match", Value: bson.M{"match_count": bson.M{"$gte": query.MinMatches}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"win_rate": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$match_count", 0}},
+				bson.M{"$divide": bson.A{"$win_count", "$match_count"}},
+				0,
+			}},
+		}}},
+	}
+
+	// Genre and year are normalised onto each ranking
+	if query.Genre != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{
+			"genres": bson.M{"$regex": "^" + regexp.QuoteMeta(query.Genre) + "$", "$options": "i"},
+		}}})
+	}
+	if query.YearFrom > 0 || query.YearTo > 0 {
+		yearRange := bson.M{}
+		if query.YearFrom > 0 {
+			yearRange["$gte"] = query.YearFrom
+		}
+		if query.YearTo > 0 {
+			yearRange["$lte"] = query.YearTo
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"year": yearRange}}})
+	}
+
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: sortField, Value: direction}, {Key: "movie_title", Value: 1}}}},
+		bson.D{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"items": bson.A{
+				bson.M{"$skip": query.Offset},
+				bson.M{"$limit": query.Limit},
+				bson.M{"$lookup": bson.M{
+					"from":         "movies",
+					"localField":   "movie_title",
+					"foreignField": "title",
+					"as":           "movie",
+				}},
+				bson.M{"$unwind": bson.M{"path": "$movie", "preserveNullAndEmptyArrays": true}},
+			},
+		}}},
+	)
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Items []models.RankedMovie `bson:"items"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, fmt.Errorf("error decoding results: %v", err)
+	}
+
+	movies := []models.RankedMovie{}
+	var total int64
+	if len(results) > 0 {
+		movies = append(movies, results[0].Items...)
+		if len(results[0].Total) > 0 {
+			total = results[0].Total[0].Count
+		}
+	}
+
+	for i := range movies {
+		movies[i].Position = query.Offset + i + 1
+	}
+
+	return movies, total, nil
+}
+
+// ForEachRankedMovie calls fn, best first, with every movie the user has battled or marked
+// as seen, joined with its catalog entry. Iteration stops at the first error fn returns.
+func (r *BattleRepository) ForEachRankedMovie(ctx context.Context, userID primitive.ObjectID, fn func(movie *models.RankedMovie) error) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie_rankings"}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"match_count": bson.M{"$gt": 0}},
+			bson.M{"seen": true},
+		}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"win_rate": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$match_count", 0}},
+				bson.M{"$divide": bson.A{"$win_count", "$match_count"}},
+				0,
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "elo_rating", Value: -1}, {Key: "movie_title", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "movies",
+			"localField":   "movie_title",
+			"foreignField": "title",
+			"as":           "movie",
+		}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$movie", "preserveNullAndEmptyArrays": true}}},
+	}
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for position := 1; cursor.Next(ctx); position++ {
+		var movie models.RankedMovie
+		if err := cursor.Decode(&movie); err != nil {
+			return fmt.Errorf("error decoding ranked movie: %v", err)
+		}
+		movie.Position = position
+		if err := fn(&movie); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// BestRankingsByGenre returns, for every genre in the user's rankings, the highest rated
+// movies with at least minMatches battles, limited to perGroup movies per genre
+func (r *BattleRepository) BestRankingsByGenre(ctx context.Context, userID primitive.ObjectID, minMatches, perGroup int) ([]models.GenreRankings, error) {
+	groups := []models.GenreRankings{}
+	err := r.bestRankingsBy(ctx, userID, minMatches, perGroup, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$genres"}},
+		{{Key: "$addFields", Value: bson.M{"group": "$genres"}}},
+	}, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// BestRankingsByDecade returns, for every decade in the user's rankings, the highest rated
+// movies with at least minMatches battles, limited to perGroup movies per decade
+func (r *BattleRepository) BestRankingsByDecade(ctx context.Context, userID primitive.ObjectID, minMatches, perGroup int) ([]models.DecadeRankings, error) {
+	groups := []models.DecadeRankings{}
+	err := r.bestRankingsBy(ctx, userID, minMatches, perGroup, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"year": bson.M{"$gt": 0}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"group": bson.M{"$subtract": bson.A{"$year", bson.M{"$mod": bson.A{"$year", 10}}}},
+		}}},
+	}, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// bestRankingsBy groups a user's rankings on the "group" field set by the grouping stages
+// and keeps the top perGroup of each group by ELO
+func (r *BattleRepository) bestRankingsBy(ctx context.Context, userID primitive.ObjectID, minMatches, perGroup int, grouping mongo.Pipeline, results interface{}) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie_rankings"}}},
+		{{Key: "$match", Value: bson.M{"match_count": bson.M{"$gte": minMatches}}}},
+	}
+	pipeline = append(pipeline, grouping...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "elo_rating", Value: -1}, {Key: "movie_title", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":    "$group",
+			"movies": bson.M{"$push": "$$ROOT"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":    0,
+			"key":    "$_id",
+			"movies": bson.M{"$slice": bson.A{"$movies", perGroup}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.M{"key": 1}}},
+	)
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("error decoding results: %v", err)
+	}
+	return nil
+}
+
+// SampleRankings returns up to size random rankings of the user, only of the given genre
+// unless it is empty
+func (r *BattleRepository) SampleRankings(ctx context.Context, userID primitive.ObjectID, genre string, size int) ([]models.MovieRanking, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie_rankings"}}},
+	}
+	if genre != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{
+			"genres": bson.M{"$regex": "^" + regexp.QuoteMeta(genre) + "$", "$options": "i"},
+		}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sample", Value: bson.M{"size": size}}})
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rankings []models.MovieRanking
+	if err = cursor.All(ctx, &rankings); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+	return rankings, nil
+}
+
+// SetRankingAttributes fills in genre and year on every user's ranking of the given title
+// that doesn't have them yet, returning the number of users modified
+func (r *BattleRepository) SetRankingAttributes(ctx context.Context, title string, genres []string, year int) (int64, error) {
+	set := bson.M{}
+	if len(genres) > 0 {
+		set["movie_rankings.$[ranking].genres"] = genres
+	}
+	if year > 0 {
+		set["movie_rankings.$[ranking].year"] = year
+	}
+	if len(set) == 0 {
+		return 0, nil
+	}
+
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{
+			"ranking.movie_title": title,
+			"$or": bson.A{
+				bson.M{"ranking.genres": bson.M{"$exists": false}},
+				bson.M{"ranking.year": bson.M{"$exists": false}},
+			},
+		}},
+	})
+	result, err := r.db.Collection("users").UpdateMany(ctx,
+		bson.M{"movie_rankings.movie_title": title},
+		bson.M{"$set": set},
+		opts,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error setting ranking attributes: %v", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// GetTopTenByWins returns the top ten movies for a user based on their win count
+func (r *BattleRepository) GetTopTenByWins(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$sort", Value: bson.M{"movie_rankings.win_count": -1}}},
+		{{Key: "$limit", Value: 10}},
+		{{Key: "$project", Value: bson.M{
+			"movie_id":     "$movie_rankings.movie_id",
+			"movie_title":  "$movie_rankings.movie_title",
+			"elo_rating":   "$movie_rankings.elo_rating",
+			"match_count":  "$movie_rankings.match_count",
+			"win_count":    "$movie_rankings.win_count",
+			"loss_count":   "$movie_rankings.loss_count",
+			"last_updated": "$movie_rankings.last_updated",
+		}}},
+	}
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rankings []models.MovieRanking
+	if err = cursor.All(ctx, &rankings); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+
+	return rankings, nil
+}
+
+// GetTopTenByMatches returns the top ten movies for a user based on their match count
+func (r *BattleRepository) GetTopTenByMatches(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": userID}}},
+		{{Key: "$unwind", Value: "$movie_rankings"}},
+		{{Key: "$sort", Value: bson.M{"movie_rankings.match_count": -1}}},
+		{{Key: "$limit", Value: 10}},
+		{{Key: "$project", Value: bson.M{
+			"movie_id":     "$movie_rankings.movie_id",
+			"movie_title":  "$movie_rankings.movie_title",
+			"elo_rating":   "$movie_rankings.elo_rating",
+			"match_count":  "$movie_rankings.match_count",
+			"win_count":    "$movie_rankings.win_count",
+			"loss_count":   "$movie_rankings.loss_count",
+			"last_updated": "$movie_rankings.last_updated",
+		}}},
+	}
+
+	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rankings []models.MovieRanking
+	if err = cursor.All(ctx, &rankings); err != nil {
+		return nil, fmt.Errorf("error decoding results: %v", err)
+	}
+
+	return rankings, nil
+}
@@ -0,0 +1,158 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChallengeRepository stores the daily challenges and the votes cast on them
+type ChallengeRepository struct {
+	collection *mongo.Collection
+	votes      *mongo.Collection
+}
+
+func NewChallengeRepository(db *MongoDB) *ChallengeRepository {
+	return &ChallengeRepository{
+		collection: db.Collection("daily_challenges"),
+		votes:      db.Collection("challenge_votes"),
+	}
+}
+
+// FindByDate returns the challenge of a day, or nil if it has not been generated
+func (r *ChallengeRepository) FindByDate(ctx context.Context, date string) (*models.DailyChallenge, error) {
+	var challenge models.DailyChallenge
+	err := r.collection.FindOne(ctx, bson.M{"date": date}).Decode(&challenge)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CreateIfMissing stores the challenge unless one exists for its day already, and returns
+// whichever is stored
+func (r *ChallengeRepository) CreateIfMissing(ctx context.Context, challenge *models.DailyChallenge) (*models.DailyChallenge, error) {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"date": challenge.Date},
+		bson.M{"$setOnInsert": challenge},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("error creating daily challenge: %v", err)
+	}
+	return r.FindByDate(ctx, challenge.Date)
+}
+
+// ListBefore returns a page of the challenges of days before the date, newest first
+func (r *ChallengeRepository) ListBefore(ctx context.Context, date string, skip, limit int64) ([]models.DailyChallenge, int64, error) {
+	filter := bson.M{"date": bson.M{"$lt": date}}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting daily challenges: %v", err)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter,
+		options.Find().SetSort(bson.M{"date": -1}).SetSkip(skip).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing daily challenges: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	challenges := []models.DailyChallenge{}
+	if err = cursor.All(ctx, &challenges); err != nil {
+		return nil, 0, fmt.Errorf("error decoding daily challenges: %v", err)
+	}
+	return challenges, total, nil
+}
+
+// RecordVote stores the user's pick and adds it to the pair's tally. It reports false when
+// the user has already voted on the pair.
+func (r *ChallengeRepository) RecordVote(ctx context.Context, vote *models.ChallengeVote, sideA bool) (bool, error) {
+	result, err := r.votes.InsertOne(ctx, vote)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error recording challenge vote: %v", err)
+	}
+	vote.ID = result.InsertedID.(primitive.ObjectID)
+
+	field := "pairs.$.votes_b"
+	if sideA {
+		field = "pairs.$.votes_a"
+	}
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"date": vote.Date, "pairs.index": vote.Pair},
+		bson.M{"$inc": bson.M{field: 1}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("error counting challenge vote: %v", err)
+	}
+	return true, nil
+}
+
+// FindVotes returns the user's votes on the challenges of the given days
+func (r *ChallengeRepository) FindVotes(ctx context.Context, userID primitive.ObjectID, dates []string) ([]models.ChallengeVote, error) {
+	cursor, err := r.votes.Find(ctx, bson.M{"user_id": userID, "date": bson.M{"$in": dates}})
+	if err != nil {
+		return nil, fmt.Errorf("error finding challenge votes: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	votes := []models.ChallengeVote{}
+	if err = cursor.All(ctx, &votes); err != nil {
+		return nil, fmt.Errorf("error decoding challenge votes: %v", err)
+	}
+	return votes, nil
+}
+
+// FindAllVotesForUser returns every vote of the user, for account exports
+func (r *ChallengeRepository) FindAllVotesForUser(ctx context.Context, userID primitive.ObjectID) ([]models.ChallengeVote, error) {
+	cursor, err := r.votes.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "pair", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error finding challenge votes: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	votes := []models.ChallengeVote{}
+	if err = cursor.All(ctx, &votes); err != nil {
+		return nil, fmt.Errorf("error decoding challenge votes: %v", err)
+	}
+	return votes, nil
+}
+
+// DeleteVotesForUser removes the user's votes. The anonymous tallies on the challenges are kept.
+func (r *ChallengeRepository) DeleteVotesForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.votes.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EnsureIndexes creates the indexes the challenge and vote collections rely on
+func (r *ChallengeRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	names, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "date", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	voteNames, err := r.votes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}, {Key: "pair", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(names, voteNames...), nil
+}
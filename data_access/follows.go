@@ -0,0 +1,187 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FollowRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFollowRepository(db *MongoDB) *FollowRepository {
+	return &FollowRepository{collection: db.Collection("follows")}
+}
+
+// Create stores a follow unless the follower already follows or asked to follow the
+// followee, and returns the stored follow
+func (r *FollowRepository) Create(ctx context.Context, follow *models.Follow) (*models.Follow, error) {
+	onInsert := bson.M{
+		"status":     follow.Status,
+		"created_at": follow.CreatedAt,
+	}
+	if follow.AcceptedAt != nil {
+		onInsert["accepted_at"] = follow.AcceptedAt
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored models.Follow
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"follower_id": follow.FollowerID, "followee_id": follow.FolloweeID},
+		bson.M{"$setOnInsert": onInsert},
+		opts,
+	).Decode(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("error creating follow: %v", err)
+	}
+	return &stored, nil
+}
+
+// Find returns the follow from follower to followee, or nil if there is none
+func (r *FollowRepository) Find(ctx context.Context, followerID, followeeID primitive.ObjectID) (*models.Follow, error) {
+	var follow models.Follow
+	err := r.collection.FindOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID}).Decode(&follow)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &follow, nil
+}
+
+// Accept accepts a pending follow request, reporting whether there was one
+func (r *FollowRepository) Accept(ctx context.Context, followerID, followeeID primitive.ObjectID, at time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"follower_id": followerID, "followee_id": followeeID, "status": models.FollowPending},
+		bson.M{"$set": bson.M{"status": models.FollowAccepted, "accepted_at": at}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// Delete removes a follow or follow request in the given states, reporting whether one existed
+func (r *FollowRepository) Delete(ctx context.Context, followerID, followeeID primitive.ObjectID, statuses ...string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{
+		"follower_id": followerID,
+		"followee_id": followeeID,
+		"status":      bson.M{"$in": statuses},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ListFollowers returns a page of the follows pointing at the user in the given state, newest first
+func (r *FollowRepository) ListFollowers(ctx context.Context, userID primitive.ObjectID, status string, skip, limit int64) ([]models.Follow, int64, error) {
+	return r.list(ctx, bson.M{"followee_id": userID, "status": status}, skip, limit)
+}
+
+// ListFollowing returns a page of the follows made by the user in the given state, newest first
+func (r *FollowRepository) ListFollowing(ctx context.Context, userID primitive.ObjectID, status string, skip, limit int64) ([]models.Follow, int64, error) {
+	return r.list(ctx, bson.M{"follower_id": userID, "status": status}, skip, limit)
+}
+
+func (r *FollowRepository) list(ctx context.Context, filter bson.M, skip, limit int64) ([]models.Follow, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting follows: %v", err)
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetSkip(skip).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing follows: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	follows := []models.Follow{}
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, 0, fmt.Errorf("error decoding follows: %v", err)
+	}
+	return follows, total, nil
+}
+
+// FollowingIDs returns the IDs of every user the user follows with an accepted follow
+func (r *FollowRepository) FollowingIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"follower_id": userID, "status": models.FollowAccepted},
+		options.Find().SetProjection(bson.M{"followee_id": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing follows: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var follows []models.Follow
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, fmt.Errorf("error decoding follows: %v", err)
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.FolloweeID)
+	}
+	return ids, nil
+}
+
+// IsAcceptedFollower reports whether follower follows followee with an accepted follow
+func (r *FollowRepository) IsAcceptedFollower(ctx context.Context, followerID, followeeID primitive.ObjectID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"follower_id": followerID,
+		"followee_id": followeeID,
+		"status":      models.FollowAccepted,
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindByUser returns every follow made by or pointing at the user, for data exports
+func (r *FollowRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Follow, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"$or": bson.A{bson.M{"follower_id": userID}, bson.M{"followee_id": userID}}},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error finding follows: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	follows := []models.Follow{}
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, fmt.Errorf("error decoding follows: %v", err)
+	}
+	return follows, nil
+}
+
+// DeleteAllForUser removes every follow made by or pointing at the user
+func (r *FollowRepository) DeleteAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx,
+		bson.M{"$or": bson.A{bson.M{"follower_id": userID}, bson.M{"followee_id": userID}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EnsureIndexes creates the indexes the follows collection relies on
+func (r *FollowRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "followee_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
@@ -0,0 +1,149 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LeaderboardRepository stores the materialised community leaderboard. Each refresh is
+// written as a new generation and only becomes visible once it is complete.
+type LeaderboardRepository struct {
+	entries *mongo.Collection
+	meta    *mongo.Collection
+}
+
+func NewLeaderboardRepository(db *MongoDB) *LeaderboardRepository {
+	return &LeaderboardRepository{
+		entries: db.Collection("global_leaderboard"),
+		meta:    db.Collection("global_leaderboard_meta"),
+	}
+}
+
+type leaderboardMeta struct {
+	Generation int64     `bson:"generation"`
+	UpdatedAt  time.Time `bson:"updated_at"`
+}
+
+// Publish writes a new generation of entries, switches readers to it and removes older ones.
+// Another instance may publish concurrently, so readers only ever move to a newer generation
+// and entries of a newer one still being written are left alone.
+func (r *LeaderboardRepository) Publish(ctx context.Context, entries []models.GlobalLeaderboardEntry, generation int64, updatedAt time.Time) error {
+	if len(entries) > 0 {
+		docs := make([]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			docs = append(docs, entry)
+		}
+		if _, err := r.entries.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("error writing leaderboard: %v", err)
+		}
+	}
+
+	// With a newer generation already current the filter doesn't match and the upsert
+	// collides with the existing document
+	_, err := r.meta.UpdateOne(ctx,
+		bson.M{"_id": "current", "generation": bson.M{"$lt": generation}},
+		bson.M{"$set": leaderboardMeta{Generation: generation, UpdatedAt: updatedAt}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("error publishing leaderboard: %v", err)
+	}
+
+	meta, err := r.current(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading leaderboard: %v", err)
+	}
+	if meta == nil {
+		return nil
+	}
+	if _, err := r.entries.DeleteMany(ctx, bson.M{"generation": bson.M{"$lt": meta.Generation}}); err != nil {
+		return fmt.Errorf("error removing old leaderboard: %v", err)
+	}
+	return nil
+}
+
+// current returns the generation readers should see, or nil before the first refresh
+func (r *LeaderboardRepository) current(ctx context.Context) (*leaderboardMeta, error) {
+	var meta leaderboardMeta
+	err := r.meta.FindOne(ctx, bson.M{"_id": "current"}).Decode(&meta)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// List returns a page of the current leaderboard ordered by rank, only including movies
+// with at least minMatches community battles. Ranks are renumbered within that list.
+func (r *LeaderboardRepository) List(ctx context.Context, skip, limit int64, minMatches int) ([]models.GlobalLeaderboardEntry, int64, time.Time, error) {
+	entries := []models.GlobalLeaderboardEntry{}
+
+	meta, err := r.current(ctx)
+	if err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("error reading leaderboard: %v", err)
+	}
+	if meta == nil {
+		return entries, 0, time.Time{}, nil
+	}
+
+	filter := bson.M{
+		"generation":        meta.Generation,
+		"community_matches": bson.M{"$gte": minMatches},
+	}
+
+	total, err := r.entries.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("error counting leaderboard: %v", err)
+	}
+
+	cursor, err := r.entries.Find(ctx, filter,
+		options.Find().SetSort(bson.M{"rank": 1}).SetSkip(skip).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("error reading leaderboard: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("error decoding leaderboard: %v", err)
+	}
+	for i := range entries {
+		entries[i].Rank = int(skip) + i + 1
+	}
+
+	return entries, total, meta.UpdatedAt, nil
+}
+
+// FindByTitle returns the current leaderboard entry of a movie, or nil if it has none
+func (r *LeaderboardRepository) FindByTitle(ctx context.Context, title string) (*models.GlobalLeaderboardEntry, error) {
+	meta, err := r.current(ctx)
+	if err != nil || meta == nil {
+		return nil, err
+	}
+
+	var entry models.GlobalLeaderboardEntry
+	err = r.entries.FindOne(ctx, bson.M{"generation": meta.Generation, "movie_title": title}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// EnsureIndexes creates the indexes the global_leaderboard collection relies on
+func (r *LeaderboardRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.entries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "generation", Value: 1}, {Key: "rank", Value: 1}}},
+		{Keys: bson.D{{Key: "generation", Value: 1}, {Key: "movie_title", Value: 1}}},
+	})
+}
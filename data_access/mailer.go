@@ -0,0 +1,54 @@
+package data_access
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends transactional emails over SMTP. Without an SMTP host it only logs the
+// message, which is enough for local development.
+type Mailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewMailer(host, port, username, password, from string) *Mailer {
+	return &Mailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *Mailer) Send(to, subject, body string) error {
+	if m.host == "" {
+		fmt.Printf("Email to %s (SMTP not configured)\nSubject: %s\n%s\n", to, subject, body)
+		return nil
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MFAAttemptRepository counts the codes tried against each two-factor challenge, keyed by
+// the challenge's jti, so the limit holds across instances
+type MFAAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMFAAttemptRepository(db *MongoDB) *MFAAttemptRepository {
+	return &MFAAttemptRepository{collection: db.Collection("mfa_attempts")}
+}
+
+// Create registers a new challenge without any attempts
+func (r *MFAAttemptRepository) Create(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.collection.InsertOne(ctx, bson.M{"_id": jti, "attempts": 0, "expires_at": expiresAt})
+	if err != nil {
+		return fmt.Errorf("error creating two-factor challenge: %v", err)
+	}
+	return nil
+}
+
+// Reserve counts one attempt at the challenge before its code is checked. It reports
+// false if the challenge is unknown, used up or already has max attempts.
+func (r *MFAAttemptRepository) Reserve(ctx context.Context, jti string, max int) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": jti, "attempts": bson.M{"$lt": max}},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("error counting two-factor attempt: %v", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Delete removes the challenge once it has been used to log in
+func (r *MFAAttemptRepository) Delete(ctx context.Context, jti string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": jti})
+	return err
+}
+
+// EnsureIndexes creates the indexes the mfa_attempts collection relies on
+func (r *MFAAttemptRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// MongoDB removes challenges once they have expired
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+}
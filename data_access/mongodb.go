@@ -33,6 +33,14 @@ func NewMongoDB(connectionString string, dbName string) (*MongoDB, error) {
 	}, nil
 }
 
+// WrapMongoDB uses an already connected client, e.g. a mock deployment in tests
+func WrapMongoDB(client *mongo.Client, dbName string) *MongoDB {
+	return &MongoDB{
+		client: client,
+		db:     client.Database(dbName),
+	}
+}
+
 func (m *MongoDB) Collection(name string) *mongo.Collection {
 	return m.db.Collection(name)
 }
@@ -0,0 +1,50 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OIDCLoginRepository stores provider logins between the redirect to the provider and its
+// callback, which may reach another instance
+type OIDCLoginRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOIDCLoginRepository(db *MongoDB) *OIDCLoginRepository {
+	return &OIDCLoginRepository{collection: db.Collection("oidc_logins")}
+}
+
+func (r *OIDCLoginRepository) Create(ctx context.Context, login *models.OIDCPendingLogin) error {
+	if _, err := r.collection.InsertOne(ctx, login); err != nil {
+		return fmt.Errorf("error saving login: %v", err)
+	}
+	return nil
+}
+
+// Take removes and returns the login started with the state, or nil if there is none.
+// Each state can only be used once.
+func (r *OIDCLoginRepository) Take(ctx context.Context, state string) (*models.OIDCPendingLogin, error) {
+	var login models.OIDCPendingLogin
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": state}).Decode(&login)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// EnsureIndexes creates the indexes the oidc_logins collection relies on
+func (r *OIDCLoginRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// MongoDB removes abandoned logins once they have expired
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+}
@@ -0,0 +1,41 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register decoders for the poster formats OMDB serves
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Largest poster we are willing to download
+const maxPosterBytes = 5 << 20
+
+var posterClient = &http.Client{Timeout: 10 * time.Second}
+
+// FetchPoster downloads and decodes a poster image
+func FetchPoster(ctx context.Context, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating poster request: %v", err)
+	}
+
+	resp, err := posterClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching poster: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("poster request returned status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxPosterBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding poster: %v", err)
+	}
+	return img, nil
+}
@@ -4,33 +4,17 @@ import (
 	"context"
 	"fmt"
 	"movie-vs-backend/models"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type UserRepository struct {
-	db         *MongoDB
-	collection *mongo.Collection
-}
-
 type MovieRepository struct {
 	db *MongoDB
 }
 
-type BattleRepository struct {
-	db *MongoDB
-}
-
-func NewUserRepository(db *MongoDB) *UserRepository {
-	return &UserRepository{
-		db:         db,
-		collection: db.Collection("users"),
-	}
-}
-
 func NewMovieRepository(db *MongoDB) *MovieRepository {
 	return &MovieRepository{db: db}
 }
@@ -72,190 +56,114 @@ func (r *MovieRepository) FindMovieByTitle(ctx context.Context, title string) (*
 	return nil, nil
 }
 
-func NewBattleRepository(db *MongoDB) *BattleRepository {
-	return &BattleRepository{db: db}
-}
-
-// UserRepository methods
-func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
-	_, err := r.collection.InsertOne(ctx, user)
-	return err
-}
+// ListCatalog returns a page of movies from the catalog collection, ordered by title
+func (r *MovieRepository) ListCatalog(ctx context.Context, skip, limit int64) ([]models.Movie, int64, error) {
+	collection := r.db.Collection("movies")
 
-func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
-	var user models.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		return nil, nil
+	total, err := collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting catalog: %v", err)
 	}
-	return &user, err
-}
-
-// SaveMovieRanking saves or updates a movie ranking for a user
-func (r *BattleRepository) SaveMovieRanking(ctx context.Context, userID primitive.ObjectID, ranking *models.MovieRanking) error {
-	// First try to find and update an existing ranking
-	result, err := r.db.Collection("users").UpdateOne(
-		ctx,
-		bson.M{
-			"_id":                     userID,
-			"movie_rankings.movie_id": ranking.MovieID,
-		},
-		bson.M{
-			"$set": bson.M{
-				"movie_rankings.$.elo_rating":   ranking.ELORating,
-				"movie_rankings.$.match_count":  ranking.MatchCount,
-				"movie_rankings.$.win_count":    ranking.WinCount,
-				"movie_rankings.$.loss_count":   ranking.LossCount,
-				"movie_rankings.$.last_updated": ranking.LastUpdated,
-			},
-		},
-	)
 
+	opts := options.Find().SetSort(bson.M{"title": 1}).SetSkip(skip).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		return err
+		return nil, 0, fmt.Errorf("error listing catalog: %v", err)
 	}
+	defer cursor.Close(ctx)
 
-	// If no existing ranking was found, add it as a new one
-	if result.MatchedCount == 0 {
-		_, err = r.db.Collection("users").UpdateOne(
-			ctx,
-			bson.M{"_id": userID},
-			bson.M{"$push": bson.M{"movie_rankings": ranking}},
-		)
+	movies := []models.Movie{}
+	if err = cursor.All(ctx, &movies); err != nil {
+		return nil, 0, fmt.Errorf("error decoding catalog: %v", err)
 	}
 
-	return err
+	return movies, total, nil
 }
 
-// GetMovieRanking returns the ranking for a specific movie for a user
-func (r *BattleRepository) GetMovieRanking(ctx context.Context, userID primitive.ObjectID, movieID primitive.ObjectID) (*models.MovieRanking, error) {
-	// Find the user's document and extract the specific movie ranking
-	var result struct {
-		MovieRankings []models.MovieRanking `bson:"movie_rankings"`
-	}
-
-	err := r.db.Collection("users").FindOne(
-		ctx,
-		bson.M{"_id": userID},
-	).Decode(&result)
-
+// CountCatalog returns the number of movies in the catalog
+func (r *MovieRepository) CountCatalog(ctx context.Context) (int64, error) {
+	total, err := r.db.Collection("movies").CountDocuments(ctx, bson.M{})
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("user not found: %v", err)
-		}
-		return nil, err
+		return 0, fmt.Errorf("error counting catalog: %v", err)
 	}
-
-	// Look for the specific movie ranking in the array
-	for _, ranking := range result.MovieRankings {
-		if ranking.MovieID == movieID {
-			return &ranking, nil
-		}
-	}
-
-	// If no ranking exists, return a new ranking with default values
-	return &models.MovieRanking{
-		MovieID:     movieID,
-		ELORating:   1200, // Default ELO rating
-		MatchCount:  0,
-		WinCount:    0,
-		LossCount:   0,
-		LastUpdated: time.Now(),
-	}, nil
+	return total, nil
 }
 
-// GetTopTwentyByELO returns the top twenty movies for a user based on their ELO ratings
-func (r *BattleRepository) GetTopTwenty(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"_id": userID}}},
-		{{Key: "$unwind", Value: "$movie_rankings"}},
-		{{Key: "$sort", Value: bson.M{"movie_rankings.elo_rating": -1}}},
-		{{Key: "$limit", Value: 20}},
-		{{Key: "$project", Value: bson.M{
-			"movie_id":     "$movie_rankings.movie_id",
-			"movie_title":  "$movie_rankings.movie_title",
-			"elo_rating":   "$movie_rankings.elo_rating",
-			"match_count":  "$movie_rankings.match_count",
-			"win_count":    "$movie_rankings.win_count",
-			"loss_count":   "$movie_rankings.loss_count",
-			"last_updated": "$movie_rankings.last_updated",
-		}}},
+// FindCatalogMovieByTitle returns the catalog entry for a title, or nil if there is none
+func (r *MovieRepository) FindCatalogMovieByTitle(ctx context.Context, title string) (*models.Movie, error) {
+	var movie models.Movie
+	err := r.db.Collection("movies").FindOne(ctx, bson.M{"title": title}).Decode(&movie)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
 	}
-
-	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
 	if err != nil {
-		return nil, fmt.Errorf("error executing aggregate: %v", err)
+		return nil, fmt.Errorf("error finding catalog movie: %v", err)
 	}
-	defer cursor.Close(ctx)
+	return &movie, nil
+}
 
-	var rankings []models.MovieRanking
-	if err = cursor.All(ctx, &rankings); err != nil {
-		return nil, fmt.Errorf("error decoding results: %v", err)
+// FindCatalogMovieByID returns a catalog entry, or nil if there is none
+func (r *MovieRepository) FindCatalogMovieByID(ctx context.Context, movieID primitive.ObjectID) (*models.Movie, error) {
+	var movie models.Movie
+	err := r.db.Collection("movies").FindOne(ctx, bson.M{"_id": movieID}).Decode(&movie)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
 	}
-
-	return rankings, nil
+	if err != nil {
+		return nil, fmt.Errorf("error finding catalog movie: %v", err)
+	}
+	return &movie, nil
 }
 
-// GetTopTenByWins returns the top ten movies for a user based on their win count
-func (r *BattleRepository) GetTopTenByWins(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"_id": userID}}},
-		{{Key: "$unwind", Value: "$movie_rankings"}},
-		{{Key: "$sort", Value: bson.M{"movie_rankings.win_count": -1}}},
-		{{Key: "$limit", Value: 10}},
-		{{Key: "$project", Value: bson.M{
-			"movie_id":     "$movie_rankings.movie_id",
-			"movie_title":  "$movie_rankings.movie_title",
-			"elo_rating":   "$movie_rankings.elo_rating",
-			"match_count":  "$movie_rankings.match_count",
-			"win_count":    "$movie_rankings.win_count",
-			"loss_count":   "$movie_rankings.loss_count",
-			"last_updated": "$movie_rankings.last_updated",
-		}}},
+// AddCatalogMovie inserts a movie into the catalog
+func (r *MovieRepository) AddCatalogMovie(ctx context.Context, movie *models.Movie) error {
+	result, err := r.db.Collection("movies").InsertOne(ctx, movie)
+	if err != nil {
+		return err
 	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		movie.ID = id
+	}
+	return nil
+}
 
-	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
+// DeleteCatalogMovie removes a movie from the catalog
+func (r *MovieRepository) DeleteCatalogMovie(ctx context.Context, movieID primitive.ObjectID) error {
+	result, err := r.db.Collection("movies").DeleteOne(ctx, bson.M{"_id": movieID})
 	if err != nil {
-		return nil, fmt.Errorf("error executing aggregate: %v", err)
+		return err
 	}
-	defer cursor.Close(ctx)
-
-	var rankings []models.MovieRanking
-	if err = cursor.All(ctx, &rankings); err != nil {
-		return nil, fmt.Errorf("error decoding results: %v", err)
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
 	}
-
-	return rankings, nil
+	return nil
 }
 
-// GetTopTenByMatches returns the top ten movies for a user based on their match count
-func (r *BattleRepository) GetTopTenByMatches(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"_id": userID}}},
-		{{Key: "$unwind", Value: "$movie_rankings"}},
-		{{Key: "$sort", Value: bson.M{"movie_rankings.match_count": -1}}},
-		{{Key: "$limit", Value: 10}},
-		{{Key: "$project", Value: bson.M{
-			"movie_id":     "$movie_rankings.movie_id",
-			"movie_title":  "$movie_rankings.movie_title",
-			"elo_rating":   "$movie_rankings.elo_rating",
-			"match_count":  "$movie_rankings.match_count",
-			"win_count":    "$movie_rankings.win_count",
-			"loss_count":   "$movie_rankings.loss_count",
-			"last_updated": "$movie_rankings.last_updated",
-		}}},
+// ImportCatalog upserts movies into the catalog keyed by title and returns how many were added
+func (r *MovieRepository) ImportCatalog(ctx context.Context, movies []models.Movie) (int64, error) {
+	if len(movies) == 0 {
+		return 0, nil
 	}
 
-	cursor, err := r.db.Collection("users").Aggregate(ctx, pipeline)
-	if err != nil {
-		return nil, fmt.Errorf("error executing aggregate: %v", err)
+	writes := make([]mongo.WriteModel, 0, len(movies))
+	for _, movie := range movies {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"title": movie.Title}).
+			SetUpdate(bson.M{"$setOnInsert": movie}).
+			SetUpsert(true))
 	}
-	defer cursor.Close(ctx)
 
-	var rankings []models.MovieRanking
-	if err = cursor.All(ctx, &rankings); err != nil {
-		return nil, fmt.Errorf("error decoding results: %v", err)
+	result, err := r.db.Collection("movies").BulkWrite(ctx, writes)
+	if err != nil {
+		return 0, fmt.Errorf("error importing catalog: %v", err)
 	}
 
-	return rankings, nil
+	return result.UpsertedCount, nil
+}
+
+// EnsureIndexes creates the indexes the movies catalog collection relies on
+func (r *MovieRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.db.Collection("movies").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "title", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
 }
@@ -0,0 +1,101 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ShareRepository struct {
+	collection *mongo.Collection
+}
+
+func NewShareRepository(db *MongoDB) *ShareRepository {
+	return &ShareRepository{collection: db.Collection("shares")}
+}
+
+func (r *ShareRepository) Create(ctx context.Context, share *models.Share) error {
+	result, err := r.collection.InsertOne(ctx, share)
+	if err != nil {
+		return fmt.Errorf("error creating share: %v", err)
+	}
+	share.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByToken returns the share published under the token, or nil if there is none.
+// The image card is only loaded when asked for.
+func (r *ShareRepository) FindByToken(ctx context.Context, token string, withCard bool) (*models.Share, error) {
+	opts := options.FindOne()
+	if !withCard {
+		opts.SetProjection(bson.M{"card": 0})
+	}
+
+	var share models.Share
+	err := r.collection.FindOne(ctx, bson.M{"token": token}, opts).Decode(&share)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ListByUser returns the user's shares without their image cards, newest first
+func (r *ShareRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Share, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.M{"created_at": -1}).SetProjection(bson.M{"card": 0}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing shares: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	shares := []models.Share{}
+	if err = cursor.All(ctx, &shares); err != nil {
+		return nil, fmt.Errorf("error decoding shares: %v", err)
+	}
+	return shares, nil
+}
+
+// SetCard stores the rendered image card unless one was stored already
+func (r *ShareRepository) SetCard(ctx context.Context, shareID primitive.ObjectID, card []byte) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": shareID, "card": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"card": card}},
+	)
+	return err
+}
+
+// Delete removes one of the user's shares, reporting whether it existed
+func (r *ShareRepository) Delete(ctx context.Context, userID primitive.ObjectID, token string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "token": token})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// DeleteAllForUser removes every share of the user
+func (r *ShareRepository) DeleteAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EnsureIndexes creates the indexes the shares collection relies on
+func (r *ShareRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
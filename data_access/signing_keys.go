@@ -0,0 +1,85 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SigningKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSigningKeyRepository(db *MongoDB) *SigningKeyRepository {
+	return &SigningKeyRepository{collection: db.Collection("signing_keys")}
+}
+
+// ListUsable returns every key that can still verify tokens, newest first
+func (r *SigningKeyRepository) ListUsable(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"verify_until": bson.M{"$exists": false}},
+			bson.M{"verify_until": bson.M{"$gt": now}},
+		}},
+		options.Find().SetSort(bson.M{"created_at": -1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing signing keys: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var keys []models.SigningKey
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("error decoding signing keys: %v", err)
+	}
+	return keys, nil
+}
+
+// InsertIfMissing stores a key unless one with the same kid already exists
+func (r *SigningKeyRepository) InsertIfMissing(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"kid": key.Kid},
+		bson.M{"$setOnInsert": key},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// SaveActivePublicKey stores the public key of a key file's key as active, reactivating it
+// if it was retired before. A private key stored for the same kid is removed.
+func (r *SigningKeyRepository) SaveActivePublicKey(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"kid": key.Kid},
+		bson.M{
+			"$set":         bson.M{"public_key": key.PublicKey},
+			"$unset":       bson.M{"private_key": "", "retired_at": "", "verify_until": ""},
+			"$setOnInsert": bson.M{"algorithm": key.Algorithm, "created_at": key.CreatedAt},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RetireAllExcept retires every active key other than kid, keeping them valid for verification until verifyUntil
+func (r *SigningKeyRepository) RetireAllExcept(ctx context.Context, kid string, retiredAt, verifyUntil time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{
+			"kid":        bson.M{"$ne": kid},
+			"retired_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"retired_at": retiredAt, "verify_until": verifyUntil}},
+	)
+	return err
+}
+
+// EnsureIndexes creates the indexes the signing_keys collection relies on
+func (r *SigningKeyRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "kid", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+}
@@ -0,0 +1,125 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TournamentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTournamentRepository(db *MongoDB) *TournamentRepository {
+	return &TournamentRepository{collection: db.Collection("tournaments")}
+}
+
+func (r *TournamentRepository) Create(ctx context.Context, tournament *models.Tournament) error {
+	result, err := r.collection.InsertOne(ctx, tournament)
+	if err != nil {
+		return fmt.Errorf("error creating tournament: %v", err)
+	}
+	tournament.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID returns one of the user's tournaments, or nil if there is none
+func (r *TournamentRepository) FindByID(ctx context.Context, userID, tournamentID primitive.ObjectID) (*models.Tournament, error) {
+	var tournament models.Tournament
+	err := r.collection.FindOne(ctx, bson.M{"_id": tournamentID, "user_id": userID}).Decode(&tournament)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tournament, nil
+}
+
+// ListByUser returns the user's tournaments, most recently played first
+func (r *TournamentRepository) ListByUser(ctx context.Context, userID primitive.ObjectID, skip, limit int64) ([]models.Tournament, int64, error) {
+	filter := bson.M{"user_id": userID}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting tournaments: %v", err)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter,
+		options.Find().SetSort(bson.M{"updated_at": -1}).SetSkip(skip).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing tournaments: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	tournaments := []models.Tournament{}
+	if err = cursor.All(ctx, &tournaments); err != nil {
+		return nil, 0, fmt.Errorf("error decoding tournaments: %v", err)
+	}
+	return tournaments, total, nil
+}
+
+// FindAllForUser returns every tournament of the user, for account exports
+func (r *TournamentRepository) FindAllForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Tournament, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("error finding tournaments: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	tournaments := []models.Tournament{}
+	if err = cursor.All(ctx, &tournaments); err != nil {
+		return nil, fmt.Errorf("error decoding tournaments: %v", err)
+	}
+	return tournaments, nil
+}
+
+// Update stores the tournament if nobody saved it since it was loaded, bumping its
+// version. It reports false when the stored version has moved on.
+func (r *TournamentRepository) Update(ctx context.Context, tournament *models.Tournament) (bool, error) {
+	loaded := tournament.Version
+	tournament.Version++
+	result, err := r.collection.ReplaceOne(ctx,
+		bson.M{"_id": tournament.ID, "user_id": tournament.UserID, "version": loaded},
+		tournament,
+	)
+	if err != nil {
+		tournament.Version = loaded
+		return false, fmt.Errorf("error updating tournament: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		tournament.Version = loaded
+		return false, nil
+	}
+	return true, nil
+}
+
+// Delete removes one of the user's tournaments, reporting whether it existed
+func (r *TournamentRepository) Delete(ctx context.Context, userID, tournamentID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": tournamentID, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// DeleteAllForUser removes every tournament of the user
+func (r *TournamentRepository) DeleteAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EnsureIndexes creates the indexes the tournaments collection relies on
+func (r *TournamentRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+}
@@ -0,0 +1,595 @@
+package data_access
+
+import (
+	"context"
+	"fmt"
+	"movie-vs-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserRepository struct {
+	db         *MongoDB
+	collection *mongo.Collection
+}
+
+func NewUserRepository(db *MongoDB) *UserRepository {
+	return &UserRepository{
+		db:         db,
+		collection: db.Collection("users"),
+	}
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		user.ID = id
+	}
+	return nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// FindByIdentity returns the user linked to an external provider account, or nil if none is
+func (r *UserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// AddIdentity links an external provider account to an existing user
+func (r *UserRepository) AddIdentity(ctx context.Context, userID primitive.ObjectID, identity models.FederatedIdentity) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$push": bson.M{"identities": identity}},
+	)
+	return err
+}
+
+// FindProfileByID returns the user without the embedded movie rankings
+func (r *UserRepository) FindProfileByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"movie_rankings": 0}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// FindAccess returns only the role, disabled flag and guest expiry of a user, or nil if
+// there is no such user
+func (r *UserRepository) FindAccess(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"role": 1, "disabled": 1, "guest_expires_at": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// UpdateProfile sets the given profile fields
+func (r *UserRepository) UpdateProfile(ctx context.Context, userID primitive.ObjectID, fields bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"last_login": at}},
+	)
+	return err
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID primitive.ObjectID, hashedPassword string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"password": hashedPassword}},
+	)
+	return err
+}
+
+// SetPendingEmail stores a requested email change until the new address is verified
+func (r *UserRepository) SetPendingEmail(ctx context.Context, userID primitive.ObjectID, email, tokenHash string, expiry time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{
+			"pending_email":             email,
+			"email_verification_hash":   tokenHash,
+			"email_verification_expiry": expiry,
+		}},
+	)
+	return err
+}
+
+// FindByEmailVerificationHash returns the user with a pending email change for the token hash
+func (r *UserRepository) FindByEmailVerificationHash(ctx context.Context, tokenHash string) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx,
+		bson.M{"email_verification_hash": tokenHash},
+		options.FindOne().SetProjection(bson.M{"movie_rankings": 0}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// ConfirmEmailChange swaps in the verified pending email
+func (r *UserRepository) ConfirmEmailChange(ctx context.Context, userID primitive.ObjectID, email string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{"email": email, "email_verified": true},
+			"$unset": bson.M{
+				"pending_email":             "",
+				"email_verification_hash":   "",
+				"email_verification_expiry": "",
+			},
+		},
+	)
+	return err
+}
+
+// SetMFAPendingSecret stores a TOTP secret that becomes active once the user confirms a code
+func (r *UserRepository) SetMFAPendingSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"mfa_pending_secret": secret}},
+	)
+	return err
+}
+
+// EnableMFA activates the pending secret together with the hashed backup codes
+func (r *UserRepository) EnableMFA(ctx context.Context, userID primitive.ObjectID, secret string, hashedBackupCodes []string, usedStep int64) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"mfa_enabled":        true,
+				"mfa_secret":         secret,
+				"mfa_backup_codes":   hashedBackupCodes,
+				"mfa_last_used_step": usedStep,
+			},
+			"$unset": bson.M{"mfa_pending_secret": ""},
+		},
+	)
+	return err
+}
+
+// DisableMFA removes every two-factor setting from the user
+func (r *UserRepository) DisableMFA(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{"mfa_enabled": false},
+			"$unset": bson.M{
+				"mfa_secret":         "",
+				"mfa_pending_secret": "",
+				"mfa_backup_codes":   "",
+				"mfa_last_used_step": "",
+			},
+		},
+	)
+	return err
+}
+
+// ConsumeMFAStep records a used TOTP time step. It returns false if that step (or a later
+// one) was already used, which stops a code from being replayed.
+func (r *UserRepository) ConsumeMFAStep(ctx context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "mfa_last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"mfa_last_used_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RemoveMFABackupCode deletes a used backup code hash. It returns false if it was already gone.
+func (r *UserRepository) RemoveMFABackupCode(ctx context.Context, userID primitive.ObjectID, hashedCode string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "mfa_backup_codes": hashedCode},
+		bson.M{"$pull": bson.M{"mfa_backup_codes": hashedCode}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// ListUsers returns a page of users without their movie rankings, newest first
+func (r *UserRepository) ListUsers(ctx context.Context, skip, limit int64) ([]models.UserSummary, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting users: %v", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(skip).
+		SetLimit(limit).
+		SetProjection(bson.M{"movie_rankings": 0, "password": 0})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing users: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.UserSummary{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, 0, fmt.Errorf("error decoding users: %v", err)
+	}
+
+	return users, total, nil
+}
+
+// SetDisabled enables or disables a user account
+func (r *UserRepository) SetDisabled(ctx context.Context, userID primitive.ObjectID, disabled bool) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"disabled": disabled}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetRole changes the role of a user account
+func (r *UserRepository) SetRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RecordBattleStats adds a submission to the user's running battle totals and returns the
+// user's role and updated totals, or nil if there is no such user. The streak grows when
+// the previous battle day was previousDay and starts over after a gap.
+func (r *UserRepository) RecordBattleStats(ctx context.Context, userID primitive.ObjectID, battles, newMovies int, day, previousDay string) (*models.User, error) {
+	stat := func(field string) bson.M {
+		return bson.M{"$ifNull": bson.A{"$battle_stats." + field, 0}}
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"battle_stats.battles":        bson.M{"$add": bson.A{stat("battles"), battles}},
+			"battle_stats.battled_movies": bson.M{"$add": bson.A{stat("battled_movies"), newMovies}},
+			"battle_stats.last_day":       day,
+			"battle_stats.current_streak": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": bson.M{"$eq": bson.A{"$battle_stats.last_day", day}}, "then": bson.M{"$max": bson.A{stat("current_streak"), 1}}},
+					bson.M{"case": bson.M{"$eq": bson.A{"$battle_stats.last_day", previousDay}}, "then": bson.M{"$add": bson.A{stat("current_streak"), 1}}},
+				},
+				"default": 1,
+			}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"battle_stats.longest_streak": bson.M{"$max": bson.A{stat("longest_streak"), "$battle_stats.current_streak"}},
+		}}},
+	}
+
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update,
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"role": 1, "battle_stats": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AddBattleStats adjusts the user's battle and battled movie totals, e.g. after an undo
+func (r *UserRepository) AddBattleStats(ctx context.Context, userID primitive.ObjectID, battles, battledMovies int) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$inc": bson.M{"battle_stats.battles": battles, "battle_stats.battled_movies": battledMovies}},
+	)
+	return err
+}
+
+// SetBattleStats replaces the user's running battle totals
+func (r *UserRepository) SetBattleStats(ctx context.Context, userID primitive.ObjectID, stats models.BattleStats) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"battle_stats": stats}},
+	)
+	return err
+}
+
+// BackfillRoles gives every user created before roles existed the default role
+func (r *UserRepository) BackfillRoles(ctx context.Context, role string) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"role": bson.M{"$exists": false}},
+			bson.M{"role": ""},
+		}},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ScheduleDeletion marks the account for deletion at the given time
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"deletion_scheduled_at": at}},
+	)
+	return err
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (r *UserRepository) CancelDeletion(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "deletion_scheduled_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deletion_scheduled_at": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// FindDueForDeletion returns the IDs of accounts whose deletion grace period has ended
+func (r *UserRepository) FindDueForDeletion(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"deletion_scheduled_at": bson.M{"$lte": now}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+// DeleteUser removes the user document, including the embedded movie rankings
+func (r *UserRepository) DeleteUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// ClaimGuest marks a guest as being merged and returns it, or nil if there is no such guest
+// or another merge has already claimed it
+func (r *UserRepository) ClaimGuest(ctx context.Context, guestID primitive.ObjectID, at time.Time) (*models.User, error) {
+	var guest models.User
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": guestID, "role": models.RoleGuest, "merging": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"merging": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&guest)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &guest, nil
+}
+
+// ReleaseGuest lets a claimed guest be merged again
+func (r *UserRepository) ReleaseGuest(ctx context.Context, guestID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": guestID, "role": models.RoleGuest},
+		bson.M{"$unset": bson.M{"merging": ""}},
+	)
+	return err
+}
+
+// FindExpiredGuests returns the IDs of guest accounts past their expiry
+func (r *UserRepository) FindExpiredGuests(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"role": models.RoleGuest, "guest_expires_at": bson.M{"$lte": now}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+// BackfillPrivacy gives users created before privacy settings existed the defaults
+func (r *UserRepository) BackfillPrivacy(ctx context.Context, privacy models.PrivacySettings) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"privacy": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"privacy": privacy}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// rankedUserProjection keeps the public profile and only the movies a user has battled
+var rankedUserProjection = bson.M{
+	"display_name":    1,
+	"avatar_url":      1,
+	"role":            1,
+	"disabled":        1,
+	"privacy":         1,
+	"favorite_genres": 1,
+	"movie_rankings": bson.M{"$filter": bson.M{
+		"input": "$movie_rankings",
+		"as":    "ranking",
+		"cond":  bson.M{"$gt": bson.A{"$$ranking.match_count", 0}},
+	}},
+}
+
+// FindRankedUser returns a user's public profile and the rankings of the movies they have
+// battled, or nil if the user doesn't exist
+func (r *UserRepository) FindRankedUser(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(rankedUserProjection),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindRankedUsers returns the users with the given IDs, projected like FindRankedUser
+func (r *UserRepository) FindRankedUsers(ctx context.Context, userIDs []primitive.ObjectID) ([]models.User, error) {
+	return r.findUsers(ctx, userIDs, rankedUserProjection)
+}
+
+// FindPublicProfiles returns the display name and avatar of the users with the given IDs
+func (r *UserRepository) FindPublicProfiles(ctx context.Context, userIDs []primitive.ObjectID) ([]models.User, error) {
+	return r.findUsers(ctx, userIDs, bson.M{"display_name": 1, "avatar_url": 1})
+}
+
+func (r *UserRepository) findUsers(ctx context.Context, userIDs []primitive.ObjectID, projection bson.M) ([]models.User, error) {
+	users := []models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, fmt.Errorf("error finding users: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("error decoding users: %v", err)
+	}
+	return users, nil
+}
+
+// ForEachDiscoverableUser calls fn with every active, discoverable user whose rankings are
+// public, projected like FindRankedUser
+func (r *UserRepository) ForEachDiscoverableUser(ctx context.Context, fn func(user *models.User)) error {
+	return r.forEachRankedUser(ctx, bson.M{
+		"privacy.discoverable":        true,
+		"privacy.rankings_visibility": bson.M{"$in": bson.A{models.VisibilityPublic, ""}},
+		"role":                        bson.M{"$ne": models.RoleGuest},
+		"disabled":                    bson.M{"$ne": true},
+		"deletion_scheduled_at":       bson.M{"$exists": false},
+	}, fn)
+}
+
+// ForEachRankedUser calls fn with every user that isn't disabled, projected like
+// FindRankedUser. Used for community-wide models that don't expose individual rankings.
+func (r *UserRepository) ForEachRankedUser(ctx context.Context, fn func(user *models.User)) error {
+	return r.forEachRankedUser(ctx, bson.M{"disabled": bson.M{"$ne": true}}, fn)
+}
+
+func (r *UserRepository) forEachRankedUser(ctx context.Context, filter bson.M, fn func(user *models.User)) error {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(rankedUserProjection))
+	if err != nil {
+		return fmt.Errorf("error finding users: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return fmt.Errorf("error decoding user: %v", err)
+		}
+		fn(&user)
+	}
+	return cursor.Err()
+}
+
+// EnsureIndexes creates the indexes the users collection relies on
+func (r *UserRepository) EnsureIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Federated users may not have an email, so only non-empty emails must be unique
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}})},
+		{Keys: bson.D{{Key: "movie_rankings.movie_title", Value: 1}}},
+		{Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}},
+		{Keys: bson.D{{Key: "email_verification_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "deletion_scheduled_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "guest_expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -31,12 +33,16 @@ func InitializeMovieRankings() ([]models.MovieRanking, error) {
 		return nil, err
 	}
 
-	// Find the index of the title column
-	titleIndex := -1
+	// Find the index of the title, genre and year columns
+	titleIndex, genreIndex, yearIndex := -1, -1, -1
 	for i, column := range header {
-		if column == "Title" {
+		switch column {
+		case "Title":
 			titleIndex = i
-			break
+		case "Genre":
+			genreIndex = i
+		case "Year":
+			yearIndex = i
 		}
 	}
 	if titleIndex == -1 {
@@ -66,9 +72,102 @@ func InitializeMovieRankings() ([]models.MovieRanking, error) {
 			LossCount:   0,    // Initial loss count
 			LastUpdated: now,  // Current time
 		}
+		if genreIndex != -1 && genreIndex < len(row) {
+			ranking.Genres = NormalizeGenres(row[genreIndex])
+		}
+		if yearIndex != -1 && yearIndex < len(row) {
+			ranking.Year = ParseYear(row[yearIndex])
+		}
 
 		rankings = append(rankings, ranking)
 	}
 
 	return rankings, nil
 }
+
+// NormalizeGenres splits a comma separated genre string as found in OMDB and the CSV
+// ("Action, Adventure,Sci-Fi") into trimmed, de-duplicated genre names
+func NormalizeGenres(genre string) []string {
+	var genres []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(genre, ",") {
+		name := strings.TrimSpace(part)
+		key := strings.ToLower(name)
+		if name == "" || key == "n/a" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		genres = append(genres, name)
+	}
+	return genres
+}
+
+// ParseYear returns the release year from values such as "2014" or OMDB's "2011–2019",
+// or 0 when none can be read
+func ParseYear(year string) int {
+	year = strings.TrimSpace(year)
+	if len(year) < 4 {
+		return 0
+	}
+	value, err := strconv.Atoi(year[:4])
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// LoadCatalogFromCSV reads the IMDB-Movie-Data.csv file and returns the movies it
+// describes, used to seed the movies catalog collection
+func LoadCatalogFromCSV() ([]models.Movie, error) {
+	file, err := os.Open("IMDB-Movie-Data.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map column names to their index so the column order doesn't matter
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[column] = i
+	}
+	if _, ok := columns["Title"]; !ok {
+		return nil, errors.New("title column not found in CSV")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var movies []models.Movie
+	for {
+		row, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		movies = append(movies, models.Movie{
+			Title:      field(row, "Title"),
+			Year:       field(row, "Year"),
+			Plot:       field(row, "Description"),
+			Director:   field(row, "Director"),
+			Genre:      field(row, "Genre"),
+			Actors:     field(row, "Actors"),
+			IMDBRating: field(row, "Rating"),
+		})
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,147 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"movie-vs-backend/models"
+)
+
+var ErrUnrecognizedExport = errors.New("file is not a recognised export")
+
+// ParseLetterboxdCSV reads the movies of a Letterboxd export file. ratings.csv, diary.csv
+// and watched.csv are supported; star ratings (0.5-5) are doubled onto the 1-10 scale.
+func ParseLetterboxdCSV(r io.Reader) ([]models.ImportRow, error) {
+	return parseExportCSV(r, "Name", func(row *models.ImportRow, field func(string) string) {
+		row.Year = ParseYear(field("Year"))
+		if stars, err := strconv.ParseFloat(field("Rating"), 64); err == nil && stars > 0 {
+			row.Rating = stars * 2
+		}
+	})
+}
+
+// ParseIMDbCSV reads the titles of an IMDb ratings or watchlist export
+func ParseIMDbCSV(r io.Reader) ([]models.ImportRow, error) {
+	return parseExportCSV(r, "Title", func(row *models.ImportRow, field func(string) string) {
+		row.Year = ParseYear(field("Year"))
+		row.IMDBID = field("Const")
+		row.Type = field("Title Type")
+		if rating, err := strconv.ParseFloat(field("Your Rating"), 64); err == nil && rating > 0 {
+			row.Rating = rating
+		}
+	})
+}
+
+// parseExportCSV reads a CSV file with a header, looking columns up by name so that
+// column order and extra columns don't matter
+func parseExportCSV(r io.Reader, titleColumn string, fill func(row *models.ImportRow, field func(string) string)) ([]models.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrUnrecognizedExport
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, column := range header {
+		// Exports may start with a UTF-8 byte order mark
+		columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	if _, ok := columns[titleColumn]; !ok {
+		return nil, ErrUnrecognizedExport
+	}
+
+	var rows []models.ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := models.ImportRow{Line: line, Title: field(titleColumn)}
+		fill(&row, field)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// NormalizeTitle reduces a title to lower case letters, digits and single spaces, with
+// "&" spelled out and a leading article dropped, so that titles written slightly
+// differently compare equal
+func NormalizeTitle(title string) string {
+	title = strings.ReplaceAll(strings.ToLower(title), "&", " and ")
+
+	var b strings.Builder
+	for _, r := range title {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’' || r == '.':
+			// Dropped so that "Schindler's" and "Schindlers", "Mr." and "Mr" compare equal
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// TitleSimilarity returns how alike two normalised titles are, from 0 to 1, based on
+// their edit distance. Swapped adjacent letters count as one edit, as typos often do.
+func TitleSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	// Optimal string alignment distance, keeping the last three rows
+	beforePrevious := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
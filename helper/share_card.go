@@ -0,0 +1,75 @@
+package helper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// Share card layout, sized for Open Graph link previews
+const (
+	shareCardWidth  = 1200
+	shareCardHeight = 630
+	posterWidth     = 200
+	posterHeight    = 300
+	posterGap       = 30
+)
+
+var (
+	shareCardBackground = color.RGBA{R: 20, G: 20, B: 28, A: 255}
+	posterPlaceholder   = color.RGBA{R: 60, G: 60, B: 72, A: 255}
+	rankBarColor        = color.RGBA{R: 245, G: 197, B: 24, A: 255}
+)
+
+// RenderShareCard draws up to five posters side by side, best ranked on the left, with a
+// bar under each whose length shows its rank. Missing posters leave a placeholder.
+func RenderShareCard(posters []image.Image) ([]byte, error) {
+	card := image.NewRGBA(image.Rect(0, 0, shareCardWidth, shareCardHeight))
+	draw.Draw(card, card.Bounds(), &image.Uniform{C: shareCardBackground}, image.Point{}, draw.Src)
+
+	if len(posters) > 5 {
+		posters = posters[:5]
+	}
+
+	rowWidth := len(posters)*posterWidth + (len(posters)-1)*posterGap
+	left := (shareCardWidth - rowWidth) / 2
+	top := (shareCardHeight - posterHeight) / 2
+
+	for i, poster := range posters {
+		x := left + i*(posterWidth+posterGap)
+		slot := image.Rect(x, top, x+posterWidth, top+posterHeight)
+		if poster == nil {
+			draw.Draw(card, slot, &image.Uniform{C: posterPlaceholder}, image.Point{}, draw.Src)
+		} else {
+			scaleInto(card, slot, poster)
+		}
+
+		// First place gets the full width bar, fifth place a fifth of it
+		barWidth := posterWidth * (5 - i) / 5
+		bar := image.Rect(x, top+posterHeight+20, x+barWidth, top+posterHeight+32)
+		draw.Draw(card, bar, &image.Uniform{C: rankBarColor}, image.Point{}, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, card); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleInto draws src stretched over dst's rect using nearest neighbour sampling
+func scaleInto(dst draw.Image, rect image.Rectangle, src image.Image) {
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		sy := bounds.Min.Y + (y-rect.Min.Y)*bounds.Dy()/rect.Dy()
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			sx := bounds.Min.X + (x-rect.Min.X)*bounds.Dx()/rect.Dx()
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 test secret "12345678901234567890", whose SHA1 codes at 59s and 1111111109s are
+// 94287082 and 07081804 (the last six digits are used here)
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     int64
+		want   int64
+	}{
+		{name: "current step", secret: rfcSecret, code: "287082", at: 59, want: 1},
+		{name: "first second of the step", secret: rfcSecret, code: "287082", at: 30, want: 1},
+		{name: "one step late", secret: rfcSecret, code: "287082", at: 89, want: 1},
+		{name: "one step early", secret: rfcSecret, code: "287082", at: 29, want: 1},
+		{name: "two steps late", secret: rfcSecret, code: "287082", at: 90, want: -1},
+		{name: "two steps early", secret: rfcSecret, code: "081804", at: 1111111109 - 60, want: -1},
+		{name: "leading zero", secret: rfcSecret, code: "081804", at: 1111111109, want: 37037036},
+		{name: "spaces are ignored", secret: rfcSecret, code: "287 082", at: 59, want: 1},
+		{name: "lowercase padded secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", code: "287082", at: 59, want: 1},
+		{name: "wrong code", secret: rfcSecret, code: "287083", at: 59, want: -1},
+		{name: "too short", secret: rfcSecret, code: "28708", at: 59, want: -1},
+		{name: "invalid secret", secret: "not base32!", code: "287082", at: 59, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.at, 0)); got != tt.want {
+				t.Errorf("ValidateTOTP(%q, %d) = %d, want %d", tt.code, tt.at, got, tt.want)
+			}
+		})
+	}
+}
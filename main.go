@@ -8,9 +8,11 @@ import (
 	"movie-vs-backend/controllers"
 	"movie-vs-backend/data_access"
 	"movie-vs-backend/middleware"
+	"movie-vs-backend/models"
 	"movie-vs-backend/services"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -23,7 +25,7 @@ func setupCORS() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -59,17 +61,95 @@ func main() {
 	userRepo := data_access.NewUserRepository(mongodb)
 	movieRepo := data_access.NewMovieRepository(mongodb)
 	battleRepo := data_access.NewBattleRepository(mongodb)
+	signingKeyRepo := data_access.NewSigningKeyRepository(mongodb)
+	auditRepo := data_access.NewAuditRepository(mongodb)
+	leaderboardRepo := data_access.NewLeaderboardRepository(mongodb)
+	followRepo := data_access.NewFollowRepository(mongodb)
+	shareRepo := data_access.NewShareRepository(mongodb)
+	tournamentRepo := data_access.NewTournamentRepository(mongodb)
+	challengeRepo := data_access.NewChallengeRepository(mongodb)
+	achievementRepo := data_access.NewAchievementRepository(mongodb)
+	mfaAttemptRepo := data_access.NewMFAAttemptRepository(mongodb)
+	oidcLoginRepo := data_access.NewOIDCLoginRepository(mongodb)
 
-	// Set JWT secret for middleware
-	middleware.SetJWTSecret(cfg.JWTSecret)
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Load token signing keys, refusing to start without one
+	keyService, err := services.NewKeyService(signingKeyRepo, cfg.JWTSigningAlgorithm, cfg.JWTKeyRotationInterval, cfg.JWTKeyVerifyGrace, cfg.JWTKeyEncryptionKey)
+	if err != nil {
+		log.Fatal("Failed to set up JWT signing keys:", err)
+	}
+	if err := keyService.Init(context.Background(), cfg.JWTPrivateKeyFile); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+	keyService.StartRotation(jobsCtx)
+	middleware.SetKeyFunc(keyService.Keyfunc)
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, cfg.JWTSecret)
-	gameService := services.NewGameService(cfg.MovieAPIKey, cfg.MovieAPIBaseURL, movieRepo, battleRepo, userRepo)
+	guestService := services.NewGuestService(userRepo, battleRepo, keyService, cfg.GuestSessionTTL)
+	guestService.StartPurge(jobsCtx, time.Hour)
+	authService := services.NewAuthService(userRepo, mfaAttemptRepo, keyService, guestService, cfg.AdminEmails)
+	middleware.SetRoleFunc(authService.CurrentRole)
+	achievementService := services.NewAchievementService(achievementRepo, battleRepo, userRepo)
+	gameService := services.NewGameService(cfg.MovieAPIKey, cfg.MovieAPIBaseURL, movieRepo, battleRepo, userRepo, achievementService)
+	adminService := services.NewAdminService(userRepo, movieRepo, battleRepo, signingKeyRepo, auditRepo, leaderboardRepo, followRepo, shareRepo, tournamentRepo, challengeRepo, achievementRepo, mfaAttemptRepo, oidcLoginRepo)
+	// Vote, follow and achievement dedupe rely on unique indexes, so create them before serving
+	indexCtx, cancelIndexes := context.WithTimeout(context.Background(), 2*time.Minute)
+	if _, err := adminService.Reindex(indexCtx); err != nil {
+		log.Fatal("Failed to create MongoDB indexes:", err)
+	}
+	cancelIndexes()
+	mailer := data_access.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	profileService := services.NewProfileService(userRepo, mailer, cfg.AppBaseURL)
+	accountService := services.NewAccountService(userRepo, battleRepo, followRepo, shareRepo, tournamentRepo, challengeRepo, achievementRepo, auditRepo, cfg.AccountDeletionGrace)
+	accountService.StartPurge(jobsCtx, time.Hour)
+	leaderboardService := services.NewLeaderboardService(battleRepo, leaderboardRepo)
+	leaderboardService.StartRefresh(jobsCtx, cfg.LeaderboardRefreshInterval)
+	compatibilityService := services.NewCompatibilityService(userRepo, battleRepo, followRepo)
+	recommendationService := services.NewRecommendationService(userRepo, movieRepo)
+	recommendationService.StartRefresh(jobsCtx, cfg.RecommendationRefreshInterval)
+	socialService := services.NewSocialService(userRepo, followRepo)
+	omdbClient := data_access.NewOMDBClient(cfg.MovieAPIKey, cfg.MovieAPIBaseURL)
+	shareService := services.NewShareService(userRepo, battleRepo, movieRepo, shareRepo, omdbClient, cfg.PublicBaseURL)
+	roomService := services.NewRoomService(gameService, userRepo)
+	roomService.StartJanitor(jobsCtx, time.Minute)
+	tournamentService := services.NewTournamentService(tournamentRepo, battleRepo, gameService)
+	importService := services.NewImportService(movieRepo, battleRepo)
+	movieStatsService := services.NewMovieStatsService(userRepo, movieRepo, battleRepo, leaderboardRepo)
+	dashboardService := services.NewDashboardService(movieRepo, battleRepo)
+	challengeService := services.NewChallengeService(challengeRepo, movieRepo, cfg.DailyChallengePairs)
+
+	var oidcClients []*data_access.OIDCClient
+	for _, provider := range cfg.OIDCProviders {
+		oidcClients = append(oidcClients, data_access.NewOIDCClient(
+			provider.Name, provider.Issuer, provider.ClientID, provider.ClientSecret, provider.RedirectURL, provider.Scopes,
+		))
+	}
+	oidcService := services.NewOIDCService(oidcClients, userRepo, oidcLoginRepo, authService)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
 	gameController := controllers.NewGameController(gameService)
+	adminController := controllers.NewAdminController(adminService)
+	oidcController := controllers.NewOIDCController(oidcService, cfg.OIDCPostLoginRedirect)
+	keyController := controllers.NewKeyController(keyService)
+	profileController := controllers.NewProfileController(profileService)
+	accountController := controllers.NewAccountController(accountService)
+	guestController := controllers.NewGuestController(guestService)
+	leaderboardController := controllers.NewLeaderboardController(leaderboardService, cfg.LeaderboardMinMatches)
+	compatibilityController := controllers.NewCompatibilityController(compatibilityService)
+	recommendationController := controllers.NewRecommendationController(recommendationService)
+	socialController := controllers.NewSocialController(socialService)
+	shareController := controllers.NewShareController(shareService)
+	roomController := controllers.NewRoomController(roomService)
+	tournamentController := controllers.NewTournamentController(tournamentService)
+	challengeController := controllers.NewChallengeController(challengeService)
+	movieStatsController := controllers.NewMovieStatsController(movieStatsService)
+	importController := controllers.NewImportController(importService)
+	achievementController := controllers.NewAchievementController(achievementService)
+	dashboardController := controllers.NewDashboardController(dashboardService)
 
 	// Setup Gin router
 	r := gin.Default()
@@ -80,20 +160,123 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// Public keys for verifying our access tokens
+	r.GET("/.well-known/jwks.json", keyController.JWKS)
+
+	// Published share snapshots, public by their unguessable token
+	r.GET("/s/:token", shareController.GetShare)
+	r.GET("/s/:token/card.png", shareController.GetCard)
+
 	// Public routes
 	api := r.Group("/api")
 	{
 		api.POST("/register", authController.Register)
 		api.POST("/login", authController.Login)
+		api.POST("/login/mfa", authController.LoginMFA)
+		api.POST("/email/verify", profileController.VerifyEmail)
+		api.POST("/guest", middleware.RateLimit(cfg.GuestCreateLimit, time.Hour), guestController.CreateGuest)
 		api.POST("/logout", authController.Logout)
 
-		// Protected routes
+		// OpenID Connect login
+		api.GET("/auth/oidc", oidcController.ListProviders)
+		api.GET("/auth/oidc/:provider/login", oidcController.Login)
+		api.GET("/auth/oidc/:provider/callback", oidcController.Callback)
+
+		// Group battle rooms. Browsers cannot set headers on a WebSocket handshake, so the
+		// connection is authenticated with a ticket from POST /api/rooms/:code/ticket.
+		api.GET("/rooms/:code/ws", roomController.Connect)
+
+		// Battle routes, open to guests
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware())
 		{
 			protected.GET("/battle", gameController.GetMovieBattlePair)
 			protected.GET("/topmovies", gameController.GetTopTwentyList)
+			protected.GET("/rankings", gameController.GetRankings)
+			protected.GET("/rankings/genres", gameController.GetBestByGenre)
+			protected.GET("/rankings/decades", gameController.GetBestByDecade)
+			protected.GET("/rankings/history", gameController.GetRatingHistory)
 			protected.POST("/battle", gameController.SubmitBattleWinner)
+			protected.POST("/battle/undo", gameController.UndoBattles)
+			protected.GET("/battle/round", gameController.GetRound)
+			protected.POST("/battle/round", gameController.SubmitRound)
+		}
+
+		// Routes for registered users only
+		members := protected.Group("")
+		members.Use(middleware.RequireRole(models.RoleUser, models.RoleAdmin))
+		{
+			members.GET("/leaderboard", leaderboardController.GetLeaderboard)
+			members.GET("/movies/:id/stats", movieStatsController.GetStats)
+			members.GET("/users/similar", compatibilityController.GetSimilarUsers)
+			members.GET("/recommendations", recommendationController.GetRecommendations)
+			members.GET("/users/:id/compatibility", compatibilityController.GetCompatibility)
+			members.GET("/users/:id/top", socialController.GetTopList)
+			members.POST("/rooms", roomController.CreateRoom)
+			members.GET("/rooms/:code", roomController.GetRoom)
+			members.POST("/rooms/:code/ticket", roomController.CreateTicket)
+			members.POST("/tournaments", tournamentController.CreateTournament)
+			members.GET("/tournaments", tournamentController.ListTournaments)
+			members.GET("/tournaments/:id", tournamentController.GetTournament)
+			members.DELETE("/tournaments/:id", tournamentController.DeleteTournament)
+			members.GET("/tournaments/:id/battle", tournamentController.GetBattle)
+			members.POST("/tournaments/:id/battle", tournamentController.SubmitBattle)
+			members.GET("/challenge", challengeController.GetToday)
+			members.POST("/challenge/votes", challengeController.Vote)
+			members.GET("/challenge/history", challengeController.GetHistory)
+			members.GET("/achievements", achievementController.GetAchievements)
+		}
+
+		// Account routes
+		me := members.Group("/me")
+		{
+			me.GET("", profileController.GetProfile)
+			me.PATCH("", profileController.UpdateProfile)
+			me.POST("/email", profileController.ChangeEmail)
+			me.POST("/password", profileController.ChangePassword)
+			me.GET("/export", accountController.Export)
+			me.GET("/rankings/export", gameController.ExportRankings)
+			me.GET("/stats", dashboardController.GetDashboard)
+			me.DELETE("", accountController.DeleteAccount)
+			me.POST("/deletion/cancel", accountController.CancelDeletion)
+			me.POST("/mfa/enroll", authController.EnrollMFA)
+			me.POST("/mfa/confirm", authController.ConfirmMFA)
+			me.POST("/mfa/disable", authController.DisableMFA)
+			me.POST("/identities/:provider", oidcController.Link)
+			me.POST("/guest/merge", guestController.MergeGuest)
+			me.GET("/followers", socialController.ListFollowers)
+			me.DELETE("/followers/:id", socialController.RemoveFollower)
+			me.GET("/following", socialController.ListFollowing)
+			me.GET("/follow-requests", socialController.ListRequests)
+			me.POST("/follow-requests/:id/accept", socialController.AcceptRequest)
+			me.POST("/follow-requests/:id/decline", socialController.DeclineRequest)
+			me.GET("/friends/consensus", socialController.GetFriendsConsensus)
+			me.POST("/following/:id", socialController.Follow)
+			me.DELETE("/following/:id", socialController.Unfollow)
+			me.POST("/shares", shareController.CreateShare)
+			me.GET("/shares", shareController.ListShares)
+			me.DELETE("/shares/:token", shareController.DeleteShare)
+			me.POST("/import/:source", importController.Import)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+		{
+			admin.GET("/users", adminController.ListUsers)
+			admin.PUT("/users/:id/disabled", adminController.SetUserDisabled)
+			admin.PUT("/users/:id/role", adminController.SetUserRole)
+			admin.GET("/movies", adminController.ListCatalog)
+			admin.POST("/movies", adminController.AddCatalogMovie)
+			admin.DELETE("/movies/:id", adminController.DeleteCatalogMovie)
+			admin.POST("/movies/import", adminController.ImportCatalog)
+			admin.POST("/reindex", adminController.Reindex)
+			admin.GET("/migrations", adminController.ListMigrations)
+			admin.POST("/migrations/:name", adminController.RunMigration)
+			admin.POST("/keys/rotate", keyController.Rotate)
+			admin.POST("/leaderboard/refresh", leaderboardController.Refresh)
+			admin.POST("/recommendations/refresh", recommendationController.Refresh)
+			admin.POST("/recommendations/evaluate", recommendationController.Evaluate)
 		}
 	}
 
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -8,10 +9,20 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret string
+var (
+	keyFunc  jwt.Keyfunc
+	roleFunc func(ctx context.Context, userID string) (string, error)
+)
+
+// SetKeyFunc sets how the middleware finds the public key that verifies a token
+func SetKeyFunc(fn jwt.Keyfunc) {
+	keyFunc = fn
+}
 
-func SetJWTSecret(secret string) {
-	jwtSecret = secret
+// SetRoleFunc sets how the middleware looks up the current role of a token's user. An
+// empty role means the account was disabled or deleted and the token is refused.
+func SetRoleFunc(fn func(ctx context.Context, userID string) (string, error)) {
+	roleFunc = fn
 }
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -24,9 +35,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, keyFunc, jwt.WithValidMethods([]string{"RS256", "EdDSA"}))
 
 		if err != nil || !token.Valid {
 			if err.Error() == "Token is expired" {
@@ -46,7 +55,48 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims["user_id"])
+		// Challenge tokens from a two-step login must not grant access
+		if claims["typ"] != "access" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		// Roles change and accounts get disabled while their tokens are still valid
+		userID, _ := claims["user_id"].(string)
+		role, err := roleFunc(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+			c.Abort()
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", userID)
+		c.Set("role", role)
 		c.Next()
 	}
 }
+
+// RequireRole only lets requests through whose user holds one of the given roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		roleStr, _ := role.(string)
+
+		for _, allowed := range roles {
+			if roleStr == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
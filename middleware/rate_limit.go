@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateWindow counts the requests of one client in the current window
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// RateLimit lets each client IP make at most limit requests per window. Counts are kept in
+// memory, so with several instances behind a load balancer the limit applies per instance.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	clients := make(map[string]*rateWindow)
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		client, ok := clients[ip]
+		if !ok || now.After(client.resetAt) {
+			// Drop finished windows so the map doesn't grow forever
+			for key, entry := range clients {
+				if now.After(entry.resetAt) {
+					delete(clients, key)
+				}
+			}
+			client = &rateWindow{resetAt: now.Add(window)}
+			clients[ip] = client
+		}
+		client.count++
+		allowed := client.count <= limit
+		retryAfter := client.resetAt.Sub(now)
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type DeleteAccountResponse struct {
+	Message     string    `json:"message"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+// DataExport is the profile part of a personal data export
+type DataExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Profile    *ProfileResponse `json:"profile"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UnlockedAchievement records when a user earned an achievement
+type UnlockedAchievement struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"-"`
+	AchievementID string             `bson:"achievement_id" json:"achievement_id"`
+	UnlockedAt    time.Time          `bson:"unlocked_at" json:"unlocked_at"`
+}
+
+// AchievementProgress is an achievement with how far a user has come towards it
+type AchievementProgress struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Progress    int        `json:"progress"` // Capped at the target once unlocked
+	Target      int        `json:"target"`
+	Unlocked    bool       `json:"unlocked"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// BattleStats are a user's running battle totals. They are updated with every submission
+// so achievements can be checked without reading the battle history.
+type BattleStats struct {
+	Battles       int    `bson:"battles"`
+	BattledMovies int    `bson:"battled_movies"`
+	LastDay       string `bson:"last_day,omitempty"` // UTC day of the latest battle, e.g. "2026-10-18"
+	CurrentStreak int    `bson:"current_streak"`     // Consecutive days with a battle up to LastDay
+	LongestStreak int    `bson:"longest_streak"`
+}
+
+// GenreCoverage is how many of the movies of a genre in a user's list they have battled
+type GenreCoverage struct {
+	Genre   string `bson:"_id"`
+	Movies  int    `bson:"movies"`
+	Battled int    `bson:"battled"`
+}
+
+// BattleDay is the number of battles a user played on one UTC day
+type BattleDay struct {
+	Date    string `bson:"_id" json:"date"` // e.g. "2026-10-18"
+	Battles int    `bson:"battles" json:"battles"`
+}
+
+type AchievementsResponse struct {
+	Earned        []AchievementProgress `json:"earned"` // Most recent first
+	InProgress    []AchievementProgress `json:"in_progress"`
+	Battles       int                   `json:"battles"`
+	CurrentStreak int                   `json:"current_streak"` // Consecutive days with a battle, up to today or yesterday
+	LongestStreak int                   `json:"longest_streak"`
+}
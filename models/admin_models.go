@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserSummary is the admin view of a user, without the embedded movie rankings
+type UserSummary struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Email     string             `bson:"email" json:"email"`
+	Role      string             `bson:"role" json:"role"`
+	Disabled  bool               `bson:"disabled" json:"disabled"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	LastLogin time.Time          `bson:"last_login" json:"last_login"`
+}
+
+type UserListResponse struct {
+	Users    []UserSummary `json:"users"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+type SetUserDisabledRequest struct {
+	Disabled *bool `json:"disabled" binding:"required"`
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type CatalogMovieRequest struct {
+	Title      string `json:"title" binding:"required"`
+	Year       string `json:"year"`
+	PosterURL  string `json:"poster_url"`
+	Plot       string `json:"plot"`
+	Director   string `json:"director"`
+	Genre      string `json:"genre"`
+	Actors     string `json:"actors"`
+	IMDBRating string `json:"imdb_rating"`
+	IMDBID     string `json:"imdb_id"`
+}
+
+// MigrationResult reports what a named migration changed
+type MigrationResult struct {
+	Name     string    `json:"name"`
+	Modified int64     `json:"modified"`
+	RanAt    time.Time `json:"ran_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditAccountDeletionRequested = "account_deletion_requested"
+	AuditAccountDeletionCancelled = "account_deletion_cancelled"
+	AuditAccountDeleted           = "account_deleted"
+	AuditDataExported             = "data_exported"
+)
+
+// AuditRecord is an append-only log entry for actions on personal data. It outlives the
+// account it refers to, so it only keeps the user ID and never personal details.
+type AuditRecord struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action    string             `bson:"action" json:"action"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Details   string             `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -1,11 +1,52 @@
 package models
 
+import "time"
+
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	GuestToken string `json:"guest_token"` // Optional, carries a guest's rankings into the account
 }
 
 type RegisterRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	GuestToken string `json:"guest_token"` // Optional, carries a guest's rankings into the account
+}
+
+// LoginResponse carries either the access token or, for accounts with two-factor
+// authentication, a short-lived challenge token to exchange at /api/login/mfa
+type LoginResponse struct {
+	Token       string `json:"token,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" binding:"required"`
+	Code       string `json:"code" binding:"required"`
+	GuestToken string `json:"guest_token"`
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFACodeRequest confirms enrolment or disables 2FA with a current code (or a backup code)
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFABackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+type GuestResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MergeGuestRequest struct {
+	GuestToken string `json:"guest_token" binding:"required"`
 }
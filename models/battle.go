@@ -8,8 +8,43 @@ import (
 
 type Battle struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"-"` // Unset once the user's account is deleted
 	MovieA    Movie              `bson:"movie_a" json:"movie_a"`
 	MovieB    Movie              `bson:"movie_b" json:"movie_b"`
 	Winner    Movie              `bson:"winner" json:"winner"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	// Shared by the battles recorded from one submission, e.g. the pairs of a multi-way round
+	SubmissionID primitive.ObjectID `bson:"submission_id,omitempty" json:"submission_id,omitempty"`
+	// Rankings of both movies before and after the submission, so it can be undone
+	Before   []RankingSnapshot `bson:"before,omitempty" json:"-"`
+	After    []RankingSnapshot `bson:"after,omitempty" json:"-"`
+	Undone   bool              `bson:"undone,omitempty" json:"undone,omitempty"` // Undone battles no longer count anywhere
+	UndoneAt *time.Time        `bson:"undone_at,omitempty" json:"undone_at,omitempty"`
+	// Where the battle was played when not on the battle screen, e.g. "tournament"
+	Source string `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// Sources of battles played in a tournament or room. Their results also live in the
+// bracket or room, so they can't be undone.
+const (
+	BattleSourceTournament = "tournament"
+	BattleSourceRoom       = "room"
+)
+
+// RankingSnapshot is a copy of a movie ranking at one point in time
+type RankingSnapshot struct {
+	MovieID     primitive.ObjectID `bson:"movie_id" json:"movie_id"`
+	MovieTitle  string             `bson:"movie_title" json:"movie_title"`
+	ELORating   int                `bson:"elo_rating" json:"elo_rating"`
+	MatchCount  int                `bson:"match_count" json:"match_count"`
+	WinCount    int                `bson:"win_count" json:"win_count"`
+	LossCount   int                `bson:"loss_count" json:"loss_count"`
+	LastUpdated time.Time          `bson:"last_updated" json:"last_updated"`
+	New         bool               `bson:"new,omitempty" json:"new,omitempty"` // The ranking didn't exist before
+}
+
+type UndoResponse struct {
+	Submissions int            `json:"submissions"` // Submissions undone
+	Battles     int            `json:"battles"`
+	Rankings    []MovieRanking `json:"rankings"` // Rankings as restored; removed ones are left out
 }
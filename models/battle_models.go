@@ -21,7 +21,39 @@ type TopTwentyResponse struct {
 }
 
 type UserBattleState struct {
-	UserID       primitive.ObjectID
-	BattleCount  int
-	LastUpdated  time.Time
+	UserID      primitive.ObjectID
+	BattleCount int
+	LastUpdated time.Time
+}
+
+// Number of movies served in a multi-way round
+const (
+	MinRoundSize = 3
+	MaxRoundSize = 5
+)
+
+// RoundResponse is a multi-way round: the user orders the movies or picks a favourite
+type RoundResponse struct {
+	Movies []Movie `json:"movies"`
+}
+
+// SubmitRoundRequest carries either a full order of the round's movies, best first, or
+// just the favourite. It is rated as the pairwise results it implies.
+type SubmitRoundRequest struct {
+	Movies   []Movie  `json:"movies" binding:"required,min=3,max=5"`
+	Order    []string `json:"order"`    // Every movie title, best first
+	Favorite string   `json:"favorite"` // Title of the best movie, when not ordering
+}
+
+// PairwiseResult is one battle a multi-way round translates into
+type PairwiseResult struct {
+	Winner string `json:"winner"`
+	Loser  string `json:"loser"`
+}
+
+type SubmitRoundResponse struct {
+	SubmissionID primitive.ObjectID    `json:"submission_id"`
+	Results      []PairwiseResult      `json:"results"`
+	Rankings     []MovieRanking        `json:"rankings"`               // The round's movies after rating, in the order submitted
+	Achievements []AchievementProgress `json:"achievements,omitempty"` // Unlocked by this round
 }
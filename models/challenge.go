@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DailyChallenge is the set of matchups every user plays on a given day
+type DailyChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Date      string             `bson:"date" json:"date"` // UTC day, e.g. "2026-10-18"
+	Pairs     []ChallengePair    `bson:"pairs" json:"pairs"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ChallengePair is one matchup of a daily challenge with the community's votes on it
+type ChallengePair struct {
+	Index  int   `bson:"index" json:"index"` // From 1
+	MovieA Movie `bson:"movie_a" json:"movie_a"`
+	MovieB Movie `bson:"movie_b" json:"movie_b"`
+	VotesA int   `bson:"votes_a" json:"votes_a"`
+	VotesB int   `bson:"votes_b" json:"votes_b"`
+}
+
+// ChallengeVote is a user's pick on one pair of a daily challenge
+type ChallengeVote struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"-"`
+	Date        string             `bson:"date" json:"date"`
+	Pair        int                `bson:"pair" json:"pair"`
+	WinnerTitle string             `bson:"winner_title" json:"winner_title"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type ChallengeVoteRequest struct {
+	Pair        int    `json:"pair" binding:"required,min=1"`
+	WinnerTitle string `json:"winner_title" binding:"required"`
+}
+
+// DailyChallengeResponse is a challenge as seen by one user
+type DailyChallengeResponse struct {
+	Date      string                `json:"date"`
+	Pairs     []ChallengePairResult `json:"pairs"`
+	Completed bool                  `json:"completed"` // The user has picked a side in every pair
+}
+
+// ChallengePairResult is a pair with the user's pick and, once picked or for past days,
+// the community's split
+type ChallengePairResult struct {
+	Index   int               `json:"index"`
+	MovieA  Movie             `json:"movie_a"`
+	MovieB  Movie             `json:"movie_b"`
+	Pick    string            `json:"pick,omitempty"`
+	Results *ChallengeResults `json:"results,omitempty"`
+}
+
+type ChallengeResults struct {
+	VotesA   int     `json:"votes_a"`
+	VotesB   int     `json:"votes_b"`
+	Total    int     `json:"total"`
+	PercentA float64 `json:"percent_a"`
+	PercentB float64 `json:"percent_b"`
+}
+
+type ChallengeHistoryResponse struct {
+	Challenges []DailyChallengeResponse `json:"challenges"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	PageSize   int                      `json:"page_size"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// CompatibilityResponse describes how closely two users' movie tastes match
+type CompatibilityResponse struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	DisplayName string             `json:"display_name"`
+	// Score from 0 to 100 combining rank correlation and head-to-head agreement.
+	// Nil when the users have too little in common to compare.
+	Score *float64 `json:"score"`
+	// Spearman rank correlation of the ELO ratings of movies both have battled, -1 to 1
+	RankCorrelation *float64 `json:"rank_correlation"`
+	SharedMovies    int      `json:"shared_movies"`
+	// Share of the movie pairs both have battled where they picked the same winner, 0 to 1
+	HeadToHeadAgreement *float64 `json:"head_to_head_agreement"`
+	SharedHeadToHeads   int      `json:"shared_head_to_heads"`
+}
+
+// SimilarUser is an entry in the "most similar users" list
+type SimilarUser struct {
+	UserID          primitive.ObjectID `json:"user_id"`
+	DisplayName     string             `json:"display_name"`
+	AvatarURL       string             `json:"avatar_url"`
+	Score           float64            `json:"score"`
+	RankCorrelation float64            `json:"rank_correlation"`
+	SharedMovies    int                `json:"shared_movies"`
+}
+
+type SimilarUsersResponse struct {
+	Users []SimilarUser `json:"users"`
+}
+
+// HeadToHead is a user's latest decision between two movies, Low and High being the
+// titles of the pair in lexical order
+type HeadToHead struct {
+	Low    string `bson:"low"`
+	High   string `bson:"high"`
+	Winner string `bson:"winner"`
+}
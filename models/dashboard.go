@@ -0,0 +1,44 @@
+package models
+
+// DashboardResponse summarises a user's activity and taste
+type DashboardResponse struct {
+	Battles             int         `json:"battles"`
+	ActiveDays          int         `json:"active_days"`            // Days with at least one battle
+	BattlesPerDay       float64     `json:"battles_per_day"`        // Averaged over every day since the first battle
+	BattlesPerActiveDay float64     `json:"battles_per_active_day"` // Averaged over the days with a battle
+	RecentDays          []BattleDay `json:"recent_days"`            // The last days up to today, including days without battles
+	Coverage            Coverage    `json:"coverage"`
+
+	FavoriteGenres    []Affinity `json:"favorite_genres"`
+	FavoriteDirectors []Affinity `json:"favorite_directors"`
+	FavoriteActors    []Affinity `json:"favorite_actors"`
+
+	// Movies whose wins and losses are closest to even
+	Controversial []MovieRanking `json:"controversial"`
+	// How much ratings moved per week, oldest first. Smaller changes mean a settled ranking.
+	Stability []RatingMovement `json:"stability"`
+}
+
+// Coverage is how much of the catalog a user has battled or marked as seen
+type Coverage struct {
+	CatalogMovies int64   `json:"catalog_movies"`
+	BattledMovies int     `json:"battled_movies"`
+	SeenMovies    int     `json:"seen_movies"` // Battled or marked as seen by an import
+	Percent       float64 `json:"percent"`     // Battled movies as a share of the catalog
+}
+
+// Affinity is how a genre, director or actor fares in a user's battles
+type Affinity struct {
+	Name    string  `json:"name"`
+	Wins    int     `json:"wins"`
+	Matches int     `json:"matches"`
+	WinRate float64 `json:"win_rate"`
+	Movies  int     `json:"movies"`
+}
+
+// RatingMovement is the average ELO change of a movie in a user's battles during one ISO week
+type RatingMovement struct {
+	Week          string  `bson:"_id" json:"week"` // e.g. "2026-W42"
+	Battles       int     `bson:"battles" json:"battles"`
+	AverageChange float64 `bson:"average_change" json:"average_change"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Follow states. Follows of users with a public profile are accepted straight away,
+// otherwise the followed user has to accept the request.
+const (
+	FollowPending  = "pending"
+	FollowAccepted = "accepted"
+)
+
+// Follow is one edge of the follow graph, stored in the follows collection
+type Follow struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	FollowerID primitive.ObjectID `bson:"follower_id" json:"follower_id"`
+	FolloweeID primitive.ObjectID `bson:"followee_id" json:"followee_id"`
+	Status     string             `bson:"status" json:"status"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	AcceptedAt *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+}
+
+// FollowUser is a user in a followers, following or requests list
+type FollowUser struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	DisplayName string             `json:"display_name"`
+	AvatarURL   string             `json:"avatar_url"`
+	Status      string             `json:"status"`
+	Since       time.Time          `json:"since"`
+}
+
+type FollowListResponse struct {
+	Users    []FollowUser `json:"users"`
+	Total    int64        `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+type FollowResponse struct {
+	Status string `json:"status"`
+}
+
+// UserTopListResponse is another user's best movies
+type UserTopListResponse struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	DisplayName string             `json:"display_name"`
+	Movies      []MovieRanking     `json:"movies"`
+}
+
+// ConsensusMovie is a movie in the combined ranking of the people a user follows
+type ConsensusMovie struct {
+	Rank       int     `json:"rank"`
+	MovieTitle string  `json:"movie_title"`
+	Score      float64 `json:"score"` // Average of each friend's normalised preference, in standard deviations
+	AverageELO float64 `json:"average_elo"`
+	RankedBy   int     `json:"ranked_by"` // Number of friends who battled the movie
+}
+
+type FriendsConsensusResponse struct {
+	Movies  []ConsensusMovie `json:"movies"`
+	Friends int              `json:"friends"` // Friends whose rankings were included
+}
@@ -0,0 +1,45 @@
+package models
+
+// Sources of rating exports users can import
+const (
+	ImportSourceLetterboxd = "letterboxd"
+	ImportSourceIMDb       = "imdb"
+)
+
+// ImportRow is one movie read from an exported ratings or watch history file
+type ImportRow struct {
+	Line   int     `json:"line"` // Line in the file, the header is line 1
+	Title  string  `json:"title"`
+	Year   int     `json:"year,omitempty"`
+	IMDBID string  `json:"imdb_id,omitempty"`
+	Rating float64 `json:"rating,omitempty"` // On a 1-10 scale, 0 when the row has no rating
+	Type   string  `json:"-"`                // IMDb title type, e.g. "Movie" or "TV Series"
+}
+
+// ImportMatch is a row matched to a catalog movie
+type ImportMatch struct {
+	Line         int     `json:"line"`
+	Title        string  `json:"title"`
+	MatchedTitle string  `json:"matched_title"`
+	Fuzzy        bool    `json:"fuzzy"` // Matched on a similar rather than an identical title
+	Rating       float64 `json:"rating,omitempty"`
+	Seeded       bool    `json:"seeded"`     // The movie's ELO was set from the rating
+	ELORating    int     `json:"elo_rating"` // ELO after the import
+}
+
+// ImportReport describes the outcome of an import
+type ImportReport struct {
+	Source    string               `json:"source"`
+	Rows      int                  `json:"rows"`
+	Matched   int                  `json:"matched"`
+	Added     int                  `json:"added"`  // Matched movies that were not in the user's list yet
+	Seeded    int                  `json:"seeded"` // Matched movies whose ELO was set from the rating
+	Matches   []ImportMatch        `json:"matches"`
+	Unmatched []UnmatchedImportRow `json:"unmatched"`
+}
+
+// UnmatchedImportRow is a row that was not imported, with the reason why
+type UnmatchedImportRow struct {
+	ImportRow
+	Reason string `json:"reason"`
+}
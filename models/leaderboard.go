@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// GlobalLeaderboardEntry is one movie in the community leaderboard. Entries are
+// materialised periodically from every user's battles and rankings.
+type GlobalLeaderboardEntry struct {
+	MovieTitle       string    `bson:"movie_title" json:"movie_title"`
+	Rank             int       `bson:"rank" json:"rank"`                           // Position by community ELO, among the listed entries in leaderboard pages
+	CommunityELO     int       `bson:"community_elo" json:"community_elo"`         // ELO replayed over every battle of every user
+	CommunityMatches int       `bson:"community_matches" json:"community_matches"` // Battles the movie appeared in
+	CommunityWins    int       `bson:"community_wins" json:"community_wins"`
+	WinRate          float64   `bson:"win_rate" json:"win_rate"`
+	AverageRating    float64   `bson:"average_rating" json:"average_rating"` // Mean personal ELO of users who battled it
+	RankedBy         int       `bson:"ranked_by" json:"ranked_by"`           // Users who battled it at least once
+	Generation       int64     `bson:"generation" json:"-"`
+	UpdatedAt        time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type GlobalLeaderboardResponse struct {
+	Movies     []GlobalLeaderboardEntry `json:"movies"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	PageSize   int                      `json:"page_size"`
+	MinMatches int                      `json:"min_matches"`
+	UpdatedAt  time.Time                `json:"updated_at"`
+}
+
+// MoviePersonalStats aggregates the personal rankings of every user for one movie
+type MoviePersonalStats struct {
+	MovieTitle    string  `bson:"_id"`
+	AverageRating float64 `bson:"average_rating"`
+	RankedBy      int     `bson:"ranked_by"`
+}
+
+// BattleOutcome is the minimal view of a stored battle used to replay ratings
+type BattleOutcome struct {
+	WinnerTitle string
+	LoserTitle  string
+}
@@ -14,4 +14,7 @@ type MovieRanking struct {
 	WinCount    int               `bson:"win_count" json:"win_count"`            // Number of times user chose this movie
 	LossCount   int               `bson:"loss_count" json:"loss_count"`          // Number of times user didn't choose this movie
 	LastUpdated time.Time         `bson:"last_updated" json:"last_updated"`      // Last time user rated this movie
+	Genres      []string          `bson:"genres,omitempty" json:"genres,omitempty"` // Normalised genres, e.g. ["Action", "Sci-Fi"]
+	Year        int               `bson:"year,omitempty" json:"year,omitempty"`     // Release year, 0 when unknown
+	Seen        bool              `bson:"seen,omitempty" json:"seen,omitempty"`     // Marked as watched by an import
 }
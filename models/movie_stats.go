@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// OpponentRecord is a movie's head-to-head record against one opponent in a user's battles
+type OpponentRecord struct {
+	MovieTitle  string    `bson:"_id" json:"movie_title"`
+	Wins        int       `bson:"wins" json:"wins"`
+	Losses      int       `bson:"losses" json:"losses"`
+	Matches     int       `bson:"matches" json:"matches"`
+	LastPlayed  time.Time `bson:"last_played" json:"last_played"`
+	OpponentELO int       `bson:"-" json:"opponent_elo,omitempty"` // The opponent's current rating in the user's list
+	LastWinner  string    `bson:"last_winner" json:"last_winner"`
+}
+
+// MovieStatsResponse is everything about one movie in a user's list
+type MovieStatsResponse struct {
+	Ranking      MovieRanking     `json:"ranking"`
+	Movie        *Movie           `json:"movie,omitempty"` // Catalog details, when the catalog has the movie
+	WinRate      float64          `json:"win_rate"`
+	Position     int              `json:"position,omitempty"`   // Rank among the user's battled or seen movies, 0 until battled
+	RankedMovies int              `json:"ranked_movies"`        // Number of battled or seen movies in the user's list
+	Percentile   *float64         `json:"percentile,omitempty"` // Share of the other battled or seen movies rated lower
+	Opponents    []OpponentRecord `json:"opponents"`
+	// The community leaderboard entry, nil until the movie is on it
+	Community *GlobalLeaderboardEntry `json:"community,omitempty"`
+	// Personal rating minus the community rating
+	CommunityDifference *int `json:"community_difference,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FederatedIdentity links a user to an account at an external OpenID Connect provider
+type FederatedIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// OIDCDiscovery holds the parts of a provider's /.well-known/openid-configuration we use
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCTokenResponse is the token endpoint response of the authorization code exchange
+type OIDCTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OIDCPendingLogin keeps the secrets of an authorization request until the provider redirects
+// back, keyed by its state. LinkUserID is set when a logged-in user links the provider instead.
+type OIDCPendingLogin struct {
+	State        string              `bson:"_id"`
+	Provider     string              `bson:"provider"`
+	CodeVerifier string              `bson:"code_verifier"`
+	Nonce        string              `bson:"nonce"`
+	GuestToken   string              `bson:"guest_token,omitempty"`
+	LinkUserID   *primitive.ObjectID `bson:"link_user_id,omitempty"`
+	ExpiresAt    time.Time           `bson:"expires_at"`
+}
+
+// OIDCClaims are the verified ID token claims used to find or create the local user
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// JSONWebKey is a single public key of a JSON Web Key Set
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
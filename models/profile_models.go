@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProfileResponse is the current user's own profile, without the movie rankings
+type ProfileResponse struct {
+	ID             primitive.ObjectID `json:"id"`
+	Email          string             `json:"email"`
+	PendingEmail   string             `json:"pending_email,omitempty"`
+	Role           string             `json:"role"`
+	DisplayName    string             `json:"display_name"`
+	AvatarURL      string             `json:"avatar_url"`
+	FavoriteGenres []string           `json:"favorite_genres"`
+	Privacy        PrivacySettings    `json:"privacy"`
+	MFAEnabled     bool               `json:"mfa_enabled"`
+	HasPassword    bool               `json:"has_password"`
+	DeletionAt     *time.Time         `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	LastLogin      time.Time          `json:"last_login"`
+}
+
+// UpdateProfileRequest is a partial update, fields left out are not changed
+type UpdateProfileRequest struct {
+	DisplayName    *string               `json:"display_name" binding:"omitempty,max=50"`
+	AvatarURL      *string               `json:"avatar_url" binding:"omitempty,max=500"`
+	FavoriteGenres *[]string             `json:"favorite_genres" binding:"omitempty,max=10,dive,min=1,max=40"`
+	Privacy        *UpdatePrivacyRequest `json:"privacy"`
+}
+
+// UpdatePrivacyRequest changes only the privacy settings that are sent
+type UpdatePrivacyRequest struct {
+	ProfileVisibility  *string `json:"profile_visibility" binding:"omitempty,oneof=public friends private"`
+	RankingsVisibility *string `json:"rankings_visibility" binding:"omitempty,oneof=public friends private"`
+	Discoverable       *bool   `json:"discoverable"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Sort keys accepted by the personal ranking endpoint
+const (
+	RankingSortELO     = "elo"
+	RankingSortWins    = "wins"
+	RankingSortMatches = "matches"
+	RankingSortWinRate = "win_rate"
+	RankingSortRecent  = "recent"
+)
+
+// RankingQuery selects, filters and pages a user's movie rankings
+type RankingQuery struct {
+	Sort       string
+	Ascending  bool
+	Offset     int
+	Limit      int
+	MinMatches int
+	Genre      string
+	YearFrom   int // 0 means no lower bound
+	YearTo     int // 0 means no upper bound
+}
+
+// RankedMovie is a movie ranking with its position in the list and catalog details
+type RankedMovie struct {
+	Position int          `bson:"-" json:"position"`
+	Ranking  MovieRanking `bson:",inline" json:"ranking"`
+	WinRate  float64      `bson:"win_rate" json:"win_rate"`
+	Movie    *Movie       `bson:"movie,omitempty" json:"movie,omitempty"`
+}
+
+// GenreRankings is a user's best movies within a genre
+type GenreRankings struct {
+	Genre  string         `bson:"key" json:"genre"`
+	Movies []MovieRanking `bson:"movies" json:"movies"`
+}
+
+// DecadeRankings is a user's best movies released in a decade, e.g. 1990 for the nineties
+type DecadeRankings struct {
+	Decade int            `bson:"key" json:"decade"`
+	Movies []MovieRanking `bson:"movies" json:"movies"`
+}
+
+type RankingListResponse struct {
+	Movies     []RankedMovie `json:"movies"`
+	Total      int64         `json:"total"`
+	Sort       string        `json:"sort"`
+	Offset     int           `json:"offset"`
+	Limit      int           `json:"limit"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// RatingPoint is a movie's rating in a user's list right after a submission
+type RatingPoint struct {
+	At         time.Time `json:"at"`
+	ELORating  int       `json:"elo_rating"`
+	Change     int       `json:"change"` // Against the previous point, 0 for the first
+	MatchCount int       `json:"match_count"`
+}
+
+// RatingHistoryResponse is the time series of a movie's rating in a user's list. The first
+// point is the rating before the first recorded battle.
+type RatingHistoryResponse struct {
+	MovieTitle  string        `json:"movie_title"`
+	Points      []RatingPoint `json:"points"`
+	TotalPoints int           `json:"total_points"`
+	Downsampled bool          `json:"downsampled"`
+}
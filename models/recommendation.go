@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Recommendation is a movie suggested to a user who hasn't battled it yet
+type Recommendation struct {
+	MovieTitle string  `json:"movie_title"`
+	Score      float64 `json:"score"` // Predicted preference, higher is better
+	// The battled movie that contributed most to the recommendation, if any
+	BecauseYouLiked string `json:"because_you_liked,omitempty"`
+	Movie           *Movie `json:"movie,omitempty"`
+}
+
+type RecommendationsResponse struct {
+	Recommendations []Recommendation `json:"recommendations"`
+	ModelBuiltAt    time.Time        `json:"model_built_at"`
+}
+
+// RecommendationEvaluation is the result of an offline evaluation of the recommender:
+// part of each user's battled movies is hidden, the model is trained on the rest and
+// the top K recommendations are checked against the hidden movies the user liked
+type RecommendationEvaluation struct {
+	K               int       `json:"k"`
+	HoldoutFraction float64   `json:"holdout_fraction"`
+	UsersEvaluated  int       `json:"users_evaluated"`
+	PrecisionAtK    float64   `json:"precision_at_k"`
+	RecallAtK       float64   `json:"recall_at_k"`
+	HitRate         float64   `json:"hit_rate"` // Share of users with at least one hit in the top K
+	CatalogCoverage float64   `json:"catalog_coverage"`
+	EvaluatedAt     time.Time `json:"evaluated_at"`
+}
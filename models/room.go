@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Room states
+const (
+	RoomWaiting = "waiting" // Between rounds
+	RoomVoting  = "voting"  // A round is open for votes
+	RoomClosed  = "closed"
+)
+
+// Message types exchanged over a room's WebSocket. The server sends the state, participant,
+// round and result messages; clients send votes, and the host starts rounds and closes the room.
+const (
+	RoomMessageState             = "room_state"
+	RoomMessageParticipantJoined = "participant_joined"
+	RoomMessageParticipantLeft   = "participant_left"
+	RoomMessageRoundStarted      = "round_started"
+	RoomMessageVoteCount         = "vote_count"
+	RoomMessageRoundResult       = "round_result"
+	RoomMessageClosed            = "room_closed"
+	RoomMessageError             = "error"
+
+	RoomMessageVote       = "vote"
+	RoomMessageStartRound = "start_round"
+	RoomMessageClose      = "close"
+)
+
+// RoomMessage is the envelope of every WebSocket message
+type RoomMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type CreateRoomRequest struct {
+	VoteSeconds int    `json:"vote_seconds" binding:"omitempty,min=5,max=120"` // Defaults to 20
+	Genre       string `json:"genre" binding:"omitempty,max=40"`               // Genre-only battles when set
+}
+
+type RoomParticipant struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	DisplayName string             `json:"display_name"`
+	IsHost      bool               `json:"is_host"`
+}
+
+// RoomRanking is a movie's standing within one room, rated on the majority votes
+type RoomRanking struct {
+	MovieTitle string `json:"movie_title"`
+	ELORating  int    `json:"elo_rating"`
+	MatchCount int    `json:"match_count"`
+	WinCount   int    `json:"win_count"`
+}
+
+type RoomState struct {
+	Code         string            `json:"code"`
+	Status       string            `json:"status"`
+	Round        int               `json:"round"`
+	VoteSeconds  int               `json:"vote_seconds"`
+	Genre        string            `json:"genre,omitempty"`
+	Participants []RoomParticipant `json:"participants"`
+	Ranking      []RoomRanking     `json:"ranking"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// RoomTicketResponse is the single-use ticket to pass as ?ticket= when opening the room's WebSocket
+type RoomTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type RoomRoundStarted struct {
+	Round    int            `json:"round"`
+	Pair     BattleResponse `json:"pair"`
+	Deadline time.Time      `json:"deadline"`
+}
+
+type RoomVote struct {
+	Round       int    `json:"round"`
+	WinnerTitle string `json:"winner_title"`
+}
+
+type RoomVoteCount struct {
+	Round  int `json:"round"`
+	Votes  int `json:"votes"`
+	Voters int `json:"voters"`
+}
+
+type RoomRoundResult struct {
+	Round  int            `json:"round"`
+	Votes  map[string]int `json:"votes"`  // Votes by movie title
+	Winner string         `json:"winner"` // Empty on a tie or when nobody voted
+	// Room ranking after the round
+	Ranking []RoomRanking `json:"ranking"`
+}
+
+type RoomError struct {
+	Message string `json:"message"`
+}
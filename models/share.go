@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Share is an immutable snapshot of a user's top list published under an unguessable token
+type Share struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Token       string             `bson:"token" json:"token"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"-"`
+	Title       string             `bson:"title" json:"title"`
+	DisplayName string             `bson:"display_name" json:"display_name"`
+	Movies      []SharedMovie      `bson:"movies" json:"movies"`
+	OpenGraph   bool               `bson:"open_graph" json:"open_graph"` // Serve Open Graph tags and the image card to link previews
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	// PNG card of the top posters, rendered on first request and kept with the snapshot
+	Card []byte `bson:"card,omitempty" json:"-"`
+}
+
+// SharedMovie is one entry of a shared top list
+type SharedMovie struct {
+	Rank       int    `bson:"rank" json:"rank"`
+	MovieTitle string `bson:"movie_title" json:"movie_title"`
+	Year       int    `bson:"year,omitempty" json:"year,omitempty"`
+	ELORating  int    `bson:"elo_rating" json:"elo_rating"`
+	MatchCount int    `bson:"match_count" json:"match_count"`
+	WinCount   int    `bson:"win_count" json:"win_count"`
+	PosterURL  string `bson:"poster_url,omitempty" json:"poster_url,omitempty"`
+}
+
+type CreateShareRequest struct {
+	Title     string `json:"title" binding:"omitempty,max=100"`
+	OpenGraph *bool  `json:"open_graph"` // Defaults to true
+}
+
+// ShareLink describes a published share to its owner
+type ShareLink struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ImageURL  string    `json:"image_url,omitempty"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningKey is a private key used to sign access tokens. Keys are shared through MongoDB
+// so every instance signs and verifies with the same set. The newest key that has not
+// been retired signs new tokens; retired keys still verify until VerifyUntil.
+type SigningKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Kid        string             `bson:"kid" json:"kid"`
+	Algorithm  string             `bson:"algorithm" json:"algorithm"`
+	PrivateKey string             `bson:"private_key,omitempty" json:"-"` // PKCS #8 PEM, AES-GCM encrypted when JWT_KEY_ENCRYPTION_KEY is set
+	// Only the public key of a JWT_PRIVATE_KEY_FILE key is stored, its private key stays in memory
+	PublicKey   *JSONWebKey `bson:"public_key,omitempty" json:"-"`
+	CreatedAt   time.Time   `bson:"created_at" json:"created_at"`
+	RetiredAt   *time.Time  `bson:"retired_at,omitempty" json:"retired_at,omitempty"`
+	VerifyUntil *time.Time  `bson:"verify_until,omitempty" json:"verify_until,omitempty"`
+}
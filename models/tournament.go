@@ -0,0 +1,151 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tournament formats
+const (
+	TournamentSingleElimination = "single"
+	TournamentDoubleElimination = "double"
+)
+
+// Where a tournament's entrants are picked from
+const (
+	TournamentSourceTop    = "top"
+	TournamentSourceGenre  = "genre"
+	TournamentSourceDecade = "decade"
+)
+
+// Tournament states
+const (
+	TournamentActive    = "active"
+	TournamentCompleted = "completed"
+)
+
+// Brackets of a tournament. Single elimination only has the winners bracket.
+const (
+	BracketWinners = "winners"
+	BracketLosers  = "losers"
+	BracketFinal   = "final"
+)
+
+// Tournament is a user's seeded elimination bracket. Matches are stored flat; each one
+// names the matches its winner and loser move on to.
+type Tournament struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID  `bson:"user_id" json:"-"`
+	Name        string              `bson:"name" json:"name"`
+	Format      string              `bson:"format" json:"format"`
+	Source      string              `bson:"source" json:"source"`
+	Genre       string              `bson:"genre,omitempty" json:"genre,omitempty"`
+	Decade      int                 `bson:"decade,omitempty" json:"decade,omitempty"`
+	Entrants    []TournamentEntrant `bson:"entrants" json:"entrants"`
+	Matches     []TournamentMatch   `bson:"matches" json:"-"`
+	Status      string              `bson:"status" json:"status"`
+	Champion    int                 `bson:"champion,omitempty" json:"-"` // Seed of the winner, 0 until completed
+	Version     int                 `bson:"version" json:"-"`            // Bumped on every save to detect concurrent results
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// TournamentEntrant is a seeded movie, seed 1 being the highest rated when the tournament started
+type TournamentEntrant struct {
+	Seed      int   `bson:"seed" json:"seed"`
+	Movie     Movie `bson:"movie" json:"movie"` // Movie ID is the ranking's, so results feed the user's ELO
+	ELORating int   `bson:"elo_rating" json:"elo_rating"`
+}
+
+// TournamentSlot points at one side of a match: slot 0 is entrant A, slot 1 entrant B
+type TournamentSlot struct {
+	MatchID string `bson:"match_id" json:"match_id"`
+	Slot    int    `bson:"slot" json:"slot"`
+}
+
+type TournamentMatch struct {
+	ID       string          `bson:"id" json:"id"` // e.g. "W1-3" for the third match of winners round 1
+	Bracket  string          `bson:"bracket" json:"bracket"`
+	Round    int             `bson:"round" json:"round"`
+	A        int             `bson:"a" json:"a"` // Seeds, 0 while still to be decided
+	B        int             `bson:"b" json:"b"`
+	Winner   int             `bson:"winner,omitempty" json:"winner,omitempty"`
+	WinnerTo *TournamentSlot `bson:"winner_to,omitempty" json:"-"`
+	LoserTo  *TournamentSlot `bson:"loser_to,omitempty" json:"-"`
+	PlayedAt *time.Time      `bson:"played_at,omitempty" json:"played_at,omitempty"`
+}
+
+type CreateTournamentRequest struct {
+	Name   string `json:"name" binding:"omitempty,max=100"`
+	Format string `json:"format" binding:"omitempty,oneof=single double"` // Defaults to single
+	Size   int    `json:"size" binding:"required,oneof=4 8 16 32 64"`
+	Source string `json:"source" binding:"omitempty,oneof=top genre decade"` // Defaults to top
+	Genre  string `json:"genre" binding:"max=40"`                            // Required for genre tournaments
+	Decade int    `json:"decade"`                                            // Required for decade tournaments, e.g. 1990
+}
+
+// TournamentBattleResponse is the next match to play, shaped like a regular battle pair
+type TournamentBattleResponse struct {
+	TournamentID primitive.ObjectID `json:"tournament_id"`
+	MatchID      string             `json:"match_id"`
+	Bracket      string             `json:"bracket"`
+	Round        int                `json:"round"`
+	MovieA       Movie              `json:"movie_a"`
+	MovieB       Movie              `json:"movie_b"`
+	SeedA        int                `json:"seed_a"`
+	SeedB        int                `json:"seed_b"`
+}
+
+type SubmitTournamentBattleRequest struct {
+	MatchID     string `json:"match_id" binding:"required"`
+	WinnerTitle string `json:"winner_title" binding:"required"`
+}
+
+// TournamentResponse is a tournament with its bracket tree
+type TournamentResponse struct {
+	Tournament
+	Brackets  []TournamentBracket `json:"brackets"`
+	Champion  *TournamentEntrant  `json:"champion,omitempty"`
+	Played    int                 `json:"played"`
+	Remaining int                 `json:"remaining"`
+}
+
+type TournamentBracket struct {
+	Name   string            `json:"name"`
+	Rounds []TournamentRound `json:"rounds"`
+}
+
+type TournamentRound struct {
+	Round   int                   `json:"round"`
+	Matches []TournamentMatchView `json:"matches"`
+}
+
+// TournamentMatchView is a match with its entrants filled in
+type TournamentMatchView struct {
+	ID       string             `json:"id"`
+	A        *TournamentEntrant `json:"a"`
+	B        *TournamentEntrant `json:"b"`
+	Winner   int                `json:"winner,omitempty"`
+	PlayedAt *time.Time         `json:"played_at,omitempty"`
+}
+
+// TournamentSummary describes a tournament in the user's list
+type TournamentSummary struct {
+	ID        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name"`
+	Format    string             `json:"format"`
+	Size      int                `json:"size"`
+	Status    string             `json:"status"`
+	Champion  string             `json:"champion,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+type TournamentListResponse struct {
+	Tournaments []TournamentSummary `json:"tournaments"`
+	Total       int64               `json:"total"`
+	Page        int                 `json:"page"`
+	PageSize    int                 `json:"page_size"`
+}
@@ -6,13 +6,80 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Visibility levels for the privacy settings. An empty value is treated as public.
+const (
+	VisibilityPublic  = "public"
+	VisibilityFriends = "friends"
+	VisibilityPrivate = "private"
+)
+
+// PrivacySettings controls what other users can see
+type PrivacySettings struct {
+	ProfileVisibility  string `bson:"profile_visibility" json:"profile_visibility" binding:"omitempty,oneof=public friends private"`
+	RankingsVisibility string `bson:"rankings_visibility" json:"rankings_visibility" binding:"omitempty,oneof=public friends private"`
+	Discoverable       bool   `bson:"discoverable" json:"discoverable"` // Listed in other users' "most similar" results
+}
+
+// DefaultPrivacySettings are applied to new accounts
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		ProfileVisibility:  VisibilityPublic,
+		RankingsVisibility: VisibilityPublic,
+		Discoverable:       true,
+	}
+}
+
+// User roles carried in the JWT claims and checked by middleware.RequireRole
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	RoleGuest = "guest" // Anonymous player, see GuestExpiresAt
+)
+
 type User struct {
 	// User information
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Email     string             `bson:"email" json:"email"`
 	Password  string             `bson:"password" json:"-"`
+	Role      string             `bson:"role" json:"role"`
+	Disabled  bool               `bson:"disabled" json:"disabled"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	LastLogin time.Time          `bson:"last_login" json:"last_login"`
+	// Set once the user proved they own the email, through a verification link or a provider
+	// that reports it as verified. Registration doesn't verify the email.
+	EmailVerified bool `bson:"email_verified" json:"email_verified"`
+
+	// Profile
+	DisplayName    string          `bson:"display_name" json:"display_name"`
+	AvatarURL      string          `bson:"avatar_url" json:"avatar_url"`
+	FavoriteGenres []string        `bson:"favorite_genres" json:"favorite_genres"`
+	Privacy        PrivacySettings `bson:"privacy" json:"privacy"`
+
+	// Email change waiting for the new address to be verified. Only a hash of the token is stored.
+	PendingEmail            string    `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	EmailVerificationHash   string    `bson:"email_verification_hash,omitempty" json:"-"`
+	EmailVerificationExpiry time.Time `bson:"email_verification_expiry,omitempty" json:"-"`
+
+	// Two-factor authentication. Backup codes are bcrypt hashes and removed once used.
+	MFAEnabled       bool     `bson:"mfa_enabled" json:"mfa_enabled"`
+	MFASecret        string   `bson:"mfa_secret,omitempty" json:"-"`
+	MFAPendingSecret string   `bson:"mfa_pending_secret,omitempty" json:"-"`
+	MFABackupCodes   []string `bson:"mfa_backup_codes,omitempty" json:"-"`
+	MFALastUsedStep  int64    `bson:"mfa_last_used_step,omitempty" json:"-"`
+
+	// Running battle totals for achievements
+	BattleStats BattleStats `bson:"battle_stats" json:"-"`
+
+	// Guest accounts are removed after this time unless merged into a real account first
+	GuestExpiresAt *time.Time `bson:"guest_expires_at,omitempty" json:"guest_expires_at,omitempty"`
+	// Set when a guest's merge into an account starts, so it is only merged once
+	GuestMergingAt *time.Time `bson:"merging,omitempty" json:"-"`
+
+	// Set when the user asked to delete their account; it is purged after the grace period
+	DeletionScheduledAt *time.Time `bson:"deletion_scheduled_at,omitempty" json:"deletion_scheduled_at,omitempty"`
+
+	// Accounts at external OpenID Connect providers that can sign in as this user
+	Identities []FederatedIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
 
 	// Movie rankings - each user's personal movie ratings and stats
 	MovieRankings []MovieRanking `bson:"movie_rankings" json:"movie_rankings"`
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// AccountService handles personal data requests: exporting everything we hold about a
+// user and deleting their account after a grace period
+type AccountService struct {
+	userRepo        *data_access.UserRepository
+	battleRepo      *data_access.BattleRepository
+	followRepo      *data_access.FollowRepository
+	shareRepo       *data_access.ShareRepository
+	tournamentRepo  *data_access.TournamentRepository
+	challengeRepo   *data_access.ChallengeRepository
+	achievementRepo *data_access.AchievementRepository
+	auditRepo       *data_access.AuditRepository
+	deletionGrace   time.Duration
+}
+
+func NewAccountService(
+	userRepo *data_access.UserRepository,
+	battleRepo *data_access.BattleRepository,
+	followRepo *data_access.FollowRepository,
+	shareRepo *data_access.ShareRepository,
+	tournamentRepo *data_access.TournamentRepository,
+	challengeRepo *data_access.ChallengeRepository,
+	achievementRepo *data_access.AchievementRepository,
+	auditRepo *data_access.AuditRepository,
+	deletionGrace time.Duration,
+) *AccountService {
+	return &AccountService{
+		userRepo:        userRepo,
+		battleRepo:      battleRepo,
+		followRepo:      followRepo,
+		shareRepo:       shareRepo,
+		tournamentRepo:  tournamentRepo,
+		challengeRepo:   challengeRepo,
+		achievementRepo: achievementRepo,
+		auditRepo:       auditRepo,
+		deletionGrace:   deletionGrace,
+	}
+}
+
+// WriteExport writes a zip archive with everything stored about the user
+func (s *AccountService) WriteExport(ctx context.Context, userID primitive.ObjectID, w io.Writer) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error finding user: %v", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	battles, err := s.battleRepo.FindBattlesByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	follows, err := s.followRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	shares, err := s.shareRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tournaments, err := s.tournamentRepo.FindAllForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	challengeVotes, err := s.challengeRepo.FindAllVotesForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	achievements, err := s.achievementRepo.FindForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	if err := writeZipJSON(archive, "profile.json", models.DataExport{
+		ExportedAt: time.Now(),
+		Profile:    toProfileResponse(user),
+	}); err != nil {
+		return err
+	}
+
+	rankings := user.MovieRankings
+	if rankings == nil {
+		rankings = []models.MovieRanking{}
+	}
+	if err := writeZipJSON(archive, "rankings.json", rankings); err != nil {
+		return err
+	}
+	rankingRows := [][]string{{"movie_id", "movie_title", "elo_rating", "match_count", "win_count", "loss_count", "last_updated"}}
+	for _, ranking := range rankings {
+		rankingRows = append(rankingRows, []string{
+			ranking.MovieID.Hex(),
+			ranking.MovieTitle,
+			strconv.Itoa(ranking.ELORating),
+			strconv.Itoa(ranking.MatchCount),
+			strconv.Itoa(ranking.WinCount),
+			strconv.Itoa(ranking.LossCount),
+			ranking.LastUpdated.Format(time.RFC3339),
+		})
+	}
+	if err := writeZipCSV(archive, "rankings.csv", rankingRows); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(archive, "battles.json", battles); err != nil {
+		return err
+	}
+	battleRows := [][]string{{"battle_id", "created_at", "movie_a", "movie_b", "winner"}}
+	for _, battle := range battles {
+		battleRows = append(battleRows, []string{
+			battle.ID.Hex(),
+			battle.CreatedAt.Format(time.RFC3339),
+			battle.MovieA.Title,
+			battle.MovieB.Title,
+			battle.Winner.Title,
+		})
+	}
+	if err := writeZipCSV(archive, "battles.csv", battleRows); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(archive, "follows.json", follows); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(archive, "shares.json", shares); err != nil {
+		return err
+	}
+
+	// Tournaments are exported with their bracket tree
+	brackets := make([]*models.TournamentResponse, 0, len(tournaments))
+	for i := range tournaments {
+		brackets = append(brackets, toTournamentResponse(&tournaments[i]))
+	}
+	if err := writeZipJSON(archive, "tournaments.json", brackets); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(archive, "challenge_votes.json", challengeVotes); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(archive, "achievements.json", achievements); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error finishing export archive: %v", err)
+	}
+
+	s.audit(ctx, models.AuditDataExported, userID, "")
+	return nil
+}
+
+// RequestDeletion schedules the account for deletion once the grace period has passed
+func (s *AccountService) RequestDeletion(ctx context.Context, userID primitive.ObjectID, req *models.DeleteAccountRequest) (time.Time, error) {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil || user == nil {
+		return time.Time{}, ErrUserNotFound
+	}
+
+	if err := checkPassword(user, req.Password); err != nil {
+		return time.Time{}, err
+	}
+
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt, nil
+	}
+
+	scheduledAt := time.Now().Add(s.deletionGrace)
+	if err := s.userRepo.ScheduleDeletion(ctx, userID, scheduledAt); err != nil {
+		return time.Time{}, fmt.Errorf("error scheduling deletion: %v", err)
+	}
+
+	s.audit(ctx, models.AuditAccountDeletionRequested, userID, "scheduled for "+scheduledAt.Format(time.RFC3339))
+	return scheduledAt, nil
+}
+
+// CancelDeletion keeps an account that is still inside its grace period
+func (s *AccountService) CancelDeletion(ctx context.Context, userID primitive.ObjectID) error {
+	cancelled, err := s.userRepo.CancelDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return errors.New("account is not scheduled for deletion")
+	}
+
+	s.audit(ctx, models.AuditAccountDeletionCancelled, userID, "")
+	return nil
+}
+
+// PurgeDueAccounts deletes every account whose grace period has ended. Battles are kept
+// anonymised so community statistics don't change when someone leaves.
+func (s *AccountService) PurgeDueAccounts(ctx context.Context) (int, error) {
+	userIDs, err := s.userRepo.FindDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding accounts to delete: %v", err)
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		anonymised, err := s.battleRepo.AnonymizeUserBattles(ctx, userID)
+		if err != nil {
+			return purged, fmt.Errorf("error anonymising battles of %s: %v", userID.Hex(), err)
+		}
+		if _, err := s.followRepo.DeleteAllForUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting follows of %s: %v", userID.Hex(), err)
+		}
+		if _, err := s.shareRepo.DeleteAllForUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting shares of %s: %v", userID.Hex(), err)
+		}
+		if _, err := s.tournamentRepo.DeleteAllForUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting tournaments of %s: %v", userID.Hex(), err)
+		}
+		if _, err := s.challengeRepo.DeleteVotesForUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting challenge votes of %s: %v", userID.Hex(), err)
+		}
+		if _, err := s.achievementRepo.DeleteAllForUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting achievements of %s: %v", userID.Hex(), err)
+		}
+		if err := s.userRepo.DeleteUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("error deleting user %s: %v", userID.Hex(), err)
+		}
+
+		s.audit(ctx, models.AuditAccountDeleted, userID, fmt.Sprintf("%d battles anonymised", anonymised))
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartPurge runs PurgeDueAccounts every interval until ctx is cancelled
+func (s *AccountService) StartPurge(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "account purge", interval, func(ctx context.Context) error {
+		purged, err := s.PurgeDueAccounts(ctx)
+		if purged > 0 {
+			fmt.Printf("Purged %d deleted accounts\n", purged)
+		}
+		return err
+	})
+}
+
+// audit records an action, logging instead of failing the request if the write fails
+func (s *AccountService) audit(ctx context.Context, action string, userID primitive.ObjectID, details string) {
+	record := &models.AuditRecord{
+		Action:    action,
+		UserID:    userID,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+	if err := s.auditRepo.Record(ctx, record); err != nil {
+		fmt.Printf("Error writing audit record %s for %s: %v\n", action, userID.Hex(), err)
+	}
+}
+
+func writeZipJSON(archive *zip.Writer, name string, value interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s to export: %v", name, err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeZipCSV(archive *zip.Writer, name string, rows [][]string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s to export: %v", name, err)
+	}
+
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = csvSafe(cell)
+		}
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// Genres need this many movies in a user's list to count for the completionist achievement
+const achievementGenreMinMovies = 10
+
+// achievementStats are the figures achievement rules are evaluated against
+type achievementStats struct {
+	battles       int
+	currentStreak int
+	longestStreak int
+	battledMovies int
+	// Battled and total movies of the genre closest to being fully battled
+	genreBattled int
+	genreTotal   int
+}
+
+// achievementRule describes an achievement and measures a user's progress towards it
+type achievementRule struct {
+	id          string
+	name        string
+	description string
+	progress    func(stats *achievementStats) (int, int) // Progress and target
+	// Progress only changes when a movie is battled for the first time
+	newMovies bool
+}
+
+// countRule is a rule that is met once a figure reaches a fixed target
+func countRule(id, name, description string, target int, figure func(stats *achievementStats) int) achievementRule {
+	return achievementRule{
+		id:          id,
+		name:        name,
+		description: description,
+		progress: func(stats *achievementStats) (int, int) {
+			return figure(stats), target
+		},
+	}
+}
+
+// movieRule is a count rule on the number of different movies battled
+func movieRule(id, name, description string, target int) achievementRule {
+	rule := countRule(id, name, description, target, battledMovies)
+	rule.newMovies = true
+	return rule
+}
+
+func battleCount(stats *achievementStats) int   { return stats.battles }
+func longestStreak(stats *achievementStats) int { return stats.longestStreak }
+func battledMovies(stats *achievementStats) int { return stats.battledMovies }
+
+// achievementRules are all achievements, in the order they are listed
+var achievementRules = []achievementRule{
+	countRule("first_battle", "First Pick", "Play your first battle", 1, battleCount),
+	countRule("battles_100", "Centurion", "Play 100 battles", 100, battleCount),
+	countRule("battles_500", "Seasoned Critic", "Play 500 battles", 500, battleCount),
+	countRule("battles_1000", "Marathon", "Play 1,000 battles", 1000, battleCount),
+	countRule("streak_3", "On a Roll", "Battle on 3 days in a row", 3, longestStreak),
+	countRule("streak_7", "Week-long Streak", "Battle on 7 days in a row", 7, longestStreak),
+	countRule("streak_30", "Daily Habit", "Battle on 30 days in a row", 30, longestStreak),
+	movieRule("movies_50", "Film Buff", "Battle 50 different movies", 50),
+	movieRule("movies_250", "Cinephile", "Battle 250 different movies", 250),
+	{
+		id:          "genre_complete",
+		name:        "Completionist",
+		description: fmt.Sprintf("Battle every movie of a genre with at least %d movies in your list", achievementGenreMinMovies),
+		progress: func(stats *achievementStats) (int, int) {
+			return stats.genreBattled, stats.genreTotal
+		},
+		newMovies: true,
+	},
+}
+
+// AchievementService unlocks achievements as users play and reports their progress. It works
+// from the running totals on the user, so checking a submission costs the same however
+// long the user has been playing.
+type AchievementService struct {
+	achievementRepo *data_access.AchievementRepository
+	battleRepo      *data_access.BattleRepository
+	userRepo        *data_access.UserRepository
+}
+
+func NewAchievementService(
+	achievementRepo *data_access.AchievementRepository,
+	battleRepo *data_access.BattleRepository,
+	userRepo *data_access.UserRepository,
+) *AchievementService {
+	return &AchievementService{
+		achievementRepo: achievementRepo,
+		battleRepo:      battleRepo,
+		userRepo:        userRepo,
+	}
+}
+
+// RecordSubmission adds a submission of battles to the user's running totals and unlocks the
+// achievements it completed. newMovies are the rankings battled for the first time; movie
+// and genre achievements are only checked when there are any. Guests don't earn
+// achievements. Unlocked achievements are kept even if battles are undone later.
+func (s *AchievementService) RecordSubmission(ctx context.Context, userID primitive.ObjectID, battles int, newMovies []*models.MovieRanking) ([]models.AchievementProgress, error) {
+	now := time.Now().UTC()
+	user, err := s.userRepo.RecordBattleStats(ctx, userID, battles, len(newMovies),
+		now.Format("2006-01-02"), now.AddDate(0, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error recording battle stats: %v", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Role == models.RoleGuest {
+		return []models.AchievementProgress{}, nil
+	}
+
+	stats := statsFromBattleStats(user.BattleStats, now)
+	if len(newMovies) > 0 {
+		genres := []string{}
+		seen := make(map[string]bool)
+		for _, ranking := range newMovies {
+			for _, genre := range ranking.Genres {
+				if !seen[genre] {
+					seen[genre] = true
+					genres = append(genres, genre)
+				}
+			}
+		}
+		if err := s.addGenreCoverage(ctx, userID, genres, stats); err != nil {
+			return nil, err
+		}
+	}
+
+	earned, err := s.earned(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlocked := []models.AchievementProgress{}
+	for _, rule := range achievementRules {
+		if _, ok := earned[rule.id]; ok || (rule.newMovies && len(newMovies) == 0) {
+			continue
+		}
+		if progress, target := rule.progress(stats); target == 0 || progress < target {
+			continue
+		}
+
+		recorded, err := s.achievementRepo.Unlock(ctx, &models.UnlockedAchievement{
+			UserID:        userID,
+			AchievementID: rule.id,
+			UnlockedAt:    now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// A concurrent submission may have recorded it first
+		if recorded {
+			unlocked = append(unlocked, unlockedProgress(rule, stats, now))
+		}
+	}
+
+	return unlocked, nil
+}
+
+// GetAchievements lists the user's earned achievements and their progress on the others
+func (s *AchievementService) GetAchievements(ctx context.Context, userID primitive.ObjectID) (*models.AchievementsResponse, error) {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding user: %v", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	stats := statsFromBattleStats(user.BattleStats, time.Now())
+	if err := s.addGenreCoverage(ctx, userID, nil, stats); err != nil {
+		return nil, err
+	}
+	earned, err := s.earned(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.AchievementsResponse{
+		Earned:        []models.AchievementProgress{},
+		InProgress:    []models.AchievementProgress{},
+		Battles:       stats.battles,
+		CurrentStreak: stats.currentStreak,
+		LongestStreak: stats.longestStreak,
+	}
+	for _, rule := range achievementRules {
+		if unlockedAt, ok := earned[rule.id]; ok {
+			response.Earned = append(response.Earned, unlockedProgress(rule, stats, unlockedAt))
+			continue
+		}
+		response.InProgress = append(response.InProgress, ruleProgress(rule, stats))
+	}
+	sort.SliceStable(response.Earned, func(i, j int) bool {
+		return response.Earned[i].UnlockedAt.After(*response.Earned[j].UnlockedAt)
+	})
+
+	return response, nil
+}
+
+// earned returns the unlock time of every achievement the user has earned
+func (s *AchievementService) earned(ctx context.Context, userID primitive.ObjectID) (map[string]time.Time, error) {
+	existing, err := s.achievementRepo.FindForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	earned := make(map[string]time.Time, len(existing))
+	for _, achievement := range existing {
+		earned[achievement.AchievementID] = achievement.UnlockedAt
+	}
+	return earned, nil
+}
+
+// addGenreCoverage fills in the completionist progress from the given genres, or every genre
+// in the user's list when genres is nil. Progress is shown for the genre with the largest
+// share battled.
+func (s *AchievementService) addGenreCoverage(ctx context.Context, userID primitive.ObjectID, genres []string, stats *achievementStats) error {
+	stats.genreTotal = achievementGenreMinMovies
+	if genres != nil && len(genres) == 0 {
+		return nil
+	}
+
+	coverage, err := s.battleRepo.FindGenreCoverage(ctx, userID, genres)
+	if err != nil {
+		return err
+	}
+	stats.genreBattled, stats.genreTotal = bestGenreCoverage(coverage)
+	return nil
+}
+
+// bestGenreCoverage returns the battled and total movies of the genre, among those with
+// enough movies, whose movies are battled the most completely
+func bestGenreCoverage(coverage []models.GenreCoverage) (int, int) {
+	battled, total := 0, 0
+	for _, genre := range coverage {
+		if genre.Movies < achievementGenreMinMovies {
+			continue
+		}
+		if total == 0 || genre.Battled*total > battled*genre.Movies {
+			battled, total = genre.Battled, genre.Movies
+		}
+	}
+	if total == 0 {
+		return 0, achievementGenreMinMovies
+	}
+	return battled, total
+}
+
+// statsFromBattleStats turns the user's running totals into achievement figures. The
+// current streak has ended when the last battle day is before yesterday.
+func statsFromBattleStats(totals models.BattleStats, now time.Time) *achievementStats {
+	stats := &achievementStats{
+		battles:       totals.Battles,
+		longestStreak: totals.LongestStreak,
+		battledMovies: totals.BattledMovies,
+	}
+	if lastDay, err := time.Parse("2006-01-02", totals.LastDay); err == nil {
+		if now.UTC().Truncate(24*time.Hour).Sub(lastDay) <= 24*time.Hour {
+			stats.currentStreak = totals.CurrentStreak
+		}
+	}
+	return stats
+}
+
+// battleStreaks returns the current and the longest run of consecutive UTC days with a
+// battle. The current run counts while the last battle day is today or yesterday.
+func battleStreaks(days []models.BattleDay, now time.Time) (int, int) {
+	current, longest := 0, 0
+	var previous time.Time
+	for _, day := range days {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+		if !previous.IsZero() && date.Sub(previous) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+		previous = date
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	if previous.IsZero() || today.Sub(previous) > 24*time.Hour {
+		current = 0
+	}
+	return current, longest
+}
+
+func ruleProgress(rule achievementRule, stats *achievementStats) models.AchievementProgress {
+	progress, target := rule.progress(stats)
+	return models.AchievementProgress{
+		ID:          rule.id,
+		Name:        rule.name,
+		Description: rule.description,
+		Progress:    progress,
+		Target:      target,
+	}
+}
+
+func unlockedProgress(rule achievementRule, stats *achievementStats, unlockedAt time.Time) models.AchievementProgress {
+	achievement := ruleProgress(rule, stats)
+	achievement.Progress = achievement.Target
+	achievement.Unlocked = true
+	achievement.UnlockedAt = &unlockedAt
+	return achievement
+}
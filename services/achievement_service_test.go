@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"movie-vs-backend/models"
+)
+
+func battleDays(dates ...string) []models.BattleDay {
+	days := make([]models.BattleDay, 0, len(dates))
+	for _, date := range dates {
+		days = append(days, models.BattleDay{Date: date, Battles: 1})
+	}
+	return days
+}
+
+func TestBattleStreaks(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	// Still the 18th in New York, already the 19th in UTC
+	lateInNewYork := time.Date(2026, 10, 18, 22, 30, 0, 0, time.FixedZone("EDT", -4*60*60))
+
+	tests := []struct {
+		name        string
+		days        []models.BattleDay
+		now         time.Time
+		wantCurrent int
+		wantLongest int
+	}{
+		{name: "no battles", now: now},
+		{name: "today only", days: battleDays("2026-10-18"), now: now, wantCurrent: 1, wantLongest: 1},
+		{name: "run ending today", days: battleDays("2026-10-16", "2026-10-17", "2026-10-18"), now: now, wantCurrent: 3, wantLongest: 3},
+		{name: "run ending yesterday", days: battleDays("2026-10-16", "2026-10-17"), now: now, wantCurrent: 2, wantLongest: 2},
+		{name: "run ended two days ago", days: battleDays("2026-10-15", "2026-10-16"), now: now, wantCurrent: 0, wantLongest: 2},
+		{name: "gap restarts the run", days: battleDays("2026-10-10", "2026-10-11", "2026-10-12", "2026-10-17", "2026-10-18"), now: now, wantCurrent: 2, wantLongest: 3},
+		{name: "across a month end", days: battleDays("2026-09-30", "2026-10-01"), now: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), wantCurrent: 2, wantLongest: 2},
+		{name: "days are UTC days", days: battleDays("2026-10-17"), now: lateInNewYork, wantCurrent: 0, wantLongest: 1},
+		{name: "unparseable days are skipped", days: battleDays("2026-10-17", "bogus", "2026-10-18"), now: now, wantCurrent: 2, wantLongest: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := battleStreaks(tt.days, tt.now)
+			if current != tt.wantCurrent || longest != tt.wantLongest {
+				t.Errorf("battleStreaks() = %d, %d, want %d, %d", current, longest, tt.wantCurrent, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func TestStatsFromBattleStats(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		lastDay     string
+		wantCurrent int
+	}{
+		{name: "battled today", lastDay: "2026-10-18", wantCurrent: 4},
+		{name: "battled yesterday", lastDay: "2026-10-17", wantCurrent: 4},
+		{name: "streak ended", lastDay: "2026-10-16", wantCurrent: 0},
+		{name: "never battled", lastDay: "", wantCurrent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := statsFromBattleStats(models.BattleStats{Battles: 9, LastDay: tt.lastDay, CurrentStreak: 4, LongestStreak: 6}, now)
+			if stats.currentStreak != tt.wantCurrent || stats.longestStreak != 6 || stats.battles != 9 {
+				t.Errorf("stats = %+v, want current streak %d", *stats, tt.wantCurrent)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrMovieNotFound    = errors.New("movie not found")
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// migration is a named, idempotent data fix that admins can trigger from the API
+type migration func(ctx context.Context) (int64, error)
+
+type AdminService struct {
+	userRepo        *data_access.UserRepository
+	movieRepo       *data_access.MovieRepository
+	battleRepo      *data_access.BattleRepository
+	signingKeyRepo  *data_access.SigningKeyRepository
+	auditRepo       *data_access.AuditRepository
+	leaderboardRepo *data_access.LeaderboardRepository
+	followRepo      *data_access.FollowRepository
+	shareRepo       *data_access.ShareRepository
+	tournamentRepo  *data_access.TournamentRepository
+	challengeRepo   *data_access.ChallengeRepository
+	achievementRepo *data_access.AchievementRepository
+	mfaAttemptRepo  *data_access.MFAAttemptRepository
+	oidcLoginRepo   *data_access.OIDCLoginRepository
+	migrations      map[string]migration
+}
+
+func NewAdminService(
+	userRepo *data_access.UserRepository,
+	movieRepo *data_access.MovieRepository,
+	battleRepo *data_access.BattleRepository,
+	signingKeyRepo *data_access.SigningKeyRepository,
+	auditRepo *data_access.AuditRepository,
+	leaderboardRepo *data_access.LeaderboardRepository,
+	followRepo *data_access.FollowRepository,
+	shareRepo *data_access.ShareRepository,
+	tournamentRepo *data_access.TournamentRepository,
+	challengeRepo *data_access.ChallengeRepository,
+	achievementRepo *data_access.AchievementRepository,
+	mfaAttemptRepo *data_access.MFAAttemptRepository,
+	oidcLoginRepo *data_access.OIDCLoginRepository,
+) *AdminService {
+	s := &AdminService{
+		userRepo:        userRepo,
+		movieRepo:       movieRepo,
+		battleRepo:      battleRepo,
+		signingKeyRepo:  signingKeyRepo,
+		auditRepo:       auditRepo,
+		leaderboardRepo: leaderboardRepo,
+		followRepo:      followRepo,
+		shareRepo:       shareRepo,
+		tournamentRepo:  tournamentRepo,
+		challengeRepo:   challengeRepo,
+		achievementRepo: achievementRepo,
+		mfaAttemptRepo:  mfaAttemptRepo,
+		oidcLoginRepo:   oidcLoginRepo,
+	}
+
+	s.migrations = map[string]migration{
+		"backfill-user-roles": func(ctx context.Context) (int64, error) {
+			return s.userRepo.BackfillRoles(ctx, models.RoleUser)
+		},
+		"seed-movie-catalog": func(ctx context.Context) (int64, error) {
+			return s.ImportCatalogFromCSV(ctx)
+		},
+		"backfill-privacy-settings": func(ctx context.Context) (int64, error) {
+			return s.userRepo.BackfillPrivacy(ctx, models.DefaultPrivacySettings())
+		},
+		"backfill-ranking-genres": s.backfillRankingGenres,
+		"backfill-battle-stats":   s.backfillBattleStats,
+	}
+
+	return s
+}
+
+// ListUsers returns one page of users, pages start at 1
+func (s *AdminService) ListUsers(ctx context.Context, page, pageSize int) (*models.UserListResponse, error) {
+	users, total, err := s.userRepo.ListUsers(ctx, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserListResponse{
+		Users:    users,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// SetUserDisabled disables or re-enables an account. Disabled users can no longer log in
+// and their tokens stop working once AuthMiddleware's cached role expires.
+func (s *AdminService) SetUserDisabled(ctx context.Context, userID primitive.ObjectID, disabled bool) error {
+	err := s.userRepo.SetDisabled(ctx, userID, disabled)
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// SetUserRole changes the user's role, which AuthMiddleware picks up within a short while
+func (s *AdminService) SetUserRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	err := s.userRepo.SetRole(ctx, userID, role)
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (s *AdminService) ListCatalog(ctx context.Context, page, pageSize int) ([]models.Movie, int64, error) {
+	return s.movieRepo.ListCatalog(ctx, int64((page-1)*pageSize), int64(pageSize))
+}
+
+func (s *AdminService) AddCatalogMovie(ctx context.Context, req *models.CatalogMovieRequest) (*models.Movie, error) {
+	existing, err := s.movieRepo.FindCatalogMovieByTitle(ctx, req.Title)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("movie already exists in catalog")
+	}
+
+	movie := &models.Movie{
+		Title:      req.Title,
+		Year:       req.Year,
+		PosterURL:  req.PosterURL,
+		Plot:       req.Plot,
+		Director:   req.Director,
+		Genre:      req.Genre,
+		Actors:     req.Actors,
+		IMDBRating: req.IMDBRating,
+		IMDBID:     req.IMDBID,
+	}
+
+	if err := s.movieRepo.AddCatalogMovie(ctx, movie); err != nil {
+		return nil, fmt.Errorf("error adding catalog movie: %v", err)
+	}
+
+	return movie, nil
+}
+
+func (s *AdminService) DeleteCatalogMovie(ctx context.Context, movieID primitive.ObjectID) error {
+	err := s.movieRepo.DeleteCatalogMovie(ctx, movieID)
+	if err == mongo.ErrNoDocuments {
+		return ErrMovieNotFound
+	}
+	return err
+}
+
+// ImportCatalogFromCSV seeds the catalog from IMDB-Movie-Data.csv, keeping existing entries
+func (s *AdminService) ImportCatalogFromCSV(ctx context.Context) (int64, error) {
+	movies, err := helper.LoadCatalogFromCSV()
+	if err != nil {
+		return 0, fmt.Errorf("error reading catalog CSV: %v", err)
+	}
+	return s.movieRepo.ImportCatalog(ctx, movies)
+}
+
+// Reindex (re)creates the indexes of every collection and returns their names
+func (s *AdminService) Reindex(ctx context.Context) ([]string, error) {
+	var indexes []string
+	for name, repo := range s.indexedRepos() {
+		created, err := repo.EnsureIndexes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error creating %s indexes: %v", name, err)
+		}
+		indexes = append(indexes, created...)
+	}
+	return indexes, nil
+}
+
+// indexer is implemented by repositories that own MongoDB indexes
+type indexer interface {
+	EnsureIndexes(ctx context.Context) ([]string, error)
+}
+
+func (s *AdminService) indexedRepos() map[string]indexer {
+	return map[string]indexer{
+		"users":              s.userRepo,
+		"movies":             s.movieRepo,
+		"signing_keys":       s.signingKeyRepo,
+		"battles":            s.battleRepo,
+		"audit_log":          s.auditRepo,
+		"global_leaderboard": s.leaderboardRepo,
+		"follows":            s.followRepo,
+		"shares":             s.shareRepo,
+		"tournaments":        s.tournamentRepo,
+		"daily_challenges":   s.challengeRepo,
+		"achievements":       s.achievementRepo,
+		"mfa_attempts":       s.mfaAttemptRepo,
+		"oidc_logins":        s.oidcLoginRepo,
+	}
+}
+
+// Migrations returns the names of the migrations that can be run
+func (s *AdminService) Migrations() []string {
+	names := make([]string, 0, len(s.migrations))
+	for name := range s.migrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *AdminService) RunMigration(ctx context.Context, name string) (*models.MigrationResult, error) {
+	run, ok := s.migrations[name]
+	if !ok {
+		return nil, ErrUnknownMigration
+	}
+
+	fmt.Printf("Running migration %s\n", name)
+	modified, err := run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migration %s failed: %v", name, err)
+	}
+
+	return &models.MigrationResult{
+		Name:     name,
+		Modified: modified,
+		RanAt:    time.Now(),
+	}, nil
+}
+
+// backfillRankingGenres copies the normalised genre and year of every catalog movie onto
+// the rankings of that title that were created before they were stored
+func (s *AdminService) backfillRankingGenres(ctx context.Context) (int64, error) {
+	movies, _, err := s.movieRepo.ListCatalog(ctx, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var modified int64
+	for _, movie := range movies {
+		count, err := s.battleRepo.SetRankingAttributes(ctx, movie.Title, helper.NormalizeGenres(movie.Genre), helper.ParseYear(movie.Year))
+		if err != nil {
+			return modified, err
+		}
+		modified += count
+	}
+
+	return modified, nil
+}
+
+// backfillBattleStats computes the running totals of every user from their battle history,
+// for users who played before the totals were kept
+func (s *AdminService) backfillBattleStats(ctx context.Context) (int64, error) {
+	battled := make(map[primitive.ObjectID]int)
+	err := s.userRepo.ForEachRankedUser(ctx, func(user *models.User) {
+		battled[user.ID] = countBattled(user.MovieRankings)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var modified int64
+	for userID, battledMovies := range battled {
+		days, err := s.battleRepo.FindBattleDays(ctx, userID)
+		if err != nil {
+			return modified, err
+		}
+
+		stats := models.BattleStats{BattledMovies: battledMovies}
+		for _, day := range days {
+			stats.Battles += day.Battles
+		}
+		if len(days) > 0 {
+			stats.LastDay = days[len(days)-1].Date
+			// Measured as of the last battle day, so a streak that has since lapsed is kept
+			// until the next battle resets it
+			lastDay, _ := time.Parse("2006-01-02", stats.LastDay)
+			stats.CurrentStreak, stats.LongestStreak = battleStreaks(days, lastDay)
+		}
+
+		if err := s.userRepo.SetBattleStats(ctx, userID, stats); err != nil {
+			return modified, fmt.Errorf("error saving battle stats of %s: %v", userID.Hex(), err)
+		}
+		modified++
+	}
+
+	return modified, nil
+}
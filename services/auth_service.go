@@ -3,24 +3,73 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"movie-vs-backend/data_access"
 	"movie-vs-backend/helper"
 	"movie-vs-backend/models"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Token types carried in the "typ" claim. Only access tokens are accepted by AuthMiddleware.
+const (
+	TokenTypeAccess       = "access"
+	TokenTypeMFAChallenge = "mfa_challenge"
+)
+
+const (
+	mfaIssuer          = "Movie VS"
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaMaxAttempts     = 5
+	mfaBackupCodeCount = 10
+	// How long an account's role and disabled flag are cached for AuthMiddleware
+	accessCacheTTL = 30 * time.Second
+)
+
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+
 type AuthService struct {
-	userRepo  *data_access.UserRepository
-	jwtSecret string
+	userRepo       *data_access.UserRepository
+	mfaAttemptRepo *data_access.MFAAttemptRepository
+	keyService     *KeyService
+	guestService   *GuestService
+	adminEmails    map[string]bool
+
+	// Current role of recently seen accounts, keyed by user ID
+	access   map[string]*cachedAccess
+	accessMu sync.Mutex
+}
+
+// cachedAccess is an account's current role, empty if it may no longer use the API
+type cachedAccess struct {
+	role      string
+	expiresAt time.Time
 }
 
-func NewAuthService(userRepo *data_access.UserRepository, jwtSecret string) *AuthService {
+func NewAuthService(
+	userRepo *data_access.UserRepository,
+	mfaAttemptRepo *data_access.MFAAttemptRepository,
+	keyService *KeyService,
+	guestService *GuestService,
+	adminEmails []string,
+) *AuthService {
+	admins := make(map[string]bool)
+	for _, email := range adminEmails {
+		admins[strings.ToLower(email)] = true
+	}
+
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:       userRepo,
+		mfaAttemptRepo: mfaAttemptRepo,
+		keyService:     keyService,
+		guestService:   guestService,
+		adminEmails:    admins,
+		access:         make(map[string]*cachedAccess),
 	}
 }
 
@@ -43,7 +92,10 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 	user := &models.User{
 		Email:         req.Email,
 		Password:      string(hashedPassword),
+		Role:          s.roleForEmail(req.Email, models.RoleUser),
+		Privacy:       models.DefaultPrivacySettings(),
 		CreatedAt:     time.Now(),
+		LastLogin:     time.Now(),
 		MovieRankings: movieRankings,
 	}
 
@@ -55,38 +107,315 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 		return "", errors.New("invalid credentials")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	s.mergeGuest(ctx, req.GuestToken, user)
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	return s.generateToken(user)
+}
+
+// Login checks the password. Accounts with two-factor authentication get a short-lived
+// challenge token instead of an access token and finish the login with VerifyMFA.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil || user == nil {
+		return nil, errors.New("invalid credentials - email not found")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, errors.New("invalid credentials - password problem")
+	}
+
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	return s.finishLogin(ctx, user, req.GuestToken)
+}
+
+// finishLogin returns an access token for a user whose first factor checked out, or a
+// two-factor challenge if the account has MFA enabled
+func (s *AuthService) finishLogin(ctx context.Context, user *models.User, guestToken string) (*models.LoginResponse, error) {
+	if user.MFAEnabled {
+		// The guest token is sent again with the second step, see VerifyMFA
+		challenge, err := s.generateMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResponse{MFARequired: true, MFAToken: challenge}, nil
+	}
+
+	s.mergeGuest(ctx, guestToken, user)
+
+	token, err := s.issueLoginToken(ctx, user)
 	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{Token: token}, nil
+}
+
+// VerifyMFA completes a two-step login with a TOTP or backup code
+func (s *AuthService) VerifyMFA(ctx context.Context, req *models.MFALoginRequest) (string, error) {
+	token, err := jwt.Parse(req.MFAToken, s.keyService.Keyfunc)
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid or expired two-factor challenge")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != TokenTypeMFAChallenge {
+		return "", errors.New("invalid two-factor challenge")
+	}
+
+	jti, _ := claims["jti"].(string)
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil || jti == "" {
+		return "", errors.New("invalid two-factor challenge")
+	}
+
+	// Each challenge allows a handful of guesses before the user has to log in again. The
+	// guess is counted before the code is checked, so parallel requests can't exceed it.
+	reserved, err := s.mfaAttemptRepo.Reserve(ctx, jti, mfaMaxAttempts)
+	if err != nil {
+		return "", err
+	}
+	if !reserved {
+		return "", errors.New("too many attempts, please log in again")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil || !user.MFAEnabled || user.Disabled {
+		return "", errors.New("invalid two-factor challenge")
+	}
+
+	if err := s.checkMFACode(ctx, user, req.Code); err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	// The challenge can't be used for a second login
+	if err := s.mfaAttemptRepo.Delete(ctx, jti); err != nil {
+		return "", fmt.Errorf("error closing two-factor challenge: %v", err)
+	}
+
+	s.mergeGuest(ctx, req.GuestToken, user)
+
+	return s.issueLoginToken(ctx, user)
 }
 
-func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (string, error) {
-	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+// EnrollMFA creates a new TOTP secret for the user. It only becomes active after ConfirmMFA.
+func (s *AuthService) EnrollMFA(ctx context.Context, userID primitive.ObjectID) (*models.MFAEnrollResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := helper.GenerateTOTPSecret()
 	if err != nil {
-		return "", errors.New("invalid credentials - email not found")
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return "", errors.New("invalid credentials - password problem")
+	if err := s.userRepo.SetMFAPendingSecret(ctx, userID, secret); err != nil {
+		return nil, fmt.Errorf("error saving two-factor secret: %v", err)
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: helper.TOTPAuthURI(mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA activates the pending secret once the user proves their app produces valid
+// codes, and returns the backup codes. They are only ever shown this once.
+func (s *AuthService) ConfirmMFA(ctx context.Context, userID primitive.ObjectID, code string) (*models.MFABackupCodesResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAPendingSecret == "" {
+		return nil, errors.New("no two-factor enrolment in progress")
+	}
+
+	step := helper.ValidateTOTP(user.MFAPendingSecret, code, time.Now())
+	if step < 0 {
+		return nil, ErrInvalidMFACode
+	}
+
+	backupCodes, err := helper.GenerateBackupCodes(mfaBackupCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := make([]string, 0, len(backupCodes))
+	for _, backupCode := range backupCodes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(backupCode), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hashed = append(hashed, string(hash))
+	}
+
+	if err := s.userRepo.EnableMFA(ctx, userID, user.MFAPendingSecret, hashed, step); err != nil {
+		return nil, fmt.Errorf("error enabling two-factor authentication: %v", err)
+	}
+
+	return &models.MFABackupCodesResponse{BackupCodes: backupCodes}, nil
+}
+
+// DisableMFA turns two-factor authentication off after checking a current code
+func (s *AuthService) DisableMFA(ctx context.Context, userID primitive.ObjectID, code string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	if err := s.checkMFACode(ctx, user, code); err != nil {
+		return err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return s.userRepo.DisableMFA(ctx, userID)
+}
+
+// checkMFACode accepts an unused TOTP code or consumes one of the backup codes
+func (s *AuthService) checkMFACode(ctx context.Context, user *models.User, code string) error {
+	if step := helper.ValidateTOTP(user.MFASecret, code, time.Now()); step >= 0 {
+		fresh, err := s.userRepo.ConsumeMFAStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errors.New("two-factor code already used")
+		}
+		return nil
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(code))
+	for _, hashed := range user.MFABackupCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(normalized)) == nil {
+			removed, err := s.userRepo.RemoveMFABackupCode(ctx, user.ID, hashed)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				return errors.New("backup code already used")
+			}
+			fmt.Printf("User %s logged in with a backup code\n", user.ID.Hex())
+			return nil
+		}
+	}
+
+	return ErrInvalidMFACode
+}
+
+// generateMFAChallenge issues the token that proves the password step of a login succeeded
+func (s *AuthService) generateMFAChallenge(ctx context.Context, user *models.User) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	if err := s.mfaAttemptRepo.Create(ctx, jti, expiresAt); err != nil {
+		return "", err
+	}
+
+	return s.keyService.Sign(jwt.MapClaims{
 		"user_id": user.ID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"typ":     TokenTypeMFAChallenge,
+		"jti":     jti,
+		"exp":     expiresAt.Unix(),
 	})
+}
+
+// CurrentRole returns the role the user currently holds, or an empty role if the account
+// was deleted, disabled or is an expired guest. Tokens keep working for a day, so the
+// middleware checks this on each request instead of trusting the role in the token.
+func (s *AuthService) CurrentRole(ctx context.Context, userIDHex string) (string, error) {
+	now := time.Now()
+	s.accessMu.Lock()
+	cached, ok := s.access[userIDHex]
+	s.accessMu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.role, nil
+	}
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	userID, err := primitive.ObjectIDFromHex(userIDHex)
 	if err != nil {
-		return "", err
+		return "", nil
+	}
+	user, err := s.userRepo.FindAccess(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("error finding user: %v", err)
+	}
+
+	role := ""
+	if user != nil && !user.Disabled && (user.GuestExpiresAt == nil || now.Before(*user.GuestExpiresAt)) {
+		role = user.Role
+		if role == "" {
+			role = models.RoleUser
+		}
+	}
+
+	s.accessMu.Lock()
+	// Forget accounts that haven't been seen for a while
+	for key, entry := range s.access {
+		if now.After(entry.expiresAt) {
+			delete(s.access, key)
+		}
+	}
+	s.access[userIDHex] = &cachedAccess{role: role, expiresAt: now.Add(accessCacheTTL)}
+	s.accessMu.Unlock()
+
+	return role, nil
+}
+
+// roleForEmail returns the admin role for emails listed in ADMIN_EMAILS, otherwise the current
+// role. It only applies when an account is created, so admins can later demote those accounts.
+func (s *AuthService) roleForEmail(email string, current string) string {
+	if s.adminEmails[strings.ToLower(email)] {
+		return models.RoleAdmin
+	}
+	return current
+}
+
+// mergeGuest carries a guest's rankings into the user when a guest token came with the
+// request. A failed merge doesn't fail the login, the guest can still be merged later.
+func (s *AuthService) mergeGuest(ctx context.Context, guestToken string, user *models.User) {
+	if guestToken == "" {
+		return
+	}
+	if err := s.guestService.MergeInto(ctx, guestToken, user.ID); err != nil {
+		fmt.Printf("Error merging guest into user %s: %v\n", user.ID.Hex(), err)
+	}
+}
+
+// issueLoginToken records the login time and returns a new access token
+func (s *AuthService) issueLoginToken(ctx context.Context, user *models.User) (string, error) {
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID, time.Now()); err != nil {
+		return "", fmt.Errorf("error updating last login: %v", err)
+	}
+	return s.generateToken(user)
+}
+
+// generateToken issues a signed access token carrying the user's ID and role
+func (s *AuthService) generateToken(user *models.User) (string, error) {
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
 	}
 
-	return tokenString, nil
+	return signAccessToken(s.keyService, user.ID, role, time.Now().Add(time.Hour*24))
+}
+
+// signAccessToken issues the token AuthMiddleware accepts
+func signAccessToken(keyService *KeyService, userID primitive.ObjectID, role string, expiresAt time.Time) (string, error) {
+	return keyService.Sign(jwt.MapClaims{
+		"user_id": userID.Hex(),
+		"role":    role,
+		"typ":     TokenTypeAccess,
+		"exp":     expiresAt.Unix(),
+	})
 }
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+func TestVerifyMFACountsAttemptFirst(t *testing.T) {
+	keyService := newTestKeyService(t)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	userID := primitive.NewObjectID()
+	usedUp := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0})
+	counted := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+	user := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "role", Value: models.RoleUser},
+		{Key: "mfa_enabled", Value: true},
+		{Key: "mfa_secret", Value: "JBSWY3DPEHPK3PXP"},
+	})
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		wantErr   string
+		commands  []string
+	}{
+		{
+			name:      "used up challenge",
+			responses: []bson.D{usedUp},
+			wantErr:   "too many attempts",
+			commands:  []string{"update"},
+		},
+		{
+			name:      "wrong code",
+			responses: []bson.D{counted, user},
+			wantErr:   ErrInvalidMFACode.Error(),
+			commands:  []string{"update", "find"},
+		},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+
+			db := data_access.WrapMongoDB(mt.Client, "movievs")
+			service := NewAuthService(data_access.NewUserRepository(db), data_access.NewMFAAttemptRepository(db), keyService, nil, nil)
+			challenge, err := keyService.Sign(jwt.MapClaims{
+				"user_id": userID.Hex(),
+				"typ":     TokenTypeMFAChallenge,
+				"jti":     "challenge",
+				"exp":     time.Now().Add(mfaChallengeTTL).Unix(),
+			})
+			if err != nil {
+				mt.Fatal(err)
+			}
+
+			_, err = service.VerifyMFA(context.Background(), &models.MFALoginRequest{MFAToken: challenge, Code: "000000x"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				mt.Errorf("VerifyMFA error = %v, want %q", err, tt.wantErr)
+			}
+			if got := strings.Join(startedCommands(mt), ","); got != strings.Join(tt.commands, ",") {
+				mt.Errorf("commands = %q, want %q", got, strings.Join(tt.commands, ","))
+			}
+		})
+	}
+}
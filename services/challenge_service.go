@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// Layout of the UTC day a daily challenge belongs to
+const challengeDateLayout = "2006-01-02"
+
+var (
+	ErrCatalogTooSmall      = errors.New("not enough catalog movies for a daily challenge")
+	ErrInvalidChallengeVote = errors.New("pair or winner is not part of today's challenge")
+	ErrAlreadyVoted         = errors.New("already voted on this pair")
+)
+
+// ChallengeService hands every user the same matchups each day and tallies the
+// community's picks
+type ChallengeService struct {
+	challengeRepo *data_access.ChallengeRepository
+	movieRepo     *data_access.MovieRepository
+	pairs         int
+}
+
+func NewChallengeService(challengeRepo *data_access.ChallengeRepository, movieRepo *data_access.MovieRepository, pairs int) *ChallengeService {
+	return &ChallengeService{
+		challengeRepo: challengeRepo,
+		movieRepo:     movieRepo,
+		pairs:         pairs,
+	}
+}
+
+// GetToday returns today's challenge with the user's picks, and the community's split on
+// the pairs the user has already picked
+func (s *ChallengeService) GetToday(ctx context.Context, userID primitive.ObjectID) (*models.DailyChallengeResponse, error) {
+	challenge, err := s.ensureChallenge(ctx, challengeDate(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := s.challengeRepo.FindVotes(ctx, userID, []string{challenge.Date})
+	if err != nil {
+		return nil, err
+	}
+	return toChallengeResponse(challenge, votes, false), nil
+}
+
+// Vote records the user's pick on a pair of today's challenge
+func (s *ChallengeService) Vote(ctx context.Context, userID primitive.ObjectID, req *models.ChallengeVoteRequest) (*models.DailyChallengeResponse, error) {
+	challenge, err := s.ensureChallenge(ctx, challengeDate(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	if req.Pair > len(challenge.Pairs) {
+		return nil, ErrInvalidChallengeVote
+	}
+	pair := challenge.Pairs[req.Pair-1]
+	if req.WinnerTitle != pair.MovieA.Title && req.WinnerTitle != pair.MovieB.Title {
+		return nil, ErrInvalidChallengeVote
+	}
+
+	vote := &models.ChallengeVote{
+		UserID:      userID,
+		Date:        challenge.Date,
+		Pair:        pair.Index,
+		WinnerTitle: req.WinnerTitle,
+		CreatedAt:   time.Now(),
+	}
+	recorded, err := s.challengeRepo.RecordVote(ctx, vote, req.WinnerTitle == pair.MovieA.Title)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		return nil, ErrAlreadyVoted
+	}
+
+	return s.GetToday(ctx, userID)
+}
+
+// GetHistory returns a page of past challenges with their final results and the user's picks
+func (s *ChallengeService) GetHistory(ctx context.Context, userID primitive.ObjectID, page, pageSize int) (*models.ChallengeHistoryResponse, error) {
+	challenges, total, err := s.challengeRepo.ListBefore(ctx, challengeDate(time.Now()), int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]string, 0, len(challenges))
+	for _, challenge := range challenges {
+		dates = append(dates, challenge.Date)
+	}
+	votes, err := s.challengeRepo.FindVotes(ctx, userID, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.DailyChallengeResponse, 0, len(challenges))
+	for i := range challenges {
+		responses = append(responses, *toChallengeResponse(&challenges[i], votes, true))
+	}
+
+	return &models.ChallengeHistoryResponse{
+		Challenges: responses,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// ensureChallenge returns the day's challenge, generating and storing it on first use.
+// Concurrent first requests store only one of their identical challenges.
+func (s *ChallengeService) ensureChallenge(ctx context.Context, date string) (*models.DailyChallenge, error) {
+	challenge, err := s.challengeRepo.FindByDate(ctx, date)
+	if err != nil {
+		return nil, fmt.Errorf("error finding daily challenge: %v", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	catalog, _, err := s.movieRepo.ListCatalog(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	pairs := generateChallengePairs(date, catalog, s.pairs)
+	if len(pairs) == 0 {
+		return nil, ErrCatalogTooSmall
+	}
+
+	return s.challengeRepo.CreateIfMissing(ctx, &models.DailyChallenge{
+		Date:      date,
+		Pairs:     pairs,
+		CreatedAt: time.Now(),
+	})
+}
+
+// generateChallengePairs picks the day's pairs from the catalog, ordered by title, with a
+// random source seeded by the date so that the same day always gives the same pairs
+func generateChallengePairs(date string, catalog []models.Movie, count int) []models.ChallengePair {
+	hash := fnv.New64a()
+	hash.Write([]byte(date))
+	random := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+	order := random.Perm(len(catalog))
+	if count > len(order)/2 {
+		count = len(order) / 2
+	}
+
+	pairs := make([]models.ChallengePair, 0, count)
+	for i := 0; i < count; i++ {
+		pairs = append(pairs, models.ChallengePair{
+			Index:  i + 1,
+			MovieA: catalog[order[2*i]],
+			MovieB: catalog[order[2*i+1]],
+		})
+	}
+	return pairs
+}
+
+func challengeDate(t time.Time) string {
+	return t.UTC().Format(challengeDateLayout)
+}
+
+// toChallengeResponse adds the user's picks to a challenge. The community's split is shown
+// for every pair once the day is over, and before that only for pairs the user has picked.
+func toChallengeResponse(challenge *models.DailyChallenge, votes []models.ChallengeVote, past bool) *models.DailyChallengeResponse {
+	picks := make(map[int]string)
+	for _, vote := range votes {
+		if vote.Date == challenge.Date {
+			picks[vote.Pair] = vote.WinnerTitle
+		}
+	}
+
+	response := &models.DailyChallengeResponse{
+		Date:      challenge.Date,
+		Pairs:     make([]models.ChallengePairResult, 0, len(challenge.Pairs)),
+		Completed: len(challenge.Pairs) > 0,
+	}
+	for _, pair := range challenge.Pairs {
+		result := models.ChallengePairResult{
+			Index:  pair.Index,
+			MovieA: pair.MovieA,
+			MovieB: pair.MovieB,
+			Pick:   picks[pair.Index],
+		}
+		if result.Pick == "" {
+			response.Completed = false
+		}
+		if past || result.Pick != "" {
+			result.Results = challengeResults(pair)
+		}
+		response.Pairs = append(response.Pairs, result)
+	}
+	return response
+}
+
+func challengeResults(pair models.ChallengePair) *models.ChallengeResults {
+	results := &models.ChallengeResults{
+		VotesA: pair.VotesA,
+		VotesB: pair.VotesB,
+		Total:  pair.VotesA + pair.VotesB,
+	}
+	if results.Total > 0 {
+		results.PercentA = math.Round(float64(pair.VotesA)/float64(results.Total)*1000) / 10
+		results.PercentB = math.Round((100-results.PercentA)*10) / 10
+	}
+	return results
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"movie-vs-backend/models"
+)
+
+func testCatalog(size int) []models.Movie {
+	catalog := make([]models.Movie, size)
+	for i := range catalog {
+		catalog[i] = models.Movie{Title: fmt.Sprintf("Movie %02d", i)}
+	}
+	return catalog
+}
+
+func TestGenerateChallengePairs(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      string
+		catalog   int
+		count     int
+		wantPairs int
+	}{
+		{name: "full day", date: "2026-10-18", catalog: 40, count: 5, wantPairs: 5},
+		{name: "small catalog", date: "2026-10-18", catalog: 7, count: 5, wantPairs: 3},
+		{name: "single movie", date: "2026-10-18", catalog: 1, count: 5, wantPairs: 0},
+		{name: "leap day", date: "2028-02-29", catalog: 40, count: 10, wantPairs: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs := generateChallengePairs(tt.date, testCatalog(tt.catalog), tt.count)
+			if len(pairs) != tt.wantPairs {
+				t.Fatalf("got %d pairs, want %d", len(pairs), tt.wantPairs)
+			}
+
+			// Same date, same pairs, even from a fresh copy of the catalog
+			if again := generateChallengePairs(tt.date, testCatalog(tt.catalog), tt.count); !reflect.DeepEqual(pairs, again) {
+				t.Errorf("pairs for %s differ between runs", tt.date)
+			}
+
+			seen := make(map[string]bool)
+			for i, pair := range pairs {
+				if pair.Index != i+1 {
+					t.Errorf("pair %d has index %d", i, pair.Index)
+				}
+				for _, title := range []string{pair.MovieA.Title, pair.MovieB.Title} {
+					if seen[title] {
+						t.Errorf("%s appears twice", title)
+					}
+					seen[title] = true
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateChallengePairsVaryByDate(t *testing.T) {
+	catalog := testCatalog(40)
+	today := generateChallengePairs("2026-10-18", catalog, 5)
+	tomorrow := generateChallengePairs("2026-10-19", catalog, 5)
+	if reflect.DeepEqual(today, tomorrow) {
+		t.Error("consecutive days got the same pairs")
+	}
+}
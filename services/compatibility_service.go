@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+const (
+	// Fewest movies both users must have battled before their rankings are compared
+	minSharedMovies = 3
+	// Weight of the rank correlation in the combined score, the rest goes to head-to-heads
+	rankCorrelationWeight = 0.7
+)
+
+var (
+	ErrRankingsPrivate  = errors.New("user's rankings are private")
+	ErrCompareWithSelf  = errors.New("cannot compare a user with themselves")
+	ErrNotEnoughRatings = errors.New("not enough battled movies to compare")
+)
+
+type CompatibilityService struct {
+	userRepo   *data_access.UserRepository
+	battleRepo *data_access.BattleRepository
+	followRepo *data_access.FollowRepository
+}
+
+func NewCompatibilityService(userRepo *data_access.UserRepository, battleRepo *data_access.BattleRepository, followRepo *data_access.FollowRepository) *CompatibilityService {
+	return &CompatibilityService{
+		userRepo:   userRepo,
+		battleRepo: battleRepo,
+		followRepo: followRepo,
+	}
+}
+
+// Compare computes how well the viewer's taste matches another user's
+func (s *CompatibilityService) Compare(ctx context.Context, viewerID, otherID primitive.ObjectID) (*models.CompatibilityResponse, error) {
+	if viewerID == otherID {
+		return nil, ErrCompareWithSelf
+	}
+
+	viewer, err := s.userRepo.FindRankedUser(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if viewer == nil {
+		return nil, ErrUserNotFound
+	}
+
+	other, err := s.userRepo.FindRankedUser(ctx, otherID)
+	if err != nil {
+		return nil, err
+	}
+	if other == nil || other.Disabled || other.Role == models.RoleGuest {
+		return nil, ErrUserNotFound
+	}
+	visible, err := canViewRankings(ctx, s.followRepo, other, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrRankingsPrivate
+	}
+
+	response := &models.CompatibilityResponse{
+		UserID:      other.ID,
+		DisplayName: other.DisplayName,
+	}
+
+	correlation, shared := rankCorrelation(viewer.MovieRankings, other.MovieRankings)
+	response.SharedMovies = shared
+	if shared >= minSharedMovies {
+		response.RankCorrelation = &correlation
+	}
+
+	viewerHeadToHeads, err := s.battleRepo.FindHeadToHeads(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	otherHeadToHeads, err := s.battleRepo.FindHeadToHeads(ctx, otherID)
+	if err != nil {
+		return nil, err
+	}
+	agreement, sharedPairs := headToHeadAgreement(viewerHeadToHeads, otherHeadToHeads)
+	response.SharedHeadToHeads = sharedPairs
+	if sharedPairs > 0 {
+		response.HeadToHeadAgreement = &agreement
+	}
+
+	switch {
+	case response.RankCorrelation != nil && response.HeadToHeadAgreement != nil:
+		score := rankCorrelationWeight*correlationScore(correlation) + (1-rankCorrelationWeight)*agreement*100
+		response.Score = &score
+	case response.RankCorrelation != nil:
+		score := correlationScore(correlation)
+		response.Score = &score
+	case response.HeadToHeadAgreement != nil:
+		score := agreement * 100
+		response.Score = &score
+	}
+
+	return response, nil
+}
+
+// SimilarUsers lists the discoverable users whose rankings correlate best with the
+// viewer's. Only rank correlation is used, head-to-heads would need every user's battles.
+func (s *CompatibilityService) SimilarUsers(ctx context.Context, viewerID primitive.ObjectID, minShared, limit int) (*models.SimilarUsersResponse, error) {
+	if minShared < minSharedMovies {
+		minShared = minSharedMovies
+	}
+
+	viewer, err := s.userRepo.FindRankedUser(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if viewer == nil {
+		return nil, ErrUserNotFound
+	}
+	if len(viewer.MovieRankings) < minShared {
+		return nil, ErrNotEnoughRatings
+	}
+
+	similar := []models.SimilarUser{}
+	err = s.userRepo.ForEachDiscoverableUser(ctx, func(user *models.User) {
+		if user.ID == viewerID {
+			return
+		}
+		correlation, shared := rankCorrelation(viewer.MovieRankings, user.MovieRankings)
+		if shared < minShared {
+			return
+		}
+		similar = append(similar, models.SimilarUser{
+			UserID:          user.ID,
+			DisplayName:     user.DisplayName,
+			AvatarURL:       user.AvatarURL,
+			Score:           correlationScore(correlation),
+			RankCorrelation: correlation,
+			SharedMovies:    shared,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Best match first, more shared movies breaks ties
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].SharedMovies > similar[j].SharedMovies
+	})
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+
+	return &models.SimilarUsersResponse{Users: similar}, nil
+}
+
+// correlationScore maps a correlation of -1..1 onto a 0..100 score
+func correlationScore(correlation float64) float64 {
+	return (correlation + 1) / 2 * 100
+}
+
+// rankCorrelation returns the Spearman rank correlation of the ELO ratings of the movies
+// in both ranking lists, and how many movies that is
+func rankCorrelation(a, b []models.MovieRanking) (float64, int) {
+	ratingsA := make(map[string]float64, len(a))
+	for _, ranking := range a {
+		ratingsA[ranking.MovieTitle] = float64(ranking.ELORating)
+	}
+
+	var xs, ys []float64
+	for _, ranking := range b {
+		if rating, ok := ratingsA[ranking.MovieTitle]; ok {
+			xs = append(xs, rating)
+			ys = append(ys, float64(ranking.ELORating))
+		}
+	}
+	if len(xs) < 2 {
+		return 0, len(xs)
+	}
+
+	return pearson(fractionalRanks(xs), fractionalRanks(ys)), len(xs)
+}
+
+// fractionalRanks ranks the values from 1, tied values share the average of their ranks
+func fractionalRanks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return ranks
+}
+
+// pearson returns the correlation coefficient of two equally long series, 0 when either
+// series is constant
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// headToHeadAgreement returns the share of movie pairs both users have battled where they
+// picked the same winner, and how many pairs that is
+func headToHeadAgreement(a, b []models.HeadToHead) (float64, int) {
+	winners := make(map[[2]string]string, len(a))
+	for _, h := range a {
+		winners[[2]string{h.Low, h.High}] = h.Winner
+	}
+
+	var shared, agreed int
+	for _, h := range b {
+		winner, ok := winners[[2]string{h.Low, h.High}]
+		if !ok {
+			continue
+		}
+		shared++
+		if winner == h.Winner {
+			agreed++
+		}
+	}
+	if shared == 0 {
+		return 0, 0
+	}
+	return float64(agreed) / float64(shared), shared
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+const (
+	dashboardRecentDays    = 30
+	dashboardWeeks         = 12
+	dashboardFavorites     = 5
+	dashboardControversial = 10
+	// Movies need this many battles before their record can count as controversial
+	dashboardControversialMinMatches = 6
+)
+
+// DashboardService summarises a user's battles and rankings for their stats page
+type DashboardService struct {
+	movieRepo  *data_access.MovieRepository
+	battleRepo *data_access.BattleRepository
+}
+
+func NewDashboardService(movieRepo *data_access.MovieRepository, battleRepo *data_access.BattleRepository) *DashboardService {
+	return &DashboardService{
+		movieRepo:  movieRepo,
+		battleRepo: battleRepo,
+	}
+}
+
+// GetDashboard returns the user's activity, catalog coverage, favourite genres, directors
+// and actors, most evenly split movies and how settled their ratings have become
+func (s *DashboardService) GetDashboard(ctx context.Context, userID primitive.ObjectID) (*models.DashboardResponse, error) {
+	days, err := s.battleRepo.FindBattleDays(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	catalogMovies, err := s.movieRepo.CountCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	movement, err := s.battleRepo.FindRatingMovement(ctx, userID, dashboardWeeks)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.DashboardResponse{
+		Coverage:  models.Coverage{CatalogMovies: catalogMovies},
+		Stability: make([]models.RatingMovement, 0, len(movement)),
+	}
+	addActivity(response, days, time.Now())
+	for i := len(movement) - 1; i >= 0; i-- {
+		movement[i].AverageChange = math.Round(movement[i].AverageChange*10) / 10
+		response.Stability = append(response.Stability, movement[i])
+	}
+
+	genres := newAffinityTally()
+	directors := newAffinityTally()
+	actors := newAffinityTally()
+	var controversial []models.MovieRanking
+	err = s.battleRepo.ForEachRankedMovie(ctx, userID, func(movie *models.RankedMovie) error {
+		ranking := movie.Ranking
+		response.Coverage.SeenMovies++
+		if ranking.MatchCount == 0 {
+			return nil
+		}
+		response.Coverage.BattledMovies++
+
+		genres.add(ranking.Genres, ranking)
+		if movie.Movie != nil {
+			directors.add(splitNames(movie.Movie.Director), ranking)
+			actors.add(splitNames(movie.Movie.Actors), ranking)
+		}
+		if ranking.MatchCount >= dashboardControversialMinMatches {
+			controversial = append(controversial, ranking)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if catalogMovies > 0 {
+		percent := float64(response.Coverage.BattledMovies) / float64(catalogMovies) * 100
+		response.Coverage.Percent = math.Round(math.Min(percent, 100)*10) / 10
+	}
+	response.FavoriteGenres = genres.top(dashboardFavorites)
+	response.FavoriteDirectors = directors.top(dashboardFavorites)
+	response.FavoriteActors = actors.top(dashboardFavorites)
+	response.Controversial = mostControversial(controversial, dashboardControversial)
+
+	return response, nil
+}
+
+// addActivity fills in the battle totals and daily averages, and the recent days up to
+// today including those without battles
+func addActivity(response *models.DashboardResponse, days []models.BattleDay, now time.Time) {
+	perDay := make(map[string]int, len(days))
+	for _, day := range days {
+		response.Battles += day.Battles
+		perDay[day.Date] = day.Battles
+	}
+	response.ActiveDays = len(days)
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	if len(days) > 0 {
+		response.BattlesPerActiveDay = math.Round(float64(response.Battles)/float64(len(days))*10) / 10
+		if first, err := time.Parse("2006-01-02", days[0].Date); err == nil {
+			span := int(today.Sub(first)/(24*time.Hour)) + 1
+			response.BattlesPerDay = math.Round(float64(response.Battles)/float64(span)*10) / 10
+		}
+	}
+
+	response.RecentDays = make([]models.BattleDay, 0, dashboardRecentDays)
+	for i := dashboardRecentDays - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format("2006-01-02")
+		response.RecentDays = append(response.RecentDays, models.BattleDay{Date: date, Battles: perDay[date]})
+	}
+}
+
+// affinityTally adds up the battles of the movies sharing a genre, director or actor
+type affinityTally map[string]*models.Affinity
+
+func newAffinityTally() affinityTally {
+	return make(affinityTally)
+}
+
+func (t affinityTally) add(names []string, ranking models.MovieRanking) {
+	for _, name := range names {
+		affinity, ok := t[name]
+		if !ok {
+			affinity = &models.Affinity{Name: name}
+			t[name] = affinity
+		}
+		affinity.Wins += ranking.WinCount
+		affinity.Matches += ranking.MatchCount
+		affinity.Movies++
+	}
+}
+
+// top returns the entries with the most wins, ties broken by win rate
+func (t affinityTally) top(limit int) []models.Affinity {
+	affinities := make([]models.Affinity, 0, len(t))
+	for _, affinity := range t {
+		if affinity.Matches > 0 {
+			affinity.WinRate = math.Round(float64(affinity.Wins)/float64(affinity.Matches)*1000) / 1000
+		}
+		affinities = append(affinities, *affinity)
+	}
+	sort.Slice(affinities, func(i, j int) bool {
+		a, b := affinities[i], affinities[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.WinRate != b.WinRate {
+			return a.WinRate > b.WinRate
+		}
+		return a.Name < b.Name
+	})
+	if len(affinities) > limit {
+		affinities = affinities[:limit]
+	}
+	return affinities
+}
+
+// splitNames splits the comma separated director or cast list of a catalog movie
+func splitNames(names string) []string {
+	var result []string
+	for _, part := range strings.Split(names, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" || strings.EqualFold(name, "N/A") {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// mostControversial returns the movies whose wins and losses are closest to even relative
+// to their battles, the most battled first among equally split ones
+func mostControversial(rankings []models.MovieRanking, limit int) []models.MovieRanking {
+	balance := func(ranking models.MovieRanking) float64 {
+		return math.Abs(float64(ranking.WinCount-ranking.LossCount)) / float64(ranking.MatchCount)
+	}
+	sort.Slice(rankings, func(i, j int) bool {
+		a, b := rankings[i], rankings[j]
+		if balance(a) != balance(b) {
+			return balance(a) < balance(b)
+		}
+		if a.MatchCount != b.MatchCount {
+			return a.MatchCount > b.MatchCount
+		}
+		return a.MovieTitle < b.MovieTitle
+	})
+	if len(rankings) > limit {
+		rankings = rankings[:limit]
+	}
+	if rankings == nil {
+		rankings = []models.MovieRanking{}
+	}
+	return rankings
+}
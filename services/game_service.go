@@ -2,19 +2,22 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
 	"movie-vs-backend/models"
 )
 
@@ -25,6 +28,8 @@ type GameService struct {
 	userRepo   *data_access.UserRepository
 	userStates map[primitive.ObjectID]*models.UserBattleState
 	stateMutex sync.RWMutex
+
+	achievementService *AchievementService
 }
 
 func NewGameService(
@@ -33,12 +38,14 @@ func NewGameService(
 	movieRepo *data_access.MovieRepository,
 	battleRepo *data_access.BattleRepository,
 	userRepo *data_access.UserRepository,
+	achievementService *AchievementService,
 ) *GameService {
 	return &GameService{
-		omdbClient: data_access.NewOMDBClient(omdbAPIKey, omdbBaseURL),
-		movieRepo:  movieRepo,
-		battleRepo: battleRepo,
-		userRepo:   userRepo,
+		omdbClient:         data_access.NewOMDBClient(omdbAPIKey, omdbBaseURL),
+		movieRepo:          movieRepo,
+		battleRepo:         battleRepo,
+		userRepo:           userRepo,
+		achievementService: achievementService,
 	}
 }
 
@@ -102,7 +109,11 @@ func (s *GameService) getRandomMovieWithRetries(maxRetries int) (*models.Movie,
 	return movie, nil
 }
 
-func (s *GameService) GetBattlePair(ctx context.Context, userID primitive.ObjectID) (*models.BattleResponse, error) {
+func (s *GameService) GetBattlePair(ctx context.Context, userID primitive.ObjectID, genre string) (*models.BattleResponse, error) {
+	// Genre-only battles draw both movies from the user's rankings in that genre
+	if genre != "" {
+		return s.getGenreBattlePair(ctx, userID, genre)
+	}
 
 	fmt.Printf("GetBattlePair called for user %v at %v\n", userID, time.Now())
 
@@ -363,7 +374,7 @@ func (s *GameService) GetBattlePair(ctx context.Context, userID primitive.Object
 		fmt.Println("XXXXXXXXXXXXXXXXXXXXXXX Restarting flow...XXXXXXXXXXXXXXXXXXXXXXXXXXXXXX")
 		// Release the mutex before recursive call to avoid deadlock
 		s.stateMutex.Unlock()
-		return s.GetBattlePair(ctx, userID)
+		return s.GetBattlePair(ctx, userID, "")
 	}
 	// Both movies exist in MongoDB, safe to proceed
 	movieDetailsA.ID = movieAFromMongo.ID
@@ -377,78 +388,444 @@ func (s *GameService) GetBattlePair(ctx context.Context, userID primitive.Object
 	}, nil
 }
 
-// SubmitBattle handles the submission of a battle result
-func (s *GameService) SubmitBattle(ctx context.Context, userID primitive.ObjectID, req *models.SubmitBattleRequest) error {
-	// Create a new battle record
-	battle := &models.Battle{
-		MovieA: req.MovieA,
-		MovieB: req.MovieB,
-		Winner: req.Winner,
+// ErrNotEnoughGenreMovies is returned when a genre-only battle is requested for a genre
+// with fewer than two ranked movies
+var ErrNotEnoughGenreMovies = errors.New("not enough movies in genre")
+
+// getGenreBattlePair picks two random movies of the user's rankings in the genre and
+// fetches their details from OMDB
+func (s *GameService) getGenreBattlePair(ctx context.Context, userID primitive.ObjectID, genre string) (*models.BattleResponse, error) {
+	// Sample a few extra in case some titles are not found in OMDB
+	rankings, err := s.battleRepo.SampleRankings(ctx, userID, genre, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	var movies []models.Movie
+	for _, ranking := range rankings {
+		movie, err := s.FetchMovieFromOMDB(ctx, ranking.MovieTitle)
+		if err != nil {
+			fmt.Printf("Error getting %s for genre battle: %v\n", ranking.MovieTitle, err)
+			continue
+		}
+		movie.ID = ranking.MovieID
+		movies = append(movies, *movie)
+		if len(movies) == 2 {
+			return &models.BattleResponse{
+				MovieA: movies[0],
+				MovieB: movies[1],
+			}, nil
+		}
 	}
 
-	// Elo constants (determines how much ratings can change after a single match/battle.)
-	const K = 32.0
+	return nil, ErrNotEnoughGenreMovies
+}
+
+// SubmitBattle handles the submission of a battle result and returns the achievements it
+// unlocked
+func (s *GameService) SubmitBattle(ctx context.Context, userID primitive.ObjectID, req *models.SubmitBattleRequest) ([]models.AchievementProgress, error) {
+	return s.SubmitBattleFrom(ctx, userID, req, "")
+}
 
-	var winner, loser *models.Movie
-	if battle.Winner.Title == battle.MovieA.Title {
-		winner = &battle.MovieA
-		loser = &battle.MovieB
-	} else {
-		winner = &battle.MovieB
-		loser = &battle.MovieA
+// SubmitBattleFrom records a battle played elsewhere, e.g. models.BattleSourceTournament
+func (s *GameService) SubmitBattleFrom(ctx context.Context, userID primitive.ObjectID, req *models.SubmitBattleRequest, source string) ([]models.AchievementProgress, error) {
+	result := pairResult{winner: 0, loser: 1}
+	if req.Winner.Title != req.MovieA.Title {
+		result = pairResult{winner: 1, loser: 0}
 	}
 
-	// Load current Elo ratings
-	winnerRanking, err := s.battleRepo.GetMovieRanking(ctx, userID, winner.ID)
+	applied, err := s.applyResults(ctx, userID, []models.Movie{req.MovieA, req.MovieB}, []pairResult{result}, source)
 	if err != nil {
-		return fmt.Errorf("error getting winner ranking: %v", err)
+		return nil, err
+	}
+	return applied.achievements, nil
+}
+
+// ErrInvalidRound is returned when a multi-way round's order or favourite doesn't match its movies
+var ErrInvalidRound = errors.New("invalid round result")
+
+// GetRound serves between MinRoundSize and MaxRoundSize random movies from the user's
+// rankings, of the given genre unless it is empty
+func (s *GameService) GetRound(ctx context.Context, userID primitive.ObjectID, size int, genre string) (*models.RoundResponse, error) {
+	if size < models.MinRoundSize || size > models.MaxRoundSize {
+		return nil, ErrInvalidRound
 	}
 
-	loserRanking, err := s.battleRepo.GetMovieRanking(ctx, userID, loser.ID)
+	// Sample a few extra in case some titles are not found in OMDB
+	rankings, err := s.battleRepo.SampleRankings(ctx, userID, genre, size+3)
 	if err != nil {
-		return fmt.Errorf("error getting loser ranking: %v", err)
+		return nil, err
+	}
+
+	movies := []models.Movie{}
+	for _, ranking := range rankings {
+		movie, err := s.FetchMovieFromOMDB(ctx, ranking.MovieTitle)
+		if err != nil {
+			fmt.Printf("Error getting %s for round: %v\n", ranking.MovieTitle, err)
+			continue
+		}
+		movie.ID = ranking.MovieID
+		movies = append(movies, *movie)
+		if len(movies) == size {
+			return &models.RoundResponse{Movies: movies}, nil
+		}
 	}
 
-	// Elo math
-	// ra
-	currentWinnerRanking := float64(winnerRanking.ELORating)
-	// rb
-	currentLoserRanking := float64(loserRanking.ELORating)
+	if genre != "" {
+		return nil, ErrNotEnoughGenreMovies
+	}
+	return nil, fmt.Errorf("only found %d of %d movies for round", len(movies), size)
+}
 
-	ea := 1.0 / (1.0 + math.Pow(10, (currentLoserRanking-currentWinnerRanking)/400))
-	eb := 1.0 / (1.0 + math.Pow(10, (currentWinnerRanking-currentLoserRanking)/400))
+// roundResults translates a round's order or favourite into the pairwise results it stands for
+func roundResults(req *models.SubmitRoundRequest) ([]pairResult, error) {
+	positions := make(map[string]int, len(req.Movies))
+	for i, movie := range req.Movies {
+		if movie.Title == "" {
+			return nil, ErrInvalidRound
+		}
+		if _, duplicate := positions[movie.Title]; duplicate {
+			return nil, ErrInvalidRound
+		}
+		positions[movie.Title] = i
+	}
 
-	newWinnerRanking := currentWinnerRanking + K*(1-ea)
-	newLoserRanking := currentLoserRanking + K*(0-eb)
+	var results []pairResult
+	switch {
+	case len(req.Order) > 0 && req.Favorite == "":
+		if len(req.Order) != len(req.Movies) {
+			return nil, ErrInvalidRound
+		}
+		order := make([]int, 0, len(req.Order))
+		seen := make(map[string]bool, len(req.Order))
+		for _, title := range req.Order {
+			position, ok := positions[title]
+			if !ok || seen[title] {
+				return nil, ErrInvalidRound
+			}
+			seen[title] = true
+			order = append(order, position)
+		}
+		for i := 0; i < len(order); i++ {
+			for j := i + 1; j < len(order); j++ {
+				results = append(results, pairResult{winner: order[i], loser: order[j]})
+			}
+		}
+	case len(req.Order) == 0 && req.Favorite != "":
+		favorite, ok := positions[req.Favorite]
+		if !ok {
+			return nil, ErrInvalidRound
+		}
+		for i := range req.Movies {
+			if i != favorite {
+				results = append(results, pairResult{winner: favorite, loser: i})
+			}
+		}
+	default:
+		return nil, ErrInvalidRound
+	}
+	return results, nil
+}
 
-	// Update winner ranking
-	winnerRanking.ELORating = int(newWinnerRanking)
-	winnerRanking.MovieTitle = winner.Title
-	winnerRanking.MatchCount++
-	winnerRanking.WinCount++
-	winnerRanking.LastUpdated = time.Now()
+// SubmitRound rates a multi-way round. A full order counts as every pair of movies won by
+// the one ranked higher; a favourite counts as the favourite beating each other movie.
+func (s *GameService) SubmitRound(ctx context.Context, userID primitive.ObjectID, req *models.SubmitRoundRequest) (*models.SubmitRoundResponse, error) {
+	results, err := roundResults(req)
+	if err != nil {
+		return nil, err
+	}
 
-	// Update loser ranking
-	loserRanking.ELORating = int(newLoserRanking)
-	loserRanking.MovieTitle = loser.Title
-	loserRanking.MatchCount++
-	loserRanking.LossCount++
-	loserRanking.LastUpdated = time.Now()
+	rankings, err := s.applyResults(ctx, userID, req.Movies, results, "")
+	if err != nil {
+		return nil, err
+	}
 
-	winnerJSON, _ := json.MarshalIndent(winnerRanking, "", "  ")
-	loserJSON, _ := json.MarshalIndent(loserRanking, "", "  ")
-	fmt.Printf("THIS IS THE WINNER:\n%s\n", string(winnerJSON))
-	fmt.Printf("THIS IS THE LOSER:\n%s\n", string(loserJSON))
+	response := &models.SubmitRoundResponse{
+		SubmissionID: rankings.submissionID,
+		Results:      make([]models.PairwiseResult, 0, len(results)),
+		Rankings:     make([]models.MovieRanking, 0, len(rankings.rankings)),
+		Achievements: rankings.achievements,
+	}
+	for _, result := range results {
+		response.Results = append(response.Results, models.PairwiseResult{
+			Winner: req.Movies[result.winner].Title,
+			Loser:  req.Movies[result.loser].Title,
+		})
+	}
+	for _, ranking := range rankings.rankings {
+		response.Rankings = append(response.Rankings, *ranking)
+	}
+	return response, nil
+}
+
+// pairResult is one pairwise outcome between movies, by their index in the submission
+type pairResult struct {
+	winner int
+	loser  int
+}
+
+// appliedResults are the rankings of a submission's movies once rated, and the
+// achievements the submission unlocked
+type appliedResults struct {
+	submissionID primitive.ObjectID
+	rankings     []*models.MovieRanking
+	achievements []models.AchievementProgress
+}
+
+// applyResults is the rating engine behind every kind of submission. Each pairwise result
+// is rated against the ratings the movies had before the submission, so that the order of
+// the results doesn't matter, and the changes are summed. Every result is also kept as a
+// battle for the user's history and community statistics.
+func (s *GameService) applyResults(ctx context.Context, userID primitive.ObjectID, movies []models.Movie, results []pairResult, source string) (*appliedResults, error) {
+	// Load current Elo ratings
+	rankings, found, err := s.battleRepo.GetMovieRankings(ctx, userID, movies)
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie rankings: %v", err)
+	}
+
+	// Snapshot the rankings so the submission can be undone exactly
+	before := make([]models.RankingSnapshot, len(rankings))
+	for i, ranking := range rankings {
+		before[i] = snapshotRanking(ranking)
+		before[i].New = !found[i]
+	}
+
+	deltas := make([]float64, len(movies))
+	for _, result := range results {
+		winner, loser := rankings[result.winner], rankings[result.loser]
+		newWinnerRating, newLoserRating := eloUpdate(float64(winner.ELORating), float64(loser.ELORating))
+		deltas[result.winner] += newWinnerRating - float64(winner.ELORating)
+		deltas[result.loser] += newLoserRating - float64(loser.ELORating)
+	}
+	for _, result := range results {
+		rankings[result.winner].MatchCount++
+		rankings[result.winner].WinCount++
+		rankings[result.loser].MatchCount++
+		rankings[result.loser].LossCount++
+	}
 
 	// Save updated rankings
-	if err := s.battleRepo.SaveMovieRanking(ctx, userID, winnerRanking); err != nil {
-		return fmt.Errorf("error saving winner ranking: %v", err)
+	now := time.Now()
+	after := make([]models.RankingSnapshot, len(rankings))
+	for i, ranking := range rankings {
+		// Fill in genre and year for rankings created before they were stored
+		fillRankingAttributes(ranking, &movies[i])
+		ranking.ELORating = int(float64(ranking.ELORating) + deltas[i])
+		ranking.MovieTitle = movies[i].Title
+		ranking.LastUpdated = now
+
+		if err := s.battleRepo.SaveMovieRanking(ctx, userID, ranking); err != nil {
+			return nil, fmt.Errorf("error saving ranking of %s: %v", ranking.MovieTitle, err)
+		}
+		after[i] = snapshotRanking(ranking)
+	}
+
+	// Keep the battles themselves for the user's history and community statistics
+	submissionID := primitive.NewObjectID()
+	battles := make([]*models.Battle, 0, len(results))
+	for _, result := range results {
+		battle := &models.Battle{
+			UserID:       userID,
+			MovieA:       movies[result.winner],
+			MovieB:       movies[result.loser],
+			Winner:       movies[result.winner],
+			CreatedAt:    now,
+			SubmissionID: submissionID,
+			Before:       []models.RankingSnapshot{before[result.winner], before[result.loser]},
+			After:        []models.RankingSnapshot{after[result.winner], after[result.loser]},
+			Source:       source,
+		}
+		// A single battle keeps the sides it was served with
+		if len(movies) == 2 {
+			battle.MovieA, battle.MovieB = movies[0], movies[1]
+		}
+		battles = append(battles, battle)
+	}
+	if err := s.battleRepo.SaveBattles(ctx, battles); err != nil {
+		return nil, fmt.Errorf("error saving battles: %v", err)
+	}
+
+	var newMovies []*models.MovieRanking
+	for i, ranking := range rankings {
+		if before[i].MatchCount == 0 {
+			newMovies = append(newMovies, ranking)
+		}
+	}
+
+	// The submission is already stored, so a failed evaluation is only logged
+	achievements, err := s.achievementService.RecordSubmission(ctx, userID, len(battles), newMovies)
+	if err != nil {
+		fmt.Printf("Error evaluating achievements of user %s: %v\n", userID.Hex(), err)
+	}
+
+	return &appliedResults{submissionID: submissionID, rankings: rankings, achievements: achievements}, nil
+}
+
+func snapshotRanking(ranking *models.MovieRanking) models.RankingSnapshot {
+	return models.RankingSnapshot{
+		MovieID:     ranking.MovieID,
+		MovieTitle:  ranking.MovieTitle,
+		ELORating:   ranking.ELORating,
+		MatchCount:  ranking.MatchCount,
+		WinCount:    ranking.WinCount,
+		LossCount:   ranking.LossCount,
+		LastUpdated: ranking.LastUpdated,
+	}
+}
+
+var (
+	ErrNothingToUndo = errors.New("no battles to undo")
+	ErrUndoConflict  = errors.New("rankings changed since the battles to undo")
+)
+
+// UndoSubmissions reverts the user's latest submissions, newest first, restoring each
+// movie's rating, counts and timestamp from before them. Nothing is undone when a ranking
+// no longer matches what a submission left behind, e.g. after a guest merge or a submission
+// made while undoing. Tournament and room battles can't be undone.
+func (s *GameService) UndoSubmissions(ctx context.Context, userID primitive.ObjectID, count int) (*models.UndoResponse, error) {
+	battles, err := s.battleRepo.FindUndoableBattles(ctx, userID, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(battles) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	// Walk the submissions back from the newest, each must start from what the newer one restored
+	var movies []models.Movie
+	index := make(map[primitive.ObjectID]int)
+	var submissionIDs []primitive.ObjectID
+	for _, battle := range battles {
+		if len(submissionIDs) == 0 || submissionIDs[len(submissionIDs)-1] != battle.SubmissionID {
+			submissionIDs = append(submissionIDs, battle.SubmissionID)
+		}
+		for _, snapshot := range battle.After {
+			if _, ok := index[snapshot.MovieID]; !ok {
+				index[snapshot.MovieID] = len(movies)
+				movies = append(movies, models.Movie{ID: snapshot.MovieID, Title: snapshot.MovieTitle})
+			}
+		}
+	}
+	rankings, found, err := s.battleRepo.GetMovieRankings(ctx, userID, movies)
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie rankings: %v", err)
+	}
+
+	current := make([]*models.RankingSnapshot, len(movies))
+	for i, ranking := range rankings {
+		if found[i] {
+			snapshot := snapshotRanking(ranking)
+			current[i] = &snapshot
+		}
 	}
-	if err := s.battleRepo.SaveMovieRanking(ctx, userID, loserRanking); err != nil {
-		return fmt.Errorf("error saving loser ranking: %v", err)
+	for start := 0; start < len(battles); {
+		end := start
+		for end < len(battles) && battles[end].SubmissionID == battles[start].SubmissionID {
+			end++
+		}
+		restored := make(map[int]models.RankingSnapshot)
+		for _, battle := range battles[start:end] {
+			for i, snapshot := range battle.After {
+				position := index[snapshot.MovieID]
+				if current[position] == nil || !sameRanking(*current[position], snapshot) {
+					return nil, ErrUndoConflict
+				}
+				restored[position] = battle.Before[i]
+			}
+		}
+		for position, snapshot := range restored {
+			if snapshot.New {
+				current[position] = nil
+				continue
+			}
+			snapshot := snapshot
+			current[position] = &snapshot
+		}
+		start = end
+	}
+
+	response := &models.UndoResponse{
+		Submissions: len(submissionIDs),
+		Rankings:    []models.MovieRanking{},
+	}
+	expected := make([]models.RankingSnapshot, 0, len(rankings))
+	var restored []models.RankingSnapshot
+	var removed []primitive.ObjectID
+	unbattled := 0
+	for i, ranking := range rankings {
+		expected = append(expected, snapshotRanking(ranking))
+		if ranking.MatchCount > 0 && (current[i] == nil || current[i].MatchCount == 0) {
+			unbattled++
+		}
+		if current[i] == nil {
+			removed = append(removed, ranking.MovieID)
+			continue
+		}
+		ranking.ELORating = current[i].ELORating
+		ranking.MatchCount = current[i].MatchCount
+		ranking.WinCount = current[i].WinCount
+		ranking.LossCount = current[i].LossCount
+		ranking.LastUpdated = current[i].LastUpdated
+		restored = append(restored, snapshotRanking(ranking))
+		response.Rankings = append(response.Rankings, *ranking)
+	}
+
+	// Mark the battles first, so a failed restore can simply be unmarked again
+	undoneAt := time.Now()
+	undone, err := s.battleRepo.MarkBattlesUndone(ctx, userID, submissionIDs, undoneAt)
+	if err != nil {
+		return nil, fmt.Errorf("error marking battles undone: %v", err)
+	}
+	response.Battles = int(undone)
+
+	// The rankings are only restored if no submission changed them since they were read
+	ok, err := s.battleRepo.RestoreRankings(ctx, userID, expected, restored, removed)
+	if err == nil && !ok {
+		err = ErrUndoConflict
+	}
+	if err != nil {
+		if unmarkErr := s.battleRepo.UnmarkBattlesUndone(ctx, userID, submissionIDs, undoneAt); unmarkErr != nil {
+			fmt.Printf("Error unmarking undone battles of user %s: %v\n", userID.Hex(), unmarkErr)
+		}
+		return nil, err
+	}
+
+	// Streaks are left as they are, like achievements that were already unlocked
+	if err := s.userRepo.AddBattleStats(ctx, userID, -response.Battles, -unbattled); err != nil {
+		return nil, fmt.Errorf("error updating battle stats: %v", err)
+	}
+
+	return response, nil
+}
+
+// sameRanking reports whether two snapshots hold the same rating, counts and timestamp
+func sameRanking(a, b models.RankingSnapshot) bool {
+	return a.ELORating == b.ELORating &&
+		a.MatchCount == b.MatchCount &&
+		a.WinCount == b.WinCount &&
+		a.LossCount == b.LossCount &&
+		a.LastUpdated.Equal(b.LastUpdated)
+}
+
+// fillRankingAttributes copies genre and year from the movie onto the ranking when missing
+func fillRankingAttributes(ranking *models.MovieRanking, movie *models.Movie) {
+	if len(ranking.Genres) == 0 {
+		ranking.Genres = helper.NormalizeGenres(movie.Genre)
+	}
+	if ranking.Year == 0 {
+		ranking.Year = helper.ParseYear(movie.Year)
 	}
+}
 
-	return nil
+// Elo constant (determines how much ratings can change after a single match/battle.)
+const eloK = 32.0
+
+// eloUpdate returns the new ratings of the winner and loser of a battle
+func eloUpdate(winnerRating, loserRating float64) (float64, float64) {
+	ea := 1.0 / (1.0 + math.Pow(10, (loserRating-winnerRating)/400))
+	eb := 1.0 / (1.0 + math.Pow(10, (winnerRating-loserRating)/400))
+
+	return winnerRating + eloK*(1-ea), loserRating + eloK*(0-eb)
 }
 
 // AreMoviesIdentical checks if two movies are identical by comparing all relevant fields
@@ -466,3 +843,191 @@ func (s *GameService) AreMoviesIdentical(movieA, movieB *models.Movie) bool {
 func (s *GameService) GetTopTwenty(ctx context.Context, userID primitive.ObjectID) ([]models.MovieRanking, error) {
 	return s.battleRepo.GetTopTwenty(ctx, userID)
 }
+
+// ErrInvalidRankingQuery is returned when a ranking query uses an unknown sort key or bad bounds
+var ErrInvalidRankingQuery = errors.New("invalid ranking query")
+
+// GetRankings returns a filtered, sorted page of the user's rankings with catalog details
+func (s *GameService) GetRankings(ctx context.Context, userID primitive.ObjectID, query models.RankingQuery) (*models.RankingListResponse, error) {
+	switch query.Sort {
+	case models.RankingSortELO, models.RankingSortWins, models.RankingSortMatches,
+		models.RankingSortWinRate, models.RankingSortRecent:
+	default:
+		return nil, ErrInvalidRankingQuery
+	}
+	if query.YearFrom > 0 && query.YearTo > 0 && query.YearFrom > query.YearTo {
+		return nil, ErrInvalidRankingQuery
+	}
+
+	movies, total, err := s.battleRepo.QueryRankings(ctx, userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.RankingListResponse{
+		Movies: movies,
+		Total:  total,
+		Sort:   query.Sort,
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}
+	if next := query.Offset + len(movies); int64(next) < total {
+		response.NextCursor = EncodeRankingCursor(next)
+	}
+
+	return response, nil
+}
+
+// EncodeRankingCursor turns an offset into the opaque cursor handed to clients
+func EncodeRankingCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// DecodeRankingCursor reverses EncodeRankingCursor
+func DecodeRankingCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidRankingQuery
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidRankingQuery
+	}
+	return offset, nil
+}
+
+// ErrRankingNotFound is returned for a movie the user has no ranking or history of
+var ErrRankingNotFound = errors.New("movie ranking not found")
+
+// GetRatingHistory returns how the movie's rating in the user's list changed over time,
+// one point per submission. With maxPoints above zero, longer series are downsampled.
+func (s *GameService) GetRatingHistory(ctx context.Context, userID primitive.ObjectID, title string, maxPoints int) (*models.RatingHistoryResponse, error) {
+	battles, err := s.battleRepo.FindRatingHistory(ctx, userID, title)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []models.RatingPoint{}
+	var lastSubmission primitive.ObjectID
+	for _, battle := range battles {
+		// Every battle of a multi-way round carries the same snapshots
+		if battle.SubmissionID == lastSubmission {
+			continue
+		}
+		lastSubmission = battle.SubmissionID
+
+		for i, after := range battle.After {
+			if after.MovieTitle != title || i >= len(battle.Before) {
+				continue
+			}
+			if len(points) == 0 {
+				before := battle.Before[i]
+				at := before.LastUpdated
+				if before.New || at.After(battle.CreatedAt) {
+					at = battle.CreatedAt
+				}
+				points = append(points, models.RatingPoint{At: at, ELORating: before.ELORating, MatchCount: before.MatchCount})
+			}
+			points = append(points, models.RatingPoint{
+				At:         battle.CreatedAt,
+				ELORating:  after.ELORating,
+				Change:     after.ELORating - points[len(points)-1].ELORating,
+				MatchCount: after.MatchCount,
+			})
+			break
+		}
+	}
+
+	if len(points) == 0 {
+		rankings, found, err := s.battleRepo.GetMovieRankings(ctx, userID, []models.Movie{{Title: title}})
+		if err != nil {
+			return nil, fmt.Errorf("error getting movie ranking: %v", err)
+		}
+		if !found[0] {
+			return nil, ErrRankingNotFound
+		}
+		// No recorded battles yet: the current rating is the whole history
+		points = append(points, models.RatingPoint{
+			At:         rankings[0].LastUpdated,
+			ELORating:  rankings[0].ELORating,
+			MatchCount: rankings[0].MatchCount,
+		})
+	}
+
+	response := &models.RatingHistoryResponse{
+		MovieTitle:  title,
+		Points:      points,
+		TotalPoints: len(points),
+	}
+	if maxPoints > 0 && len(points) > maxPoints {
+		response.Points = downsampleRatingPoints(points, maxPoints)
+		response.Downsampled = true
+	}
+	return response, nil
+}
+
+// downsampleRatingPoints reduces a series to size points with Largest-Triangle-Three-Buckets,
+// which keeps the first and last points and the peaks and dips that shape a chart. Changes
+// are recomputed against the points that remain.
+func downsampleRatingPoints(points []models.RatingPoint, size int) []models.RatingPoint {
+	if size < 3 || len(points) <= size {
+		return points
+	}
+
+	x := func(i int) float64 { return float64(points[i].At.UnixNano()) }
+	y := func(i int) float64 { return float64(points[i].ELORating) }
+
+	sampled := make([]models.RatingPoint, 0, size)
+	sampled = append(sampled, points[0])
+	bucketSize := float64(len(points)-2) / float64(size-2)
+	selected := 0
+	for bucket := 0; bucket < size-2; bucket++ {
+		start := int(float64(bucket)*bucketSize) + 1
+		end := int(float64(bucket+1)*bucketSize) + 1
+
+		// Average of the next bucket, or the last point for the final bucket
+		nextStart, nextEnd := end, int(float64(bucket+2)*bucketSize)+1
+		if nextEnd > len(points)-1 {
+			nextEnd = len(points) - 1
+		}
+		if nextStart >= nextEnd {
+			nextStart, nextEnd = len(points)-1, len(points)
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += x(i)
+			avgY += y(i)
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((x(selected)-avgX)*(y(i)-y(selected)) - (x(selected)-x(i))*(avgY-y(selected)))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		sampled = append(sampled, points[best])
+		selected = best
+	}
+	sampled = append(sampled, points[len(points)-1])
+
+	for i := range sampled {
+		sampled[i].Change = 0
+		if i > 0 {
+			sampled[i].Change = sampled[i].ELORating - sampled[i-1].ELORating
+		}
+	}
+	return sampled
+}
+
+// GetBestByGenre returns the user's highest rated movies per genre
+func (s *GameService) GetBestByGenre(ctx context.Context, userID primitive.ObjectID, minMatches, perGroup int) ([]models.GenreRankings, error) {
+	return s.battleRepo.BestRankingsByGenre(ctx, userID, minMatches, perGroup)
+}
+
+// GetBestByDecade returns the user's highest rated movies per decade of release
+func (s *GameService) GetBestByDecade(ctx context.Context, userID primitive.ObjectID, minMatches, perGroup int) ([]models.DecadeRankings, error) {
+	return s.battleRepo.BestRankingsByDecade(ctx, userID, minMatches, perGroup)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"movie-vs-backend/models"
+)
+
+// ratingSeries returns one point per rating, a minute apart
+func ratingSeries(ratings ...int) []models.RatingPoint {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]models.RatingPoint, len(ratings))
+	for i, rating := range ratings {
+		points[i] = models.RatingPoint{At: start.Add(time.Duration(i) * time.Minute), ELORating: rating, MatchCount: i}
+	}
+	return points
+}
+
+func TestDownsampleRatingPoints(t *testing.T) {
+	flat := make([]int, 100)
+	for i := range flat {
+		flat[i] = 1500
+	}
+	spiked := append([]int(nil), flat...)
+	spiked[37] = 1800
+	spiked[71] = 1200
+
+	tests := []struct {
+		name     string
+		ratings  []int
+		size     int
+		wantLen  int
+		wantKept []int // Indexes of points that must survive
+	}{
+		{name: "shorter than size", ratings: []int{1500, 1516, 1530}, size: 5, wantLen: 3},
+		{name: "exactly size", ratings: []int{1500, 1516, 1530, 1510, 1525}, size: 5, wantLen: 5},
+		{name: "size below three keeps everything", ratings: flat, size: 2, wantLen: 100},
+		{name: "one point over size", ratings: []int{1500, 1516, 1530, 1510, 1525, 1540}, size: 5, wantLen: 5, wantKept: []int{0, 5}},
+		{name: "peaks and dips survive", ratings: spiked, size: 10, wantLen: 10, wantKept: []int{0, 37, 71, 99}},
+		{name: "three points keep the extreme", ratings: spiked, size: 3, wantLen: 3, wantKept: []int{0, 99}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := ratingSeries(tt.ratings...)
+			sampled := downsampleRatingPoints(points, tt.size)
+			if len(sampled) != tt.wantLen {
+				t.Fatalf("got %d points, want %d", len(sampled), tt.wantLen)
+			}
+
+			kept := make(map[int]bool)
+			for i, point := range sampled {
+				kept[point.MatchCount] = true
+				if i > 0 && !point.At.After(sampled[i-1].At) {
+					t.Errorf("point %d is not after point %d", i, i-1)
+				}
+			}
+			for _, index := range tt.wantKept {
+				if !kept[index] {
+					t.Errorf("point %d (rating %d) was dropped", index, tt.ratings[index])
+				}
+			}
+
+			if len(sampled) < len(points) {
+				for i, point := range sampled {
+					want := 0
+					if i > 0 {
+						want = point.ELORating - sampled[i-1].ELORating
+					}
+					if point.Change != want {
+						t.Errorf("point %d has change %d, want %d", i, point.Change, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRoundResults(t *testing.T) {
+	movies := []models.Movie{{Title: "Alien"}, {Title: "Brazil"}, {Title: "Casablanca"}, {Title: "Dune"}}
+
+	tests := []struct {
+		name     string
+		movies   []models.Movie
+		order    []string
+		favorite string
+		want     []pairResult
+		wantErr  error
+	}{
+		{
+			name:   "order ranks every pair",
+			movies: movies[:3],
+			order:  []string{"Casablanca", "Alien", "Brazil"},
+			want:   []pairResult{{winner: 2, loser: 0}, {winner: 2, loser: 1}, {winner: 0, loser: 1}},
+		},
+		{
+			name:   "order of four gives six pairs",
+			movies: movies,
+			order:  []string{"Dune", "Casablanca", "Brazil", "Alien"},
+			want: []pairResult{
+				{winner: 3, loser: 2}, {winner: 3, loser: 1}, {winner: 3, loser: 0},
+				{winner: 2, loser: 1}, {winner: 2, loser: 0}, {winner: 1, loser: 0},
+			},
+		},
+		{
+			name:     "favourite beats each other movie",
+			movies:   movies,
+			favorite: "Brazil",
+			want:     []pairResult{{winner: 1, loser: 0}, {winner: 1, loser: 2}, {winner: 1, loser: 3}},
+		},
+		{name: "order and favourite", movies: movies[:3], order: []string{"Alien", "Brazil", "Casablanca"}, favorite: "Alien", wantErr: ErrInvalidRound},
+		{name: "neither order nor favourite", movies: movies[:3], wantErr: ErrInvalidRound},
+		{name: "partial order", movies: movies[:3], order: []string{"Alien", "Brazil"}, wantErr: ErrInvalidRound},
+		{name: "repeated title in order", movies: movies[:3], order: []string{"Alien", "Alien", "Brazil"}, wantErr: ErrInvalidRound},
+		{name: "unknown title in order", movies: movies[:3], order: []string{"Alien", "Brazil", "Dune"}, wantErr: ErrInvalidRound},
+		{name: "unknown favourite", movies: movies[:3], favorite: "Dune", wantErr: ErrInvalidRound},
+		{name: "duplicate movie", movies: []models.Movie{{Title: "Alien"}, {Title: "Alien"}, {Title: "Brazil"}}, favorite: "Brazil", wantErr: ErrInvalidRound},
+		{name: "untitled movie", movies: []models.Movie{{Title: "Alien"}, {}, {Title: "Brazil"}}, favorite: "Brazil", wantErr: ErrInvalidRound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := roundResults(&models.SubmitRoundRequest{Movies: tt.movies, Order: tt.order, Favorite: tt.favorite})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("roundResults() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("roundResults() = %v, want %v", results, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+)
+
+var ErrInvalidGuestToken = errors.New("invalid or expired guest token")
+
+// GuestService lets visitors play without registering. A guest is a short-lived user with
+// the guest role; when they register or log in their rankings are merged into the account.
+type GuestService struct {
+	userRepo   *data_access.UserRepository
+	battleRepo *data_access.BattleRepository
+	keyService *KeyService
+	ttl        time.Duration
+}
+
+func NewGuestService(
+	userRepo *data_access.UserRepository,
+	battleRepo *data_access.BattleRepository,
+	keyService *KeyService,
+	ttl time.Duration,
+) *GuestService {
+	return &GuestService{
+		userRepo:   userRepo,
+		battleRepo: battleRepo,
+		keyService: keyService,
+		ttl:        ttl,
+	}
+}
+
+// CreateGuest starts a guest session with freshly initialised rankings
+func (s *GuestService) CreateGuest(ctx context.Context) (*models.GuestResponse, error) {
+	movieRankings, err := helper.InitializeMovieRankings()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	guest := &models.User{
+		Role:           models.RoleGuest,
+		Privacy:        models.PrivacySettings{ProfileVisibility: models.VisibilityPrivate, RankingsVisibility: models.VisibilityPrivate},
+		CreatedAt:      now,
+		LastLogin:      now,
+		GuestExpiresAt: &expiresAt,
+		MovieRankings:  movieRankings,
+	}
+
+	if err := s.userRepo.CreateUser(ctx, guest); err != nil {
+		return nil, fmt.Errorf("error creating guest: %v", err)
+	}
+
+	// The token lives exactly as long as the guest account
+	token, err := signAccessToken(s.keyService, guest.ID, models.RoleGuest, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GuestResponse{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// MergeInto moves a guest's rankings and battles into a real account and removes the guest.
+// A guest is merged at most once: if the merge fails part-way, the movies merged so far are
+// kept and the rest of the guest expires as usual.
+func (s *GuestService) MergeInto(ctx context.Context, guestToken string, userID primitive.ObjectID) error {
+	guestID, err := s.parseGuestToken(guestToken)
+	if err != nil {
+		return err
+	}
+	if guestID == userID {
+		return errors.New("cannot merge a guest into itself")
+	}
+
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error finding user: %v", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	// Claim the guest first, so a retry or a concurrent merge can't count it twice
+	guest, err := s.userRepo.ClaimGuest(ctx, guestID, time.Now())
+	if err != nil {
+		return fmt.Errorf("error claiming guest: %v", err)
+	}
+	if guest == nil {
+		return ErrInvalidGuestToken
+	}
+
+	merged, newlyBattled := 0, 0
+	for _, ranking := range guest.MovieRankings {
+		if ranking.MatchCount == 0 {
+			continue
+		}
+		added, err := s.battleRepo.MergeMovieRanking(ctx, userID, ranking)
+		if err != nil {
+			// Once a movie is merged the guest stays claimed, so it can't be counted twice
+			if merged == 0 {
+				if releaseErr := s.userRepo.ReleaseGuest(ctx, guestID); releaseErr != nil {
+					fmt.Printf("Error releasing guest %s: %v\n", guestID.Hex(), releaseErr)
+				}
+			}
+			return err
+		}
+		merged++
+		if added {
+			newlyBattled++
+		}
+	}
+	if err := s.userRepo.AddBattleStats(ctx, userID, guest.BattleStats.Battles, newlyBattled); err != nil {
+		return fmt.Errorf("error saving merged battle stats: %v", err)
+	}
+
+	moved, err := s.battleRepo.ReassignBattles(ctx, guestID, userID)
+	if err != nil {
+		return fmt.Errorf("error moving guest battles: %v", err)
+	}
+
+	if err := s.userRepo.DeleteUser(ctx, guestID); err != nil {
+		return fmt.Errorf("error removing guest: %v", err)
+	}
+
+	fmt.Printf("Merged guest %s into user %s (%d battles)\n", guestID.Hex(), userID.Hex(), moved)
+	return nil
+}
+
+// PurgeExpired removes guests that were never turned into an account. Their battles are
+// kept anonymised for community statistics.
+func (s *GuestService) PurgeExpired(ctx context.Context) (int, error) {
+	guestIDs, err := s.userRepo.FindExpiredGuests(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding expired guests: %v", err)
+	}
+
+	for i, guestID := range guestIDs {
+		if _, err := s.battleRepo.AnonymizeUserBattles(ctx, guestID); err != nil {
+			return i, fmt.Errorf("error anonymising guest battles: %v", err)
+		}
+		if err := s.userRepo.DeleteUser(ctx, guestID); err != nil {
+			return i, fmt.Errorf("error deleting guest: %v", err)
+		}
+	}
+
+	return len(guestIDs), nil
+}
+
+// StartPurge runs PurgeExpired every interval until ctx is cancelled
+func (s *GuestService) StartPurge(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "guest purge", interval, func(ctx context.Context) error {
+		purged, err := s.PurgeExpired(ctx)
+		if purged > 0 {
+			fmt.Printf("Purged %d expired guests\n", purged)
+		}
+		return err
+	})
+}
+
+// parseGuestToken returns the guest user ID from a valid guest access token
+func (s *GuestService) parseGuestToken(tokenString string) (primitive.ObjectID, error) {
+	token, err := jwt.Parse(tokenString, s.keyService.Keyfunc)
+	if err != nil || !token.Valid {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != TokenTypeAccess || claims["role"] != models.RoleGuest {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+
+	userIDStr, _ := claims["user_id"].(string)
+	guestID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+
+	return guestID, nil
+}
+
+// countBattled returns how many of the rankings have been battled
+func countBattled(rankings []models.MovieRanking) int {
+	count := 0
+	for _, ranking := range rankings {
+		if ranking.MatchCount > 0 {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+func TestMergeIntoClaimsTheGuest(t *testing.T) {
+	keyService := newTestKeyService(t)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	userID, guestID := primitive.NewObjectID(), primitive.NewObjectID()
+	user := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{{Key: "_id", Value: userID}})
+	guest := mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+		{Key: "_id", Value: guestID},
+		{Key: "role", Value: models.RoleGuest},
+		{Key: "movie_rankings", Value: bson.A{bson.D{
+			{Key: "movie_title", Value: "Alien"},
+			{Key: "elo_rating", Value: 1216},
+			{Key: "match_count", Value: 1},
+			{Key: "win_count", Value: 1},
+		}}},
+	}})
+	alreadyClaimed := mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+	failed := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Name: "InternalError", Message: "connection lost"})
+	updated := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		wantErr   error
+		commands  []string
+	}{
+		{
+			name:      "already merged",
+			responses: []bson.D{user, alreadyClaimed},
+			wantErr:   ErrInvalidGuestToken,
+			commands:  []string{"find", "findAndModify"},
+		},
+		{
+			name:      "failure before anything is merged releases the guest",
+			responses: []bson.D{user, guest, failed, updated},
+			commands:  []string{"find", "findAndModify", "findAndModify", "update"},
+		},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+
+			db := data_access.WrapMongoDB(mt.Client, "movievs")
+			service := NewGuestService(data_access.NewUserRepository(db), data_access.NewBattleRepository(db), keyService, time.Hour)
+			token, err := signAccessToken(keyService, guestID, models.RoleGuest, time.Now().Add(time.Hour))
+			if err != nil {
+				mt.Fatal(err)
+			}
+
+			err = service.MergeInto(context.Background(), token, userID)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				mt.Errorf("MergeInto error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err == nil {
+				mt.Error("MergeInto succeeded, want an error")
+			}
+			if got := strings.Join(startedCommands(mt), ","); got != strings.Join(tt.commands, ",") {
+				mt.Errorf("commands = %q, want %q", got, strings.Join(tt.commands, ","))
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+)
+
+const (
+	// Titles at least this similar are matched when no title is identical
+	importFuzzyThreshold = 0.85
+	// ELO points per step on the 1-10 rating scale, around the default 1200 for a 5.5.
+	// A 10 seeds 1380 and a 1 seeds 1020.
+	importRatingStep = 40.0
+)
+
+var (
+	ErrUnknownImportSource = errors.New("unknown import source")
+	ErrInvalidImportFile   = errors.New("file is not a valid export")
+)
+
+// ImportService brings ratings and watch history from other sites into a user's list
+type ImportService struct {
+	movieRepo  *data_access.MovieRepository
+	battleRepo *data_access.BattleRepository
+}
+
+func NewImportService(movieRepo *data_access.MovieRepository, battleRepo *data_access.BattleRepository) *ImportService {
+	return &ImportService{
+		movieRepo:  movieRepo,
+		battleRepo: battleRepo,
+	}
+}
+
+// Import matches the rows of an export file against the catalog, marks the matched movies
+// as seen and seeds the ELO of movies the user has not battled yet from their rating
+func (s *ImportService) Import(ctx context.Context, userID primitive.ObjectID, source string, file io.Reader) (*models.ImportReport, error) {
+	var rows []models.ImportRow
+	var err error
+	switch source {
+	case models.ImportSourceLetterboxd:
+		rows, err = helper.ParseLetterboxdCSV(file)
+	case models.ImportSourceIMDb:
+		rows, err = helper.ParseIMDbCSV(file)
+	default:
+		return nil, ErrUnknownImportSource
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	catalog, _, err := s.movieRepo.ListCatalog(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newCatalogMatcher(catalog)
+
+	report := &models.ImportReport{
+		Source:    source,
+		Rows:      len(rows),
+		Matches:   []models.ImportMatch{},
+		Unmatched: []models.UnmatchedImportRow{},
+	}
+
+	// One match per catalog movie; a later row with a rating (e.g. a rewatch in a diary)
+	// replaces the rating of an earlier one
+	var movies []models.Movie
+	matchIndex := make(map[primitive.ObjectID]int)
+	for _, row := range rows {
+		movie, fuzzy, reason := matcher.match(row)
+		if movie == nil {
+			report.Unmatched = append(report.Unmatched, models.UnmatchedImportRow{ImportRow: row, Reason: reason})
+			continue
+		}
+		if i, ok := matchIndex[movie.ID]; ok {
+			if row.Rating > 0 {
+				report.Matches[i].Rating = row.Rating
+			}
+			continue
+		}
+		matchIndex[movie.ID] = len(movies)
+		movies = append(movies, *movie)
+		report.Matches = append(report.Matches, models.ImportMatch{
+			Line:         row.Line,
+			Title:        row.Title,
+			MatchedTitle: movie.Title,
+			Fuzzy:        fuzzy,
+			Rating:       row.Rating,
+		})
+	}
+	report.Matched = len(movies)
+	if len(movies) == 0 {
+		return report, nil
+	}
+
+	rankings, found, err := s.battleRepo.GetMovieRankings(ctx, userID, movies)
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie rankings: %v", err)
+	}
+
+	var existing, added []models.MovieRanking
+	for i, ranking := range rankings {
+		match := &report.Matches[i]
+		ranking.Seen = true
+		if match.Rating > 0 && ranking.MatchCount == 0 {
+			ranking.ELORating = seedRating(match.Rating)
+			match.Seeded = true
+			report.Seeded++
+		}
+		match.ELORating = ranking.ELORating
+
+		if found[i] {
+			existing = append(existing, *ranking)
+			continue
+		}
+		fillRankingAttributes(ranking, &movies[i])
+		added = append(added, *ranking)
+	}
+	report.Added = len(added)
+
+	if err := s.battleRepo.ImportRankings(ctx, userID, existing, added); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// seedRating converts a rating on the 1-10 scale to a starting ELO
+func seedRating(rating float64) int {
+	return int(math.Round(1200 + (rating-5.5)*importRatingStep))
+}
+
+// catalogMatcher finds the catalog movie an imported row refers to
+type catalogMatcher struct {
+	catalog []models.Movie
+	titles  []string // Normalised titles, by catalog index
+	years   []int
+	byTitle map[string][]int
+	byIMDB  map[string]int
+}
+
+func newCatalogMatcher(catalog []models.Movie) *catalogMatcher {
+	m := &catalogMatcher{
+		catalog: catalog,
+		titles:  make([]string, len(catalog)),
+		years:   make([]int, len(catalog)),
+		byTitle: make(map[string][]int),
+		byIMDB:  make(map[string]int),
+	}
+	for i, movie := range catalog {
+		m.titles[i] = helper.NormalizeTitle(movie.Title)
+		m.years[i] = helper.ParseYear(movie.Year)
+		m.byTitle[m.titles[i]] = append(m.byTitle[m.titles[i]], i)
+		if movie.IMDBID != "" {
+			m.byIMDB[movie.IMDBID] = i
+		}
+	}
+	return m
+}
+
+// match returns the row's catalog movie and whether it was matched on a similar title,
+// or the reason no movie was found
+func (m *catalogMatcher) match(row models.ImportRow) (*models.Movie, bool, string) {
+	if row.Title == "" {
+		return nil, false, "missing title"
+	}
+	if !isImportableType(row.Type) {
+		return nil, false, "not a movie"
+	}
+	if i, ok := m.byIMDB[row.IMDBID]; ok && row.IMDBID != "" {
+		return &m.catalog[i], false, ""
+	}
+
+	title := helper.NormalizeTitle(row.Title)
+	best, bestGap := -1, 0
+	for _, i := range m.byTitle[title] {
+		if gap, ok := yearGap(row.Year, m.years[i]); ok && (best == -1 || gap < bestGap) {
+			best, bestGap = i, gap
+		}
+	}
+	if best != -1 {
+		return &m.catalog[best], false, ""
+	}
+
+	bestSimilarity := 0.0
+	for i, candidate := range m.titles {
+		if _, ok := yearGap(row.Year, m.years[i]); !ok {
+			continue
+		}
+		if similarity := helper.TitleSimilarity(title, candidate); similarity >= importFuzzyThreshold && similarity > bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	if best != -1 {
+		return &m.catalog[best], true, ""
+	}
+
+	if len(m.byTitle[title]) > 0 {
+		return nil, false, fmt.Sprintf("no catalog movie with this title from %d", row.Year)
+	}
+	return nil, false, "no matching movie in the catalog"
+}
+
+// yearGap returns how many years apart a row and a catalog movie are, allowing a year's
+// difference between release dates in different countries. Unknown years match any year.
+func yearGap(rowYear, catalogYear int) (int, bool) {
+	if rowYear == 0 || catalogYear == 0 {
+		return 0, true
+	}
+	gap := rowYear - catalogYear
+	if gap < 0 {
+		gap = -gap
+	}
+	return gap, gap <= 1
+}
+
+// isImportableType reports whether an IMDb title type is a film. Letterboxd rows have no
+// type and only list films.
+func isImportableType(titleType string) bool {
+	switch strings.ReplaceAll(strings.ToLower(titleType), " ", "") {
+	case "", "movie", "tvmovie", "video":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// runPeriodically calls job every interval in a background goroutine until ctx is cancelled.
+// Errors are logged and the job is tried again on the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := job(ctx); err != nil {
+				fmt.Printf("Error running %s: %v\n", name, err)
+			}
+		}
+	}()
+}
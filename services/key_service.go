@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// Supported token signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Marks private keys stored encrypted with JWT_KEY_ENCRYPTION_KEY
+const encryptedKeyPrefix = "aes-gcm:"
+
+var ErrKeyFileConfigured = errors.New("signing key is set by JWT_PRIVATE_KEY_FILE and cannot be rotated")
+
+// loadedKey is a parsed SigningKey ready to sign or verify
+type loadedKey struct {
+	record    models.SigningKey
+	private   crypto.Signer // Nil for the key file of another instance, which only verifies
+	public    crypto.PublicKey
+	method    jwt.SigningMethod
+	publicJWK models.JSONWebKey
+}
+
+// KeyService owns the token signing keys. It signs with the newest active key, verifies
+// with every key that has not passed its verification grace period, and rotates keys on
+// a schedule so old tokens keep working until they expire. A configured key file is
+// always the signing key instead. With an encryption key, private keys are stored
+// encrypted so a copy of the database alone cannot sign tokens.
+type KeyService struct {
+	keyRepo          *data_access.SigningKeyRepository
+	algorithm        string
+	rotationInterval time.Duration
+	verifyGrace      time.Duration
+	encryption       cipher.AEAD
+	fileKid          string
+	fileKey          *loadedKey
+
+	mu         sync.RWMutex
+	active     *loadedKey
+	keys       map[string]*loadedKey
+	lastReload time.Time
+}
+
+func NewKeyService(
+	keyRepo *data_access.SigningKeyRepository,
+	algorithm string,
+	rotationInterval, verifyGrace time.Duration,
+	encryptionKey []byte,
+) (*KeyService, error) {
+	s := &KeyService{
+		keyRepo:          keyRepo,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		verifyGrace:      verifyGrace,
+		keys:             make(map[string]*loadedKey),
+	}
+
+	if len(encryptionKey) > 0 {
+		block, err := aes.NewCipher(encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key encryption key: %v", err)
+		}
+		if s.encryption, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Init makes the configured key file (if any) the signing key and retires all others, loads
+// the shared key set and makes sure there is a key to sign with. It fails when no key is
+// available and rotation is disabled. A key file's private key is never written to MongoDB,
+// only its public key is shared so other instances can verify its tokens.
+func (s *KeyService) Init(ctx context.Context, privateKeyFile string) error {
+	if privateKeyFile != "" {
+		pemBytes, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			return fmt.Errorf("error reading JWT private key: %v", err)
+		}
+		key, err := parseSigningKey(models.SigningKey{}, pemBytes)
+		if err != nil {
+			return fmt.Errorf("error parsing JWT private key: %v", err)
+		}
+		if key.method.Alg() != s.algorithm {
+			return fmt.Errorf("JWT_PRIVATE_KEY_FILE holds an %s key but JWT_SIGNING_ALG is %s", key.method.Alg(), s.algorithm)
+		}
+
+		key.record = models.SigningKey{
+			Kid:       key.publicJWK.Kid,
+			Algorithm: key.method.Alg(),
+			PublicKey: &key.publicJWK,
+			CreatedAt: time.Now(),
+		}
+		if err := s.keyRepo.SaveActivePublicKey(ctx, &key.record); err != nil {
+			return fmt.Errorf("error storing JWT public key: %v", err)
+		}
+		now := time.Now()
+		if err := s.keyRepo.RetireAllExcept(ctx, key.record.Kid, now, now.Add(s.verifyGrace)); err != nil {
+			return fmt.Errorf("error retiring signing keys: %v", err)
+		}
+		s.fileKid = key.record.Kid
+		s.fileKey = key
+	}
+
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	active := s.active
+	s.mu.RUnlock()
+
+	if active == nil {
+		if s.rotationInterval <= 0 {
+			return errors.New("no JWT signing key configured")
+		}
+		return s.Rotate(ctx)
+	}
+
+	return nil
+}
+
+// Reload refreshes the key set from MongoDB, picking up rotations done by other instances
+func (s *KeyService) Reload(ctx context.Context) error {
+	records, err := s.keyRepo.ListUsable(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*loadedKey)
+	var active *loadedKey
+	for _, record := range records {
+		var key *loadedKey
+		var err error
+		switch {
+		case record.Kid == s.fileKid:
+			key = s.fileKey
+		case record.PrivateKey == "":
+			// Another instance's key file, only its public key is shared
+			key, err = parsePublicSigningKey(record)
+		default:
+			key, err = s.loadSigningKey(record)
+		}
+		if err != nil {
+			fmt.Printf("Skipping signing key %s: %v\n", record.Kid, err)
+			continue
+		}
+		keys[record.Kid] = key
+		// Records are sorted newest first
+		if active == nil && record.RetiredAt == nil && key.private != nil {
+			active = key
+		}
+	}
+	// The key file always signs, even if another instance retired it since
+	if s.fileKey != nil {
+		keys[s.fileKid] = s.fileKey
+		active = s.fileKey
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.active = active
+	s.lastReload = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Rotate generates a new signing key and retires the previous ones
+func (s *KeyService) Rotate(ctx context.Context) error {
+	if s.fileKid != "" {
+		return ErrKeyFileConfigured
+	}
+
+	var signer crypto.Signer
+	var err error
+	switch s.algorithm {
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return fmt.Errorf("error generating signing key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+	record, err := s.newSigningKeyRecord(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return err
+	}
+
+	if err := s.keyRepo.InsertIfMissing(ctx, record); err != nil {
+		return fmt.Errorf("error storing signing key: %v", err)
+	}
+
+	now := time.Now()
+	if err := s.keyRepo.RetireAllExcept(ctx, record.Kid, now, now.Add(s.verifyGrace)); err != nil {
+		return fmt.Errorf("error retiring signing keys: %v", err)
+	}
+
+	fmt.Printf("Rotated JWT signing key, new kid %s\n", record.Kid)
+	return s.Reload(ctx)
+}
+
+// StartRotation checks once an hour whether the active key is older than the rotation
+// interval and reloads the key set in between. It stops when ctx is cancelled.
+func (s *KeyService) StartRotation(ctx context.Context) {
+	if s.rotationInterval <= 0 {
+		return
+	}
+
+	runPeriodically(ctx, "signing key rotation", time.Hour, func(ctx context.Context) error {
+		if err := s.Reload(ctx); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		active := s.active
+		s.mu.RUnlock()
+
+		if active == nil || time.Since(active.record.CreatedAt) >= s.rotationInterval {
+			return s.Rotate(ctx)
+		}
+		return nil
+	})
+}
+
+// Sign signs the claims with the active key and tags the token with its kid
+func (s *KeyService) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	active := s.active
+	s.mu.RUnlock()
+
+	if active == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.record.Kid
+	return token.SignedString(active.private)
+}
+
+// Keyfunc resolves the verification key of a token from its kid header
+func (s *KeyService) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	lastReload := s.lastReload
+	s.mu.RUnlock()
+
+	// Another instance may have rotated, reload at most once a minute for unknown kids
+	if !ok && time.Since(lastReload) > time.Minute {
+		if err := s.Reload(context.Background()); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		key, ok = s.keys[kid]
+		s.mu.RUnlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// JWKS returns the public keys other services can use to verify our tokens
+func (s *KeyService) JWKS() models.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.publicJWK)
+	}
+	return set
+}
+
+// newSigningKeyRecord builds a SigningKey from a PKCS #8 PEM private key, using the
+// RFC 7638 thumbprint of the public key as its kid
+func (s *KeyService) newSigningKeyRecord(pemBytes []byte) (*models.SigningKey, error) {
+	record := &models.SigningKey{CreatedAt: time.Now()}
+
+	key, err := parseSigningKey(*record, pemBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Kid = key.publicJWK.Kid
+	record.Algorithm = key.method.Alg()
+	if record.PrivateKey, err = s.sealPrivateKey(pemBytes); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// loadSigningKey decrypts and parses a stored key
+func (s *KeyService) loadSigningKey(record models.SigningKey) (*loadedKey, error) {
+	pemBytes, err := s.openPrivateKey(record.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return parseSigningKey(record, pemBytes)
+}
+
+// sealPrivateKey encrypts a PEM private key for storage, or keeps it as is without an
+// encryption key
+func (s *KeyService) sealPrivateKey(pemBytes []byte) (string, error) {
+	if s.encryption == nil {
+		return string(pemBytes), nil
+	}
+
+	nonce := make([]byte, s.encryption.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.encryption.Seal(nonce, nonce, pemBytes, nil)
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPrivateKey returns the PEM of a stored private key. Keys stored before encryption was
+// configured are still read as plain PEM.
+func (s *KeyService) openPrivateKey(stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, encryptedKeyPrefix) {
+		return []byte(stored), nil
+	}
+	if s.encryption == nil {
+		return nil, errors.New("key is encrypted but JWT_KEY_ENCRYPTION_KEY is not set")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedKeyPrefix))
+	if err != nil || len(sealed) < s.encryption.NonceSize() {
+		return nil, errors.New("malformed encrypted key")
+	}
+	nonce, ciphertext := sealed[:s.encryption.NonceSize()], sealed[s.encryption.NonceSize():]
+	pemBytes, err := s.encryption.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt key, is JWT_KEY_ENCRYPTION_KEY correct?")
+	}
+	return pemBytes, nil
+}
+
+func parseSigningKey(record models.SigningKey, pemBytes []byte) (*loadedKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// Also accept the traditional "RSA PRIVATE KEY" format produced by openssl genrsa
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return nil, err
+		}
+		parsed = rsaKey
+	}
+
+	key := &loadedKey{record: record}
+	var thumbprintInput []byte
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private = private
+		key.public = private.Public()
+		key.method = jwt.SigningMethodRS256
+		n := base64.RawURLEncoding.EncodeToString(private.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(private.E)).Bytes())
+		key.publicJWK = models.JSONWebKey{Kty: "RSA", Use: "sig", Alg: AlgRS256, N: n, E: e}
+		thumbprintInput, _ = json.Marshal(map[string]string{"e": e, "kty": "RSA", "n": n})
+
+	case ed25519.PrivateKey:
+		key.private = private
+		key.public = private.Public()
+		key.method = jwt.SigningMethodEdDSA
+		x := base64.RawURLEncoding.EncodeToString(private.Public().(ed25519.PublicKey))
+		key.publicJWK = models.JSONWebKey{Kty: "OKP", Use: "sig", Alg: AlgEdDSA, Crv: "Ed25519", X: x}
+		thumbprintInput, _ = json.Marshal(map[string]string{"crv": "Ed25519", "kty": "OKP", "x": x})
+
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	thumbprint := sha256.Sum256(thumbprintInput)
+	key.publicJWK.Kid = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	return key, nil
+}
+
+// parsePublicSigningKey loads a key that only has its public JWK stored, for verification
+func parsePublicSigningKey(record models.SigningKey) (*loadedKey, error) {
+	if record.PublicKey == nil {
+		return nil, errors.New("key has neither a private nor a public key")
+	}
+	jwk := *record.PublicKey
+	key := &loadedKey{record: record, publicJWK: jwk}
+
+	switch {
+	case jwk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %v", err)
+		}
+		key.public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		key.method = jwt.SigningMethodRS256
+
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		key.public = ed25519.PublicKey(x)
+		key.method = jwt.SigningMethodEdDSA
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"movie-vs-backend/models"
+)
+
+func TestParsePublicSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{name: "RS256", signer: rsaKey},
+		{name: "EdDSA", signer: edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tt.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileKey, err := parseSigningKey(models.SigningKey{}, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// What another instance reads back from MongoDB
+			shared, err := parsePublicSigningKey(models.SigningKey{Kid: fileKey.publicJWK.Kid, PublicKey: &fileKey.publicJWK})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shared.private != nil || shared.method.Alg() != tt.name {
+				t.Fatalf("shared key signs or has method %s, want a verify-only %s key", shared.method.Alg(), tt.name)
+			}
+
+			signed, err := jwt.NewWithClaims(fileKey.method, jwt.MapClaims{"sub": "ada"}).SignedString(fileKey.private)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return shared.public, nil }); err != nil {
+				t.Errorf("token signed with the file key doesn't verify with the shared key: %v", err)
+			}
+		})
+	}
+
+	if _, err := parsePublicSigningKey(models.SigningKey{Kid: "empty"}); err == nil {
+		t.Error("a record without any key was accepted")
+	}
+}
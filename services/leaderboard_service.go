@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// LeaderboardService builds the community-wide movie leaderboard. Computing it means
+// replaying every battle ever submitted, so it is materialised periodically and served
+// from the stored copy.
+type LeaderboardService struct {
+	battleRepo      *data_access.BattleRepository
+	leaderboardRepo *data_access.LeaderboardRepository
+	refreshMu       sync.Mutex
+}
+
+func NewLeaderboardService(battleRepo *data_access.BattleRepository, leaderboardRepo *data_access.LeaderboardRepository) *LeaderboardService {
+	return &LeaderboardService{
+		battleRepo:      battleRepo,
+		leaderboardRepo: leaderboardRepo,
+	}
+}
+
+// communityRecord accumulates one movie while replaying battles
+type communityRecord struct {
+	rating  float64
+	matches int
+	wins    int
+}
+
+// Refresh recomputes the leaderboard and publishes it
+func (s *LeaderboardService) Refresh(ctx context.Context) (int, error) {
+	// One refresh at a time, a second caller would only repeat the same work
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	start := time.Now()
+	records := make(map[string]*communityRecord)
+	record := func(title string) *communityRecord {
+		r, ok := records[title]
+		if !ok {
+			r = &communityRecord{rating: 1200}
+			records[title] = r
+		}
+		return r
+	}
+
+	// Replay every battle in order as if the whole community were one player
+	err := s.battleRepo.ForEachBattleOutcome(ctx, func(outcome models.BattleOutcome) {
+		if outcome.WinnerTitle == "" || outcome.LoserTitle == "" || outcome.WinnerTitle == outcome.LoserTitle {
+			return
+		}
+		winner := record(outcome.WinnerTitle)
+		loser := record(outcome.LoserTitle)
+		winner.rating, loser.rating = eloUpdate(winner.rating, loser.rating)
+		winner.matches++
+		winner.wins++
+		loser.matches++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	personalStats, err := s.battleRepo.AggregatePersonalStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	personalByTitle := make(map[string]models.MoviePersonalStats, len(personalStats))
+	for _, stats := range personalStats {
+		personalByTitle[stats.MovieTitle] = stats
+	}
+
+	updatedAt := time.Now()
+	generation := updatedAt.UnixNano()
+	entries := make([]models.GlobalLeaderboardEntry, 0, len(records))
+	for title, r := range records {
+		entry := models.GlobalLeaderboardEntry{
+			MovieTitle:       title,
+			CommunityELO:     int(math.Round(r.rating)),
+			CommunityMatches: r.matches,
+			CommunityWins:    r.wins,
+			WinRate:          float64(r.wins) / float64(r.matches),
+			Generation:       generation,
+			UpdatedAt:        updatedAt,
+		}
+		if personal, ok := personalByTitle[title]; ok {
+			entry.AverageRating = math.Round(personal.AverageRating*10) / 10
+			entry.RankedBy = personal.RankedBy
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CommunityELO != entries[j].CommunityELO {
+			return entries[i].CommunityELO > entries[j].CommunityELO
+		}
+		return entries[i].MovieTitle < entries[j].MovieTitle
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+
+	if err := s.leaderboardRepo.Publish(ctx, entries, generation, updatedAt); err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("Global leaderboard refreshed with %d movies in %v\n", len(entries), time.Since(start))
+	return len(entries), nil
+}
+
+// StartRefresh materialises the leaderboard now and then every interval until ctx is cancelled
+func (s *LeaderboardService) StartRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		if _, err := s.Refresh(ctx); err != nil {
+			fmt.Printf("Error refreshing global leaderboard: %v\n", err)
+		}
+	}()
+
+	runPeriodically(ctx, "global leaderboard refresh", interval, func(ctx context.Context) error {
+		_, err := s.Refresh(ctx)
+		return err
+	})
+}
+
+// GetLeaderboard returns one page of the last materialised leaderboard
+func (s *LeaderboardService) GetLeaderboard(ctx context.Context, page, pageSize, minMatches int) (*models.GlobalLeaderboardResponse, error) {
+	entries, total, updatedAt, err := s.leaderboardRepo.List(ctx, int64((page-1)*pageSize), int64(pageSize), minMatches)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GlobalLeaderboardResponse{
+		Movies:     entries,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		MinMatches: minMatches,
+		UpdatedAt:  updatedAt,
+	}, nil
+}
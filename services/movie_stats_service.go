@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// MovieStatsService describes one movie in a user's list: its head-to-head record, its
+// standing among the user's other movies and the community's view of it
+type MovieStatsService struct {
+	userRepo        *data_access.UserRepository
+	movieRepo       *data_access.MovieRepository
+	battleRepo      *data_access.BattleRepository
+	leaderboardRepo *data_access.LeaderboardRepository
+}
+
+func NewMovieStatsService(
+	userRepo *data_access.UserRepository,
+	movieRepo *data_access.MovieRepository,
+	battleRepo *data_access.BattleRepository,
+	leaderboardRepo *data_access.LeaderboardRepository,
+) *MovieStatsService {
+	return &MovieStatsService{
+		userRepo:        userRepo,
+		movieRepo:       movieRepo,
+		battleRepo:      battleRepo,
+		leaderboardRepo: leaderboardRepo,
+	}
+}
+
+// GetStats returns the stats of a movie in the user's list. The ID is the one on the
+// user's ranking, or a catalog ID.
+func (s *MovieStatsService) GetStats(ctx context.Context, userID, movieID primitive.ObjectID) (*models.MovieStatsResponse, error) {
+	ranking, err := s.findRanking(ctx, userID, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindRankedUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	response := &models.MovieStatsResponse{
+		Ranking: *ranking,
+	}
+	if ranking.MatchCount > 0 {
+		response.WinRate = math.Round(float64(ranking.WinCount)/float64(ranking.MatchCount)*1000) / 1000
+	}
+
+	// Standing among the battled or seen movies, not the catalog still at its starting
+	// rating; ties share a position
+	ratings := make(map[string]int, len(user.MovieRankings))
+	higher, lower := 0, 0
+	for _, other := range user.MovieRankings {
+		ratings[other.MovieTitle] = other.ELORating
+		if other.MatchCount == 0 && !other.Seen {
+			continue
+		}
+		response.RankedMovies++
+		if other.MovieTitle == ranking.MovieTitle {
+			continue
+		}
+		if other.ELORating > ranking.ELORating {
+			higher++
+		} else if other.ELORating < ranking.ELORating {
+			lower++
+		}
+	}
+	if ranking.MatchCount > 0 {
+		response.Position = higher + 1
+		percentile := 100.0
+		if others := response.RankedMovies - 1; others > 0 {
+			percentile = math.Round(float64(lower)/float64(others)*1000) / 10
+		}
+		response.Percentile = &percentile
+	}
+
+	opponents, err := s.battleRepo.FindOpponents(ctx, userID, ranking.MovieTitle)
+	if err != nil {
+		return nil, err
+	}
+	for i := range opponents {
+		opponents[i].OpponentELO = ratings[opponents[i].MovieTitle]
+	}
+	response.Opponents = opponents
+
+	if movie, err := s.movieRepo.FindCatalogMovieByTitle(ctx, ranking.MovieTitle); err != nil {
+		fmt.Printf("Error finding catalog entry of %s: %v\n", ranking.MovieTitle, err)
+	} else {
+		response.Movie = movie
+	}
+
+	community, err := s.leaderboardRepo.FindByTitle(ctx, ranking.MovieTitle)
+	if err != nil {
+		return nil, fmt.Errorf("error finding leaderboard entry: %v", err)
+	}
+	if community != nil {
+		response.Community = community
+		difference := ranking.ELORating - community.CommunityELO
+		response.CommunityDifference = &difference
+	}
+
+	return response, nil
+}
+
+// findRanking resolves a ranking ID or, failing that, a catalog ID to the user's ranking
+func (s *MovieStatsService) findRanking(ctx context.Context, userID, movieID primitive.ObjectID) (*models.MovieRanking, error) {
+	rankings, found, err := s.battleRepo.GetMovieRankings(ctx, userID, []models.Movie{{ID: movieID}})
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie ranking: %v", err)
+	}
+	if found[0] {
+		return rankings[0], nil
+	}
+
+	movie, err := s.movieRepo.FindCatalogMovieByID(ctx, movieID)
+	if err != nil {
+		return nil, err
+	}
+	if movie == nil {
+		return nil, ErrRankingNotFound
+	}
+	rankings, found, err = s.battleRepo.GetMovieRankings(ctx, userID, []models.Movie{{Title: movie.Title}})
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie ranking: %v", err)
+	}
+	if !found[0] {
+		return nil, ErrRankingNotFound
+	}
+	return rankings[0], nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	ErrInvalidState    = errors.New("login session expired or invalid")
+	ErrIdentityLinked  = errors.New("this provider account is already linked to another user")
+	ErrUnverifiedEmail = errors.New("an account with this email already exists, log in with your password and link the provider from your account")
+)
+
+// How long a user has to complete the provider login before the state is discarded
+const oidcLoginTTL = 10 * time.Minute
+
+type OIDCService struct {
+	providers   map[string]*data_access.OIDCClient
+	userRepo    *data_access.UserRepository
+	loginRepo   *data_access.OIDCLoginRepository
+	authService *AuthService
+}
+
+func NewOIDCService(
+	providers []*data_access.OIDCClient,
+	userRepo *data_access.UserRepository,
+	loginRepo *data_access.OIDCLoginRepository,
+	authService *AuthService,
+) *OIDCService {
+	byName := make(map[string]*data_access.OIDCClient)
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	return &OIDCService{
+		providers:   byName,
+		userRepo:    userRepo,
+		loginRepo:   loginRepo,
+		authService: authService,
+	}
+}
+
+// Providers returns the names of the configured providers
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// BeginLogin starts an authorization code flow with PKCE and returns the provider URL to visit.
+// An optional guest token is merged into the account once the login completes.
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName, guestToken string) (string, error) {
+	return s.begin(ctx, providerName, &models.OIDCPendingLogin{GuestToken: guestToken})
+}
+
+// BeginLink starts a provider login that links the provider account to the logged-in user.
+// This is how accounts whose email isn't verified get a provider linked.
+func (s *OIDCService) BeginLink(ctx context.Context, providerName string, userID primitive.ObjectID) (string, error) {
+	return s.begin(ctx, providerName, &models.OIDCPendingLogin{LinkUserID: &userID})
+}
+
+func (s *OIDCService) begin(ctx context.Context, providerName string, login *models.OIDCPendingLogin) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := provider.AuthorizationURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return "", err
+	}
+
+	// The callback may reach another instance, so the secrets are kept in MongoDB
+	login.State = state
+	login.Provider = providerName
+	login.CodeVerifier = verifier
+	login.Nonce = nonce
+	login.ExpiresAt = time.Now().Add(oidcLoginTTL)
+	if err := s.loginRepo.Create(ctx, login); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteLogin handles the provider callback: it exchanges the code, verifies the ID token,
+// finds, links or creates the local user and returns our own access token, or a two-factor
+// challenge for accounts with MFA enabled
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerName, state, code string) (*models.LoginResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	login, err := s.loginRepo.Take(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("error finding login: %v", err)
+	}
+	// Expired logins linger until MongoDB's TTL monitor gets to them
+	if login == nil || login.Provider != providerName || time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	tokens, err := provider.ExchangeCode(ctx, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	if login.LinkUserID != nil {
+		user, err = s.linkUser(ctx, providerName, claims, *login.LinkUserID)
+	} else {
+		user, err = s.findOrCreateUser(ctx, providerName, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	return s.authService.finishLogin(ctx, user, login.GuestToken)
+}
+
+// linkUser links the provider identity to the user who started the login with BeginLink
+func (s *OIDCService) linkUser(ctx context.Context, providerName string, claims *models.OIDCClaims, userID primitive.ObjectID) (*models.User, error) {
+	linked, err := s.userRepo.FindByIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("error finding linked user: %v", err)
+	}
+	if linked != nil {
+		if linked.ID != userID {
+			return nil, ErrIdentityLinked
+		}
+		return linked, nil
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	identity := models.FederatedIdentity{
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+	if err := s.userRepo.AddIdentity(ctx, user.ID, identity); err != nil {
+		return nil, fmt.Errorf("error linking identity: %v", err)
+	}
+	fmt.Printf("Linked %s identity to user %s\n", providerName, user.ID.Hex())
+	return user, nil
+}
+
+// findOrCreateUser resolves the local account for a provider identity. An existing email
+// account is only linked when both the provider and our own verification link confirmed the
+// email; otherwise the user has to log in with their password and use BeginLink.
+func (s *OIDCService) findOrCreateUser(ctx context.Context, providerName string, claims *models.OIDCClaims) (*models.User, error) {
+	user, err := s.userRepo.FindByIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("error finding linked user: %v", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	identity := models.FederatedIdentity{
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	if claims.Email != "" {
+		existing, err := s.userRepo.FindByEmail(ctx, claims.Email)
+		if err != nil {
+			return nil, fmt.Errorf("error finding user by email: %v", err)
+		}
+		if existing != nil {
+			if !claims.EmailVerified || !existing.EmailVerified {
+				return nil, ErrUnverifiedEmail
+			}
+			if err := s.userRepo.AddIdentity(ctx, existing.ID, identity); err != nil {
+				return nil, fmt.Errorf("error linking identity: %v", err)
+			}
+			fmt.Printf("Linked %s identity to user %s\n", providerName, existing.ID.Hex())
+			return existing, nil
+		}
+	}
+
+	// New federated users start with the same rankings as password registrations
+	movieRankings, err := helper.InitializeMovieRankings()
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.RoleUser
+	if claims.EmailVerified {
+		role = s.authService.roleForEmail(claims.Email, role)
+	}
+
+	user = &models.User{
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Role:          role,
+		DisplayName:   claims.Name,
+		Privacy:       models.DefaultPrivacySettings(),
+		CreatedAt:     time.Now(),
+		LastLogin:     time.Now(),
+		Identities:    []models.FederatedIdentity{identity},
+		MovieRankings: movieRankings,
+	}
+
+	if err := s.userRepo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+const mockClientID = "movie-vs"
+
+// mockIssuer is a minimal OpenID Connect provider serving discovery, a JWKS and a token
+// endpoint. Each authorization code hands out an ID token with the claims registered for it.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &mockIssuer{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.OIDCDiscovery{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.JSONWebKeySet{Keys: []models.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "mock",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+// token redeems a code once, checking the PKCE verifier against the challenge it was issued for
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.Form.Get("code")]
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || r.Form.Get("client_id") != mockClientID ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.OIDCTokenResponse{Error: "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss": m.server.URL,
+		"aud": mockClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(models.OIDCTokenResponse{IDToken: idToken, TokenType: "Bearer"})
+}
+
+// authorize plays the user signing in at the provider: it registers a code for the request
+// in authURL and returns the state and code the provider redirects back with. The nonce of
+// the request is used unless claims sets one.
+func (m *mockIssuer) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (string, string) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	grant := mockGrant{challenge: query.Get("code_challenge"), claims: jwt.MapClaims{"nonce": query.Get("nonce")}}
+	for name, value := range claims {
+		grant.claims[name] = value
+	}
+	code := primitive.NewObjectID().Hex()
+
+	m.mu.Lock()
+	m.codes[code] = grant
+	m.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+// newTestKeyService returns a key service signing with a fresh key, without a key repository
+func newTestKeyService(t *testing.T) *KeyService {
+	keyService, err := NewKeyService(nil, AlgEdDSA, 0, 48*time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := keyService.newSigningKeyRecord(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keyService.loadSigningKey(*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyService.active = key
+	keyService.keys[record.Kid] = key
+	keyService.lastReload = time.Now()
+	return keyService
+}
+
+// startedCommands lists the MongoDB commands sent so far
+func startedCommands(mt *mtest.T) []string {
+	var names []string
+	for _, started := range mt.GetAllStartedEvents() {
+		names = append(names, started.CommandName)
+	}
+	return names
+}
+
+// takenLogin answers the lookup of the login that BeginLogin just saved with the saved document
+func takenLogin(mt *mtest.T) bson.D {
+	var saved bson.Raw
+	for _, started := range mt.GetAllStartedEvents() {
+		if started.CommandName == "insert" {
+			saved = started.Command.Lookup("documents", "0").Document()
+		}
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(saved, &doc); err != nil {
+		mt.Fatal(err)
+	}
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: doc})
+}
+
+func TestCompleteLogin(t *testing.T) {
+	issuer := newMockIssuer(t)
+	keyService := newTestKeyService(t)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	userID := primitive.NewObjectID()
+	noUser := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch)
+	existing := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "email", Value: "ada@example.com"},
+		{Key: "role", Value: models.RoleUser},
+	})
+	verified := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "email", Value: "ada@example.com"},
+		{Key: "email_verified", Value: true},
+		{Key: "role", Value: models.RoleUser},
+	})
+	withMFA := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "email", Value: "ada@example.com"},
+		{Key: "role", Value: models.RoleUser},
+		{Key: "mfa_enabled", Value: true},
+	})
+	otherUser := mtest.CreateCursorResponse(0, "movievs.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "role", Value: models.RoleUser},
+	})
+	updated := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+	inserted := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})
+
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		state     string // Overrides the state the provider redirects back with
+		link      bool   // Started by the logged-in user with BeginLink
+		responses []bson.D
+		wantErr   string
+		wantMFA   bool
+		commands  []string // Sent by CompleteLogin
+	}{
+		{
+			name:      "unknown state",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": true},
+			state:     "forged",
+			responses: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})},
+			wantErr:   ErrInvalidState.Error(),
+			commands:  []string{"findAndModify"},
+		},
+		{
+			name:     "nonce mismatch",
+			claims:   jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": true, "nonce": "replayed"},
+			wantErr:  "nonce mismatch",
+			commands: []string{"findAndModify"},
+		},
+		{
+			name:      "unverified provider email is not linked",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": false},
+			responses: []bson.D{noUser, verified},
+			wantErr:   ErrUnverifiedEmail.Error(),
+			commands:  []string{"findAndModify", "find", "find"},
+		},
+		{
+			name:      "unverified local email is not linked",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": true},
+			responses: []bson.D{noUser, existing},
+			wantErr:   ErrUnverifiedEmail.Error(),
+			commands:  []string{"findAndModify", "find", "find"},
+		},
+		{
+			name:      "verified email is linked",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": "true"},
+			responses: []bson.D{noUser, verified, updated, updated},
+			commands:  []string{"findAndModify", "find", "find", "update", "update"},
+		},
+		{
+			name:      "linked user with MFA gets a challenge",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": true},
+			responses: []bson.D{withMFA, inserted},
+			wantMFA:   true,
+			commands:  []string{"findAndModify", "find", "insert"},
+		},
+		{
+			name:      "logged-in user links an unverified email",
+			claims:    jwt.MapClaims{"sub": "ada", "email": "ada@example.com", "email_verified": false},
+			link:      true,
+			responses: []bson.D{noUser, existing, updated, updated},
+			commands:  []string{"findAndModify", "find", "find", "update", "update"},
+		},
+		{
+			name:      "identity of another user is not linked",
+			claims:    jwt.MapClaims{"sub": "ada"},
+			link:      true,
+			responses: []bson.D{otherUser},
+			wantErr:   ErrIdentityLinked.Error(),
+			commands:  []string{"findAndModify", "find"},
+		},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(inserted)
+
+			db := data_access.WrapMongoDB(mt.Client, "movievs")
+			userRepo := data_access.NewUserRepository(db)
+			authService := NewAuthService(userRepo, data_access.NewMFAAttemptRepository(db), keyService, nil, nil)
+			provider := data_access.NewOIDCClient("mock", issuer.server.URL, mockClientID, "", "http://localhost/callback", []string{"openid", "email"})
+			service := NewOIDCService([]*data_access.OIDCClient{provider}, userRepo, data_access.NewOIDCLoginRepository(db), authService)
+
+			ctx := context.Background()
+			var authURL string
+			var err error
+			if tt.link {
+				authURL, err = service.BeginLink(ctx, "mock", userID)
+			} else {
+				authURL, err = service.BeginLogin(ctx, "mock", "")
+			}
+			if err != nil {
+				mt.Fatalf("starting the login: %v", err)
+			}
+			state, code := issuer.authorize(mt.T, authURL, tt.claims)
+			if tt.state != "" {
+				state = tt.state
+			} else {
+				mt.AddMockResponses(takenLogin(mt))
+			}
+			mt.AddMockResponses(tt.responses...)
+			mt.ClearEvents()
+
+			response, err := service.CompleteLogin(ctx, "mock", state, code)
+			if got := strings.Join(startedCommands(mt), ","); got != strings.Join(tt.commands, ",") {
+				mt.Errorf("commands = %q, want %q", got, strings.Join(tt.commands, ","))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					mt.Fatalf("CompleteLogin error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				mt.Fatalf("CompleteLogin: %v", err)
+			}
+
+			if tt.wantMFA {
+				if !response.MFARequired || response.MFAToken == "" || response.Token != "" {
+					mt.Fatalf("response = %+v, want only a two-factor challenge", response)
+				}
+				return
+			}
+			token, err := jwt.Parse(response.Token, keyService.Keyfunc)
+			if err != nil {
+				mt.Fatalf("invalid access token: %v", err)
+			}
+			claims := token.Claims.(jwt.MapClaims)
+			if claims["user_id"] != userID.Hex() || claims["typ"] != TokenTypeAccess {
+				mt.Errorf("token claims = %v, want an access token for %s", claims, userID.Hex())
+			}
+		})
+	}
+}
+
+func TestCompleteLoginStateIsSingleUse(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("removed on first use", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		provider := data_access.NewOIDCClient("mock", issuer.server.URL, mockClientID, "", "http://localhost/callback", []string{"openid"})
+		service := NewOIDCService([]*data_access.OIDCClient{provider}, nil, data_access.NewOIDCLoginRepository(data_access.WrapMongoDB(mt.Client, "movievs")), nil)
+
+		ctx := context.Background()
+		authURL, err := service.BeginLogin(ctx, "mock", "")
+		if err != nil {
+			mt.Fatalf("BeginLogin: %v", err)
+		}
+		state, code := issuer.authorize(mt.T, authURL, jwt.MapClaims{"sub": "ada"})
+		mt.AddMockResponses(takenLogin(mt))
+		mt.ClearEvents()
+
+		// The first callback fails at the provider, the state must be gone all the same
+		if _, err := service.CompleteLogin(ctx, "mock", state, "wrong-"+code); err == nil {
+			mt.Fatal("CompleteLogin accepted an unknown code")
+		}
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "findAndModify" ||
+			!started.Command.Lookup("remove").Boolean() ||
+			started.Command.Lookup("query", "_id").StringValue() != state {
+			mt.Fatalf("the login wasn't removed when it was looked up: %v", started)
+		}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+		if _, err := service.CompleteLogin(ctx, "mock", state, code); !errors.Is(err, ErrInvalidState) {
+			mt.Fatalf("CompleteLogin error = %v, want %v", err, ErrInvalidState)
+		}
+	})
+}
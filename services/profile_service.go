@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// How long an email change verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+var ErrInvalidPassword = errors.New("current password is incorrect")
+
+type ProfileService struct {
+	userRepo   *data_access.UserRepository
+	mailer     *data_access.Mailer
+	appBaseURL string
+}
+
+func NewProfileService(userRepo *data_access.UserRepository, mailer *data_access.Mailer, appBaseURL string) *ProfileService {
+	return &ProfileService{
+		userRepo:   userRepo,
+		mailer:     mailer,
+		appBaseURL: strings.TrimSuffix(appBaseURL, "/"),
+	}
+}
+
+func (s *ProfileService) GetProfile(ctx context.Context, userID primitive.ObjectID) (*models.ProfileResponse, error) {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding user: %v", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return toProfileResponse(user), nil
+}
+
+// UpdateProfile applies a partial profile update and returns the new profile
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID primitive.ObjectID, req *models.UpdateProfileRequest) (*models.ProfileResponse, error) {
+	fields := bson.M{}
+
+	if req.DisplayName != nil {
+		fields["display_name"] = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*req.AvatarURL)
+		if avatarURL != "" {
+			parsed, err := url.Parse(avatarURL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return nil, errors.New("avatar_url must be an http(s) URL")
+			}
+		}
+		fields["avatar_url"] = avatarURL
+	}
+	if req.FavoriteGenres != nil {
+		genres := []string{}
+		seen := make(map[string]bool)
+		for _, genre := range *req.FavoriteGenres {
+			genre = strings.TrimSpace(genre)
+			if genre != "" && !seen[strings.ToLower(genre)] {
+				seen[strings.ToLower(genre)] = true
+				genres = append(genres, genre)
+			}
+		}
+		fields["favorite_genres"] = genres
+	}
+	if privacy := req.Privacy; privacy != nil {
+		if privacy.ProfileVisibility != nil {
+			fields["privacy.profile_visibility"] = *privacy.ProfileVisibility
+		}
+		if privacy.RankingsVisibility != nil {
+			fields["privacy.rankings_visibility"] = *privacy.RankingsVisibility
+		}
+		if privacy.Discoverable != nil {
+			fields["privacy.discoverable"] = *privacy.Discoverable
+		}
+	}
+
+	if len(fields) > 0 {
+		if err := s.userRepo.UpdateProfile(ctx, userID, fields); err != nil {
+			return nil, fmt.Errorf("error updating profile: %v", err)
+		}
+	}
+
+	return s.GetProfile(ctx, userID)
+}
+
+// RequestEmailChange stores the new address as pending and mails it a verification link.
+// The email only changes once VerifyEmailChange is called with the token from that link.
+func (s *ProfileService) RequestEmailChange(ctx context.Context, userID primitive.ObjectID, req *models.ChangeEmailRequest) error {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := checkPassword(user, req.Password); err != nil {
+		return err
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return errors.New("new email is the same as the current one")
+	}
+
+	existing, err := s.userRepo.FindByEmail(ctx, newEmail)
+	if err != nil {
+		return fmt.Errorf("error checking email: %v", err)
+	}
+	if existing != nil {
+		return errors.New("email already in use")
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetPendingEmail(ctx, userID, newEmail, hashToken(token), time.Now().Add(emailVerificationTTL)); err != nil {
+		return fmt.Errorf("error saving pending email: %v", err)
+	}
+
+	link := s.appBaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Confirm your new Movie VS email address by opening this link within 24 hours:\n\n%s\n\nIf you didn't ask for this, you can ignore this email.", link)
+	return s.mailer.Send(newEmail, "Confirm your new email address", body)
+}
+
+// VerifyEmailChange completes an email change with the token from the verification link
+func (s *ProfileService) VerifyEmailChange(ctx context.Context, token string) error {
+	user, err := s.userRepo.FindByEmailVerificationHash(ctx, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("error finding verification: %v", err)
+	}
+	if user == nil || user.PendingEmail == "" || time.Now().After(user.EmailVerificationExpiry) {
+		return errors.New("verification link is invalid or has expired")
+	}
+
+	// The address may have been taken since the change was requested
+	existing, err := s.userRepo.FindByEmail(ctx, user.PendingEmail)
+	if err != nil {
+		return fmt.Errorf("error checking email: %v", err)
+	}
+	if existing != nil {
+		return errors.New("email already in use")
+	}
+
+	return s.userRepo.ConfirmEmailChange(ctx, user.ID, user.PendingEmail)
+}
+
+// ChangePassword replaces the password after checking the current one. Accounts created
+// through social login have no password yet and can set one directly.
+func (s *ProfileService) ChangePassword(ctx context.Context, userID primitive.ObjectID, req *models.ChangePasswordRequest) error {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := checkPassword(user, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword))
+}
+
+// checkPassword verifies the password of accounts that have one
+func checkPassword(user *models.User, password string) error {
+	if user.Password == "" {
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// hashToken returns the SHA-256 of a random token, so only hashes are stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toProfileResponse(user *models.User) *models.ProfileResponse {
+	favoriteGenres := user.FavoriteGenres
+	if favoriteGenres == nil {
+		favoriteGenres = []string{}
+	}
+
+	return &models.ProfileResponse{
+		ID:             user.ID,
+		Email:          user.Email,
+		PendingEmail:   user.PendingEmail,
+		Role:           user.Role,
+		DisplayName:    user.DisplayName,
+		AvatarURL:      user.AvatarURL,
+		FavoriteGenres: favoriteGenres,
+		Privacy:        user.Privacy,
+		MFAEnabled:     user.MFAEnabled,
+		HasPassword:    user.Password != "",
+		DeletionAt:     user.DeletionScheduledAt,
+		CreatedAt:      user.CreatedAt,
+		LastLogin:      user.LastLogin,
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/models"
+)
+
+// Formats the ranked list can be exported in
+const (
+	RankingExportCSV        = "csv"
+	RankingExportJSON       = "json"
+	RankingExportLetterboxd = "letterboxd"
+	RankingExportMarkdown   = "markdown"
+)
+
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// RankingExportFile returns the content type and file extension of an export format
+func RankingExportFile(format string) (string, string, error) {
+	switch format {
+	case RankingExportCSV, RankingExportLetterboxd:
+		return "text/csv; charset=utf-8", "csv", nil
+	case RankingExportJSON:
+		return "application/json; charset=utf-8", "json", nil
+	case RankingExportMarkdown:
+		return "text/markdown; charset=utf-8", "md", nil
+	}
+	return "", "", ErrUnknownExportFormat
+}
+
+// rankingExportWriter writes the ranked list one movie at a time
+type rankingExportWriter interface {
+	begin() error
+	write(movie *models.RankedMovie) error
+	end() error
+}
+
+// ExportRankings streams every movie the user has battled or seen, best first, with its
+// catalog details in the given format
+func (s *GameService) ExportRankings(ctx context.Context, userID primitive.ObjectID, format string, w io.Writer) error {
+	var writer rankingExportWriter
+	switch format {
+	case RankingExportCSV:
+		writer = &csvRankingWriter{writer: csv.NewWriter(w), header: rankingCSVHeader, row: rankingCSVRow, spreadsheet: true}
+	case RankingExportLetterboxd:
+		writer = &csvRankingWriter{writer: csv.NewWriter(w), header: letterboxdCSVHeader, row: letterboxdCSVRow}
+	case RankingExportJSON:
+		writer = &jsonRankingWriter{w: w}
+	case RankingExportMarkdown:
+		writer = &markdownRankingWriter{w: w}
+	default:
+		return ErrUnknownExportFormat
+	}
+
+	if err := writer.begin(); err != nil {
+		return err
+	}
+	if err := s.battleRepo.ForEachRankedMovie(ctx, userID, writer.write); err != nil {
+		return err
+	}
+	return writer.end()
+}
+
+var rankingCSVHeader = []string{
+	"position", "title", "year", "elo_rating", "match_count", "win_count", "loss_count", "win_rate",
+	"seen", "genres", "director", "actors", "imdb_id", "imdb_rating", "last_updated",
+}
+
+func rankingCSVRow(movie *models.RankedMovie) []string {
+	ranking := movie.Ranking
+	details := exportMovieDetails(movie)
+	return []string{
+		strconv.Itoa(movie.Position),
+		ranking.MovieTitle,
+		exportYear(movie),
+		strconv.Itoa(ranking.ELORating),
+		strconv.Itoa(ranking.MatchCount),
+		strconv.Itoa(ranking.WinCount),
+		strconv.Itoa(ranking.LossCount),
+		strconv.FormatFloat(math.Round(movie.WinRate*1000)/1000, 'f', -1, 64),
+		strconv.FormatBool(ranking.Seen || ranking.MatchCount > 0),
+		strings.Join(ranking.Genres, ", "),
+		details.Director,
+		details.Actors,
+		details.IMDBID,
+		details.IMDBRating,
+		ranking.LastUpdated.UTC().Format(time.RFC3339),
+	}
+}
+
+// Columns of Letterboxd's import format
+var letterboxdCSVHeader = []string{"Title", "Year", "imdbID", "Directors", "Rating"}
+
+func letterboxdCSVRow(movie *models.RankedMovie) []string {
+	details := exportMovieDetails(movie)
+	return []string{
+		movie.Ranking.MovieTitle,
+		exportYear(movie),
+		details.IMDBID,
+		details.Director,
+		letterboxdStars(movie.Ranking),
+	}
+}
+
+// letterboxdStars converts an ELO back onto Letterboxd's half-star scale, the inverse of
+// how imports seed ratings. Movies that were only marked as seen are left unrated.
+func letterboxdStars(ranking models.MovieRanking) string {
+	if ranking.MatchCount == 0 && ranking.ELORating == 1200 {
+		return ""
+	}
+	rating := math.Round(5.5 + float64(ranking.ELORating-1200)/importRatingStep)
+	rating = math.Max(1, math.Min(10, rating))
+	return strconv.FormatFloat(rating/2, 'f', -1, 64)
+}
+
+type csvRankingWriter struct {
+	writer *csv.Writer
+	header []string
+	row    func(movie *models.RankedMovie) []string
+	// Guard cells against formulas, for files opened in a spreadsheet rather than imported
+	spreadsheet bool
+}
+
+func (w *csvRankingWriter) begin() error {
+	return w.writer.Write(w.header)
+}
+
+func (w *csvRankingWriter) write(movie *models.RankedMovie) error {
+	row := w.row(movie)
+	if w.spreadsheet {
+		for i, cell := range row {
+			row[i] = csvSafe(cell)
+		}
+	}
+	return w.writer.Write(row)
+}
+
+// csvSafe keeps spreadsheets from running a cell as a formula, e.g. a movie titled
+// "=HYPERLINK(...)", by prefixing it with an apostrophe
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (w *csvRankingWriter) end() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonRankingWriter writes a JSON array, one movie per line
+type jsonRankingWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonRankingWriter) begin() error {
+	_, err := io.WriteString(w.w, "[")
+	return err
+}
+
+func (w *jsonRankingWriter) write(movie *models.RankedMovie) error {
+	encoded, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+	separator := "\n"
+	if w.count > 0 {
+		separator = ",\n"
+	}
+	w.count++
+	if _, err := io.WriteString(w.w, separator); err != nil {
+		return err
+	}
+	_, err = w.w.Write(encoded)
+	return err
+}
+
+func (w *jsonRankingWriter) end() error {
+	_, err := io.WriteString(w.w, "\n]\n")
+	return err
+}
+
+// markdownRankingWriter writes a table for pasting into notes, READMEs or forum posts
+type markdownRankingWriter struct {
+	w io.Writer
+}
+
+func (w *markdownRankingWriter) begin() error {
+	_, err := fmt.Fprintf(w.w, "# My movie rankings\n\nExported %s\n\n| # | Title | Year | ELO | W-L | Win rate |\n|---:|---|---:|---:|---:|---:|\n",
+		time.Now().UTC().Format("2006-01-02"))
+	return err
+}
+
+func (w *markdownRankingWriter) write(movie *models.RankedMovie) error {
+	ranking := movie.Ranking
+	title := strings.NewReplacer("|", `\|`, "\n", " ").Replace(ranking.MovieTitle)
+	_, err := fmt.Fprintf(w.w, "| %d | %s | %s | %d | %d-%d | %.0f%% |\n",
+		movie.Position, title, exportYear(movie), ranking.ELORating, ranking.WinCount, ranking.LossCount, movie.WinRate*100)
+	return err
+}
+
+func (w *markdownRankingWriter) end() error {
+	return nil
+}
+
+// exportMovieDetails returns the catalog entry of a ranked movie, or an empty one
+func exportMovieDetails(movie *models.RankedMovie) models.Movie {
+	if movie.Movie == nil {
+		return models.Movie{}
+	}
+	return *movie.Movie
+}
+
+func exportYear(movie *models.RankedMovie) string {
+	if movie.Ranking.Year > 0 {
+		return strconv.Itoa(movie.Ranking.Year)
+	}
+	if movie.Movie != nil {
+		return movie.Movie.Year
+	}
+	return ""
+}
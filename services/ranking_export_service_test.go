@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{cell: "", want: ""},
+		{cell: "Alien", want: "Alien"},
+		{cell: "1979", want: "1979"},
+		{cell: "Fast & Furious 6", want: "Fast & Furious 6"},
+		{cell: `=HYPERLINK("http://evil","x")`, want: `'=HYPERLINK("http://evil","x")`},
+		{cell: "+1", want: "'+1"},
+		{cell: "-2+3", want: "'-2+3"},
+		{cell: "@SUM(A1)", want: "'@SUM(A1)"},
+		{cell: "\t=1", want: "'\t=1"},
+		{cell: "Mission=Impossible", want: "Mission=Impossible"},
+	}
+
+	for _, tt := range tests {
+		if got := csvSafe(tt.cell); got != tt.want {
+			t.Errorf("csvSafe(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
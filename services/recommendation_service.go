@@ -0,0 +1,504 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+)
+
+const (
+	// Most similar items kept per movie in the item-item model
+	maxItemNeighbours = 50
+	// Fewest users that must have battled both movies for their similarity to count
+	minCoRaters = 2
+	// Share of the final score coming from catalog content rather than other users
+	contentWeight = 0.3
+)
+
+var ErrModelNotReady = errors.New("recommendation model is not built yet")
+
+// RecommendationService suggests movies a user hasn't battled yet. Building the model reads
+// every user's rankings, so it is kept in memory and rebuilt periodically.
+type RecommendationService struct {
+	userRepo  *data_access.UserRepository
+	movieRepo *data_access.MovieRepository
+	model     *recommendationModel
+	modelMu   sync.RWMutex
+	refreshMu sync.Mutex
+}
+
+func NewRecommendationService(userRepo *data_access.UserRepository, movieRepo *data_access.MovieRepository) *RecommendationService {
+	return &RecommendationService{
+		userRepo:  userRepo,
+		movieRepo: movieRepo,
+	}
+}
+
+// userPreferences are a user's normalised preferences by movie title: how far above or
+// below their own average a movie is rated, in standard deviations
+type userPreferences map[string]float64
+
+// contentFeatures describe a catalog movie for content similarity
+type contentFeatures struct {
+	genres    map[string]bool
+	directors map[string]bool
+	actors    map[string]bool
+}
+
+type neighbour struct {
+	title      string
+	similarity float64
+}
+
+type recommendationModel struct {
+	neighbours map[string][]neighbour
+	content    map[string]contentFeatures
+	catalog    map[string]models.Movie
+	builtAt    time.Time
+}
+
+// Refresh rebuilds the model from all users' rankings and the catalog
+func (s *RecommendationService) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	start := time.Now()
+	users, catalog, err := s.loadTrainingData(ctx)
+	if err != nil {
+		return err
+	}
+
+	var preferences []userPreferences
+	for _, prefs := range users {
+		preferences = append(preferences, prefs)
+	}
+	model := buildRecommendationModel(preferences, catalog)
+
+	s.modelMu.Lock()
+	s.model = model
+	s.modelMu.Unlock()
+
+	fmt.Printf("Recommendation model built from %d users and %d catalog movies in %v\n", len(users), len(catalog), time.Since(start))
+	return nil
+}
+
+// StartRefresh builds the model now and then every interval until ctx is cancelled
+func (s *RecommendationService) StartRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		if err := s.Refresh(ctx); err != nil {
+			fmt.Printf("Error building recommendation model: %v\n", err)
+		}
+	}()
+
+	runPeriodically(ctx, "recommendation model refresh", interval, s.Refresh)
+}
+
+// loadTrainingData returns every user's preferences and the catalog
+func (s *RecommendationService) loadTrainingData(ctx context.Context) (map[primitive.ObjectID]userPreferences, []models.Movie, error) {
+	users := make(map[primitive.ObjectID]userPreferences)
+	err := s.userRepo.ForEachRankedUser(ctx, func(user *models.User) {
+		if prefs := preferencesFromRankings(user.MovieRankings); len(prefs) > 0 {
+			users[user.ID] = prefs
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	catalog, _, err := s.movieRepo.ListCatalog(ctx, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, catalog, nil
+}
+
+// GetRecommendations returns up to limit movies the user hasn't battled, best first
+func (s *RecommendationService) GetRecommendations(ctx context.Context, userID primitive.ObjectID, limit int) (*models.RecommendationsResponse, error) {
+	s.modelMu.RLock()
+	model := s.model
+	s.modelMu.RUnlock()
+	if model == nil {
+		return nil, ErrModelNotReady
+	}
+
+	user, err := s.userRepo.FindRankedUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	prefs := preferencesFromRankings(user.MovieRankings)
+	// New users haven't battled anything yet, seed them from their favourite genres
+	var favoriteGenres map[string]bool
+	if len(prefs) == 0 {
+		favoriteGenres = make(map[string]bool)
+		for _, genre := range user.FavoriteGenres {
+			favoriteGenres[strings.ToLower(strings.TrimSpace(genre))] = true
+		}
+	}
+
+	recommendations := model.recommend(prefs, favoriteGenres, limit)
+	for i := range recommendations {
+		if movie, ok := model.catalog[recommendations[i].MovieTitle]; ok {
+			recommendations[i].Movie = &movie
+		}
+	}
+
+	return &models.RecommendationsResponse{
+		Recommendations: recommendations,
+		ModelBuiltAt:    model.builtAt,
+	}, nil
+}
+
+// Evaluate hides holdoutFraction of the battled movies of every user with enough battles,
+// trains a model on the rest and measures how many of the hidden movies the user liked
+// appear in their top k recommendations
+func (s *RecommendationService) Evaluate(ctx context.Context, k int, holdoutFraction float64) (*models.RecommendationEvaluation, error) {
+	users, catalog, err := s.loadTrainingData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A fixed seed keeps evaluations comparable between runs on the same data, as long as
+	// users draw from it in the same order
+	random := rand.New(rand.NewSource(1))
+	userIDs := make([]primitive.ObjectID, 0, len(users))
+	for userID := range users {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i].Hex() < userIDs[j].Hex() })
+
+	training := make([]userPreferences, 0, len(users))
+	heldOut := make(map[int]userPreferences)
+	for _, userID := range userIDs {
+		prefs := users[userID]
+		titles := make([]string, 0, len(prefs))
+		for title := range prefs {
+			titles = append(titles, title)
+		}
+		sort.Strings(titles)
+
+		holdout := int(float64(len(titles)) * holdoutFraction)
+		if len(titles) < 5 || holdout == 0 {
+			training = append(training, prefs)
+			continue
+		}
+
+		random.Shuffle(len(titles), func(i, j int) { titles[i], titles[j] = titles[j], titles[i] })
+		train := make(userPreferences)
+		test := make(userPreferences)
+		for i, title := range titles {
+			if i < holdout {
+				test[title] = prefs[title]
+			} else {
+				train[title] = prefs[title]
+			}
+		}
+		heldOut[len(training)] = test
+		training = append(training, train)
+	}
+
+	model := buildRecommendationModel(training, catalog)
+
+	evaluation := &models.RecommendationEvaluation{
+		K:               k,
+		HoldoutFraction: holdoutFraction,
+		EvaluatedAt:     time.Now(),
+	}
+	recommended := make(map[string]bool)
+	var precisionSum, recallSum float64
+	var usersWithHit int
+	for index, test := range heldOut {
+		liked := 0
+		for _, pref := range test {
+			if pref > 0 {
+				liked++
+			}
+		}
+		if liked == 0 {
+			continue
+		}
+
+		hits := 0
+		for _, recommendation := range model.recommend(training[index], nil, k) {
+			recommended[recommendation.MovieTitle] = true
+			if test[recommendation.MovieTitle] > 0 {
+				hits++
+			}
+		}
+
+		evaluation.UsersEvaluated++
+		precisionSum += float64(hits) / float64(k)
+		recallSum += float64(hits) / float64(liked)
+		if hits > 0 {
+			usersWithHit++
+		}
+	}
+
+	if evaluation.UsersEvaluated > 0 {
+		n := float64(evaluation.UsersEvaluated)
+		evaluation.PrecisionAtK = precisionSum / n
+		evaluation.RecallAtK = recallSum / n
+		evaluation.HitRate = float64(usersWithHit) / n
+	}
+	if len(model.content) > 0 {
+		evaluation.CatalogCoverage = float64(len(recommended)) / float64(len(model.content))
+	}
+
+	return evaluation, nil
+}
+
+// preferencesFromRankings centres and scales the ELO of the battled movies
+func preferencesFromRankings(rankings []models.MovieRanking) userPreferences {
+	var rated []models.MovieRanking
+	for _, ranking := range rankings {
+		if ranking.MatchCount > 0 {
+			rated = append(rated, ranking)
+		}
+	}
+	if len(rated) == 0 {
+		return nil
+	}
+
+	var mean float64
+	for _, ranking := range rated {
+		mean += float64(ranking.ELORating)
+	}
+	mean /= float64(len(rated))
+
+	var variance float64
+	for _, ranking := range rated {
+		d := float64(ranking.ELORating) - mean
+		variance += d * d
+	}
+	std := math.Sqrt(variance / float64(len(rated)))
+	if std < 1 {
+		std = 1
+	}
+
+	prefs := make(userPreferences, len(rated))
+	for _, ranking := range rated {
+		prefs[ranking.MovieTitle] = (float64(ranking.ELORating) - mean) / std
+	}
+	return prefs
+}
+
+// buildRecommendationModel computes item-item adjusted cosine similarities from the users'
+// preferences and the content features of the catalog
+func buildRecommendationModel(users []userPreferences, catalog []models.Movie) *recommendationModel {
+	type pairStats struct {
+		dot      float64
+		coRaters int
+	}
+
+	norms := make(map[string]float64)
+	pairs := make(map[[2]string]*pairStats)
+	for _, prefs := range users {
+		titles := make([]string, 0, len(prefs))
+		for title, pref := range prefs {
+			titles = append(titles, title)
+			norms[title] += pref * pref
+		}
+		sort.Strings(titles)
+
+		for i := range titles {
+			for j := i + 1; j < len(titles); j++ {
+				key := [2]string{titles[i], titles[j]}
+				stats, ok := pairs[key]
+				if !ok {
+					stats = &pairStats{}
+					pairs[key] = stats
+				}
+				stats.dot += prefs[titles[i]] * prefs[titles[j]]
+				stats.coRaters++
+			}
+		}
+	}
+
+	neighbours := make(map[string][]neighbour)
+	for key, stats := range pairs {
+		if stats.coRaters < minCoRaters || norms[key[0]] == 0 || norms[key[1]] == 0 {
+			continue
+		}
+		similarity := stats.dot / math.Sqrt(norms[key[0]]*norms[key[1]])
+		if similarity <= 0 {
+			continue
+		}
+		neighbours[key[0]] = append(neighbours[key[0]], neighbour{title: key[1], similarity: similarity})
+		neighbours[key[1]] = append(neighbours[key[1]], neighbour{title: key[0], similarity: similarity})
+	}
+	for title, list := range neighbours {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].similarity != list[j].similarity {
+				return list[i].similarity > list[j].similarity
+			}
+			return list[i].title < list[j].title
+		})
+		if len(list) > maxItemNeighbours {
+			list = list[:maxItemNeighbours]
+		}
+		neighbours[title] = list
+	}
+
+	content := make(map[string]contentFeatures, len(catalog))
+	catalogByTitle := make(map[string]models.Movie, len(catalog))
+	for _, movie := range catalog {
+		content[movie.Title] = contentFeatures{
+			genres:    lowerSet(helper.NormalizeGenres(movie.Genre)),
+			directors: lowerSet(strings.Split(movie.Director, ",")),
+			actors:    lowerSet(strings.Split(movie.Actors, ",")),
+		}
+		catalogByTitle[movie.Title] = movie
+	}
+
+	return &recommendationModel{
+		neighbours: neighbours,
+		content:    content,
+		catalog:    catalogByTitle,
+		builtAt:    time.Now(),
+	}
+}
+
+// recommend scores every catalog movie the user hasn't battled. Without preferences the
+// favourite genres are used instead.
+func (m *recommendationModel) recommend(prefs userPreferences, favoriteGenres map[string]bool, limit int) []models.Recommendation {
+	recommendations := []models.Recommendation{}
+	for title, features := range m.content {
+		if _, battled := prefs[title]; battled {
+			continue
+		}
+
+		var recommendation models.Recommendation
+		if len(prefs) == 0 {
+			overlap := 0
+			for genre := range features.genres {
+				if favoriteGenres[genre] {
+					overlap++
+				}
+			}
+			if overlap == 0 {
+				continue
+			}
+			recommendation = models.Recommendation{MovieTitle: title, Score: float64(overlap) / float64(len(features.genres))}
+		} else {
+			var ok bool
+			recommendation, ok = m.predict(title, features, prefs)
+			if !ok {
+				continue
+			}
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].MovieTitle < recommendations[j].MovieTitle
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations
+}
+
+// predict blends the collaborative and content based estimates of how much the user would
+// like the movie. Both are weighted averages of the user's preferences, squashed to -1..1.
+func (m *recommendationModel) predict(title string, features contentFeatures, prefs userPreferences) (models.Recommendation, bool) {
+	recommendation := models.Recommendation{MovieTitle: title}
+	var bestContribution float64
+
+	var cfSum, cfWeight float64
+	for _, n := range m.neighbours[title] {
+		pref, ok := prefs[n.title]
+		if !ok {
+			continue
+		}
+		cfSum += n.similarity * pref
+		cfWeight += n.similarity
+		if contribution := n.similarity * pref; contribution > bestContribution {
+			bestContribution = contribution
+			recommendation.BecauseYouLiked = n.title
+		}
+	}
+
+	var contentSum, contentWeightSum float64
+	for rated, pref := range prefs {
+		other, ok := m.content[rated]
+		if !ok {
+			continue
+		}
+		similarity := contentSimilarity(features, other)
+		if similarity == 0 {
+			continue
+		}
+		contentSum += similarity * pref
+		contentWeightSum += similarity
+		// Content only explains a recommendation when no similar users' movie does
+		if cfWeight == 0 {
+			if contribution := similarity * pref; contribution > bestContribution {
+				bestContribution = contribution
+				recommendation.BecauseYouLiked = rated
+			}
+		}
+	}
+
+	switch {
+	case cfWeight > 0 && contentWeightSum > 0:
+		recommendation.Score = (1-contentWeight)*math.Tanh(cfSum/cfWeight) + contentWeight*math.Tanh(contentSum/contentWeightSum)
+	case cfWeight > 0:
+		recommendation.Score = math.Tanh(cfSum / cfWeight)
+	case contentWeightSum > 0:
+		recommendation.Score = math.Tanh(contentSum / contentWeightSum)
+	default:
+		return recommendation, false
+	}
+
+	recommendation.Score = math.Round(recommendation.Score*1000) / 1000
+	return recommendation, true
+}
+
+// contentSimilarity compares two movies by genre, director and cast, from 0 to 1
+func contentSimilarity(a, b contentFeatures) float64 {
+	return 0.5*jaccard(a.genres, b.genres) + 0.2*jaccard(a.directors, b.directors) + 0.3*jaccard(a.actors, b.actors)
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	intersection := 0
+	for value := range a {
+		if b[value] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+// lowerSet trims and lower-cases the values, dropping empty ones and OMDB's "N/A"
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" && value != "n/a" {
+			set[value] = true
+		}
+	}
+	return set
+}
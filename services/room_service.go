@@ -0,0 +1,623 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+const (
+	roomCodeAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No 0/O or 1/I to misread
+	roomCodeLength     = 6
+	maxRoomMembers     = 20
+	defaultVoteSeconds = 20
+	// Messages queued for a slow connection before it is dropped
+	roomSendBuffer = 32
+	// How long an empty room is kept so its members can reconnect
+	emptyRoomTTL = 10 * time.Minute
+	// How long a client has to open the WebSocket after asking for a ticket
+	roomTicketTTL = 30 * time.Second
+)
+
+var (
+	ErrRoomNotFound      = errors.New("room not found")
+	ErrRoomFull          = errors.New("room is full")
+	ErrInvalidRoomTicket = errors.New("invalid or expired room ticket")
+)
+
+// RoomService runs group battle rooms. Rooms live in memory on the instance that created
+// them; the transport hands every member's messages to HandleMessage and writes whatever
+// arrives on the member's Send channel.
+type RoomService struct {
+	gameService *GameService
+	userRepo    *data_access.UserRepository
+	rooms       map[string]*room
+	tickets     map[string]*roomTicket
+	mu          sync.Mutex
+}
+
+func NewRoomService(gameService *GameService, userRepo *data_access.UserRepository) *RoomService {
+	return &RoomService{
+		gameService: gameService,
+		userRepo:    userRepo,
+		rooms:       make(map[string]*room),
+		tickets:     make(map[string]*roomTicket),
+	}
+}
+
+// roomTicket lets a user open one WebSocket to a room without putting their access token in
+// the URL, where it would end up in access logs
+type roomTicket struct {
+	code      string
+	userID    primitive.ObjectID
+	expiresAt time.Time
+}
+
+// RoomMember is one connection to a room
+type RoomMember struct {
+	UserID      primitive.ObjectID
+	DisplayName string
+	// Encoded messages for the member, closed when the member leaves or is dropped
+	Send   chan []byte
+	closed bool
+	mu     sync.Mutex
+}
+
+// roomRecord is a movie's rating inside a room
+type roomRecord struct {
+	rating  float64
+	matches int
+	wins    int
+}
+
+type room struct {
+	mu          sync.Mutex
+	code        string
+	hostID      primitive.ObjectID
+	genre       string
+	voteSeconds int
+	status      string
+	round       int
+	members     map[primitive.ObjectID]*RoomMember
+	pair        *models.BattleResponse
+	votes       map[primitive.ObjectID]string
+	timer       *time.Timer
+	ranking     map[string]*roomRecord
+	createdAt   time.Time
+	emptySince  time.Time
+}
+
+// CreateRoom opens a room hosted by the user
+func (s *RoomService) CreateRoom(hostID primitive.ObjectID, req *models.CreateRoomRequest) (*models.RoomState, error) {
+	voteSeconds := req.VoteSeconds
+	if voteSeconds == 0 {
+		voteSeconds = defaultVoteSeconds
+	}
+
+	now := time.Now()
+	r := &room{
+		hostID:      hostID,
+		genre:       strings.TrimSpace(req.Genre),
+		voteSeconds: voteSeconds,
+		status:      models.RoomWaiting,
+		members:     make(map[primitive.ObjectID]*RoomMember),
+		ranking:     make(map[string]*roomRecord),
+		createdAt:   now,
+		emptySince:  now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		code, err := newRoomCode()
+		if err != nil {
+			return nil, fmt.Errorf("error generating room code: %v", err)
+		}
+		if _, taken := s.rooms[code]; !taken {
+			r.code = code
+			break
+		}
+	}
+	s.rooms[r.code] = r
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state(), nil
+}
+
+// IssueTicket returns a short-lived, single-use ticket for the user to connect to the room
+func (s *RoomService) IssueTicket(code string, userID primitive.ObjectID) (*models.RoomTicketResponse, error) {
+	ticket, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[strings.ToUpper(code)]; !ok {
+		return nil, ErrRoomNotFound
+	}
+
+	// Drop tickets that were never used
+	now := time.Now()
+	for key, t := range s.tickets {
+		if now.After(t.expiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+
+	expiresAt := now.Add(roomTicketTTL)
+	s.tickets[ticket] = &roomTicket{code: strings.ToUpper(code), userID: userID, expiresAt: expiresAt}
+	return &models.RoomTicketResponse{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+// RedeemTicket uses up a ticket and returns the user it was issued to
+func (s *RoomService) RedeemTicket(code, ticket string) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	t, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	s.mu.Unlock()
+
+	if !ok || t.code != strings.ToUpper(code) || time.Now().After(t.expiresAt) {
+		return primitive.NilObjectID, ErrInvalidRoomTicket
+	}
+	return t.userID, nil
+}
+
+// GetRoom returns the current state of a room
+func (s *RoomService) GetRoom(code string) (*models.RoomState, error) {
+	r := s.findRoom(code)
+	if r == nil {
+		return nil, ErrRoomNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state(), nil
+}
+
+// Join adds a connection for the user to the room. A user has one connection per room,
+// joining again replaces the previous one.
+func (s *RoomService) Join(ctx context.Context, code string, userID primitive.ObjectID) (*RoomMember, error) {
+	r := s.findRoom(code)
+	if r == nil {
+		return nil, ErrRoomNotFound
+	}
+
+	displayName := ""
+	profiles, err := s.userRepo.FindPublicProfiles(ctx, []primitive.ObjectID{userID})
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) > 0 {
+		displayName = profiles[0].DisplayName
+	}
+
+	member := &RoomMember{
+		UserID:      userID,
+		DisplayName: displayName,
+		Send:        make(chan []byte, roomSendBuffer),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == models.RoomClosed {
+		return nil, ErrRoomNotFound
+	}
+	previous, rejoining := r.members[userID]
+	if !rejoining && len(r.members) >= maxRoomMembers {
+		return nil, ErrRoomFull
+	}
+	if rejoining {
+		previous.close()
+	}
+	r.members[userID] = member
+
+	member.send(encodeRoomMessage(models.RoomMessageState, r.state()))
+	if r.status == models.RoomVoting && r.pair != nil {
+		member.send(encodeRoomMessage(models.RoomMessageRoundStarted, models.RoomRoundStarted{
+			Round: r.round,
+			Pair:  *r.pair,
+		}))
+	}
+	if !rejoining {
+		r.broadcast(models.RoomMessageParticipantJoined, r.participant(member))
+	}
+
+	return member, nil
+}
+
+// Leave removes the member's connection from the room
+func (s *RoomService) Leave(code string, member *RoomMember) {
+	r := s.findRoom(code)
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A newer connection of the same user may have replaced this one already
+	if r.members[member.UserID] != member {
+		return
+	}
+	delete(r.members, member.UserID)
+	member.close()
+	r.broadcast(models.RoomMessageParticipantLeft, r.participant(member))
+
+	if len(r.members) == 0 {
+		r.emptySince = time.Now()
+	}
+}
+
+// HandleMessage processes a message received from a member
+func (s *RoomService) HandleMessage(code string, member *RoomMember, raw []byte) {
+	r := s.findRoom(code)
+	if r == nil {
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Room not found"}))
+		return
+	}
+
+	var message models.RoomMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Invalid message"}))
+		return
+	}
+
+	switch message.Type {
+	case models.RoomMessageVote:
+		var vote models.RoomVote
+		if err := json.Unmarshal(message.Data, &vote); err != nil {
+			member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Invalid vote"}))
+			return
+		}
+		s.vote(r, member, vote)
+	case models.RoomMessageStartRound:
+		s.startRound(r, member)
+	case models.RoomMessageClose:
+		s.closeRoom(r, member)
+	default:
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Unknown message type"}))
+	}
+}
+
+// startRound fetches a battle pair and opens it for votes. Only the host may start rounds.
+func (s *RoomService) startRound(r *room, member *RoomMember) {
+	r.mu.Lock()
+	if member.UserID != r.hostID {
+		r.mu.Unlock()
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Only the host can start a round"}))
+		return
+	}
+	if r.status != models.RoomWaiting {
+		r.mu.Unlock()
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "A round is already running"}))
+		return
+	}
+	// Voting with no pair yet keeps a second start request out while the pair is fetched
+	r.status = models.RoomVoting
+	r.pair = nil
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	pair, err := s.gameService.GetBattlePair(ctx, r.hostID, r.genre)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == models.RoomClosed {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error getting battle pair for room %s: %v\n", r.code, err)
+		r.status = models.RoomWaiting
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Failed to fetch movies"}))
+		return
+	}
+
+	r.round++
+	r.pair = pair
+	r.votes = make(map[primitive.ObjectID]string)
+	deadline := time.Now().Add(time.Duration(r.voteSeconds) * time.Second)
+	round := r.round
+	r.timer = time.AfterFunc(time.Until(deadline), func() { s.finishRound(r, round) })
+
+	r.broadcast(models.RoomMessageRoundStarted, models.RoomRoundStarted{
+		Round:    round,
+		Pair:     *pair,
+		Deadline: deadline,
+	})
+}
+
+// vote records or changes a member's vote in the current round
+func (s *RoomService) vote(r *room, member *RoomMember, vote models.RoomVote) {
+	r.mu.Lock()
+	if r.status != models.RoomVoting || r.pair == nil || vote.Round != r.round {
+		r.mu.Unlock()
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "This round is not open for votes"}))
+		return
+	}
+	if vote.WinnerTitle != r.pair.MovieA.Title && vote.WinnerTitle != r.pair.MovieB.Title {
+		r.mu.Unlock()
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Vote for one of the two movies"}))
+		return
+	}
+
+	r.votes[member.UserID] = vote.WinnerTitle
+	r.broadcast(models.RoomMessageVoteCount, models.RoomVoteCount{
+		Round:  r.round,
+		Votes:  len(r.votes),
+		Voters: len(r.members),
+	})
+
+	// Everyone connected has voted, no need to wait for the timer
+	allVoted := true
+	for userID := range r.members {
+		if _, voted := r.votes[userID]; !voted {
+			allVoted = false
+			break
+		}
+	}
+	round := r.round
+	r.mu.Unlock()
+
+	if allVoted {
+		s.finishRound(r, round)
+	}
+}
+
+// finishRound closes voting on the round, announces the majority winner, updates the room
+// ranking and records each voter's choice in their personal rankings
+func (s *RoomService) finishRound(r *room, round int) {
+	r.mu.Lock()
+	if r.status != models.RoomVoting || r.round != round || r.pair == nil {
+		r.mu.Unlock()
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	pair := *r.pair
+	counts := map[string]int{pair.MovieA.Title: 0, pair.MovieB.Title: 0}
+	for _, title := range r.votes {
+		counts[title]++
+	}
+
+	winner := ""
+	switch {
+	case counts[pair.MovieA.Title] > counts[pair.MovieB.Title]:
+		winner = pair.MovieA.Title
+	case counts[pair.MovieB.Title] > counts[pair.MovieA.Title]:
+		winner = pair.MovieB.Title
+	}
+	if winner != "" {
+		loser := pair.MovieA.Title
+		if winner == loser {
+			loser = pair.MovieB.Title
+		}
+		winnerRecord, loserRecord := r.record(winner), r.record(loser)
+		winnerRecord.rating, loserRecord.rating = eloUpdate(winnerRecord.rating, loserRecord.rating)
+		winnerRecord.matches++
+		winnerRecord.wins++
+		loserRecord.matches++
+	}
+
+	votes := r.votes
+	r.status = models.RoomWaiting
+	r.pair = nil
+	r.votes = nil
+	r.broadcast(models.RoomMessageRoundResult, models.RoomRoundResult{
+		Round:   round,
+		Votes:   counts,
+		Winner:  winner,
+		Ranking: r.rankingList(),
+	})
+	r.mu.Unlock()
+
+	// Every vote also counts as a battle in the voter's own rankings
+	for userID, title := range votes {
+		req := &models.SubmitBattleRequest{MovieA: pair.MovieA, MovieB: pair.MovieB, Winner: pair.MovieA}
+		if title == pair.MovieB.Title {
+			req.Winner = pair.MovieB
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := s.gameService.SubmitBattleFrom(ctx, userID, req, models.BattleSourceRoom); err != nil {
+			fmt.Printf("Error recording room vote of %s in room %s: %v\n", userID.Hex(), r.code, err)
+		}
+		cancel()
+	}
+}
+
+// closeRoom ends the room for everyone. Only the host may close it.
+func (s *RoomService) closeRoom(r *room, member *RoomMember) {
+	r.mu.Lock()
+	if member.UserID != r.hostID {
+		r.mu.Unlock()
+		member.send(encodeRoomMessage(models.RoomMessageError, models.RoomError{Message: "Only the host can close the room"}))
+		return
+	}
+	r.shutdown()
+	r.mu.Unlock()
+
+	s.mu.Lock()
+	delete(s.rooms, r.code)
+	s.mu.Unlock()
+}
+
+// RemoveEmptyRooms closes rooms that have had no members for emptyRoomTTL
+func (s *RoomService) RemoveEmptyRooms() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for code, r := range s.rooms {
+		r.mu.Lock()
+		if len(r.members) == 0 && time.Since(r.emptySince) > emptyRoomTTL {
+			r.shutdown()
+			delete(s.rooms, code)
+			removed++
+		}
+		r.mu.Unlock()
+	}
+	return removed
+}
+
+// StartJanitor removes abandoned rooms every interval until ctx is cancelled
+func (s *RoomService) StartJanitor(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "room cleanup", interval, func(ctx context.Context) error {
+		if removed := s.RemoveEmptyRooms(); removed > 0 {
+			fmt.Printf("Removed %d abandoned battle rooms\n", removed)
+		}
+		return nil
+	})
+}
+
+func (s *RoomService) findRoom(code string) *room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rooms[strings.ToUpper(code)]
+}
+
+// shutdown closes the room and every connection to it. Callers hold r.mu.
+func (r *room) shutdown() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.status = models.RoomClosed
+	r.broadcast(models.RoomMessageClosed, nil)
+	for userID, member := range r.members {
+		member.close()
+		delete(r.members, userID)
+	}
+}
+
+// broadcast sends a message to every member. Callers hold r.mu.
+func (r *room) broadcast(messageType string, data interface{}) {
+	message := encodeRoomMessage(messageType, data)
+	for userID, member := range r.members {
+		if !member.send(message) {
+			// Too slow to keep up, the transport notices the closed channel and disconnects
+			member.close()
+			delete(r.members, userID)
+		}
+	}
+}
+
+func (r *room) record(title string) *roomRecord {
+	record, ok := r.ranking[title]
+	if !ok {
+		record = &roomRecord{rating: 1200}
+		r.ranking[title] = record
+	}
+	return record
+}
+
+// rankingList returns the room ranking, best first. Callers hold r.mu.
+func (r *room) rankingList() []models.RoomRanking {
+	ranking := make([]models.RoomRanking, 0, len(r.ranking))
+	for title, record := range r.ranking {
+		ranking = append(ranking, models.RoomRanking{
+			MovieTitle: title,
+			ELORating:  int(math.Round(record.rating)),
+			MatchCount: record.matches,
+			WinCount:   record.wins,
+		})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].ELORating != ranking[j].ELORating {
+			return ranking[i].ELORating > ranking[j].ELORating
+		}
+		return ranking[i].MovieTitle < ranking[j].MovieTitle
+	})
+	return ranking
+}
+
+func (r *room) participant(member *RoomMember) models.RoomParticipant {
+	return models.RoomParticipant{
+		UserID:      member.UserID,
+		DisplayName: member.DisplayName,
+		IsHost:      member.UserID == r.hostID,
+	}
+}
+
+// state describes the room. Callers hold r.mu.
+func (r *room) state() *models.RoomState {
+	participants := make([]models.RoomParticipant, 0, len(r.members))
+	for _, member := range r.members {
+		participants = append(participants, r.participant(member))
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].UserID.Hex() < participants[j].UserID.Hex()
+	})
+
+	return &models.RoomState{
+		Code:         r.code,
+		Status:       r.status,
+		Round:        r.round,
+		VoteSeconds:  r.voteSeconds,
+		Genre:        r.genre,
+		Participants: participants,
+		Ranking:      r.rankingList(),
+		CreatedAt:    r.createdAt,
+	}
+}
+
+// send queues a message without blocking, reporting false if the queue is full
+func (m *RoomMember) send(message []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false
+	}
+	select {
+	case m.Send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// close ends the member's message stream
+func (m *RoomMember) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.Send)
+	}
+}
+
+func encodeRoomMessage(messageType string, data interface{}) []byte {
+	message := models.RoomMessage{Type: messageType}
+	if data != nil {
+		message.Data, _ = json.Marshal(data)
+	}
+	encoded, _ := json.Marshal(message)
+	return encoded
+}
+
+func newRoomCode() (string, error) {
+	code := make([]byte, roomCodeLength)
+	max := big.NewInt(int64(len(roomCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = roomCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/helper"
+	"movie-vs-backend/models"
+)
+
+// Number of posters on a share's image card
+const shareCardPosters = 5
+
+var (
+	ErrShareNotFound  = errors.New("share not found")
+	ErrNothingToShare = errors.New("no battled movies to share")
+)
+
+// ShareService publishes immutable snapshots of a user's top list under unguessable links
+type ShareService struct {
+	userRepo      *data_access.UserRepository
+	battleRepo    *data_access.BattleRepository
+	movieRepo     *data_access.MovieRepository
+	shareRepo     *data_access.ShareRepository
+	omdbClient    *data_access.OMDBClient
+	publicBaseURL string
+}
+
+func NewShareService(
+	userRepo *data_access.UserRepository,
+	battleRepo *data_access.BattleRepository,
+	movieRepo *data_access.MovieRepository,
+	shareRepo *data_access.ShareRepository,
+	omdbClient *data_access.OMDBClient,
+	publicBaseURL string,
+) *ShareService {
+	return &ShareService{
+		userRepo:      userRepo,
+		battleRepo:    battleRepo,
+		movieRepo:     movieRepo,
+		shareRepo:     shareRepo,
+		omdbClient:    omdbClient,
+		publicBaseURL: strings.TrimSuffix(publicBaseURL, "/"),
+	}
+}
+
+// CreateShare snapshots the user's current top twenty and publishes it
+func (s *ShareService) CreateShare(ctx context.Context, userID primitive.ObjectID, req *models.CreateShareRequest) (*models.ShareLink, error) {
+	user, err := s.userRepo.FindProfileByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding user: %v", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	rankings, err := s.battleRepo.GetTopTwenty(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var movies []models.SharedMovie
+	for _, ranking := range rankings {
+		// Movies never battled only sit at the starting rating
+		if ranking.MatchCount == 0 {
+			continue
+		}
+		movie := models.SharedMovie{
+			Rank:       len(movies) + 1,
+			MovieTitle: ranking.MovieTitle,
+			Year:       ranking.Year,
+			ELORating:  ranking.ELORating,
+			MatchCount: ranking.MatchCount,
+			WinCount:   ranking.WinCount,
+		}
+		if movie.Rank <= shareCardPosters {
+			movie.PosterURL = s.posterURL(ctx, ranking.MovieTitle)
+		}
+		movies = append(movies, movie)
+	}
+	if len(movies) == 0 {
+		return nil, ErrNothingToShare
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, fmt.Errorf("error generating share token: %v", err)
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = "My top movies"
+	}
+
+	share := &models.Share{
+		Token:       token,
+		UserID:      userID,
+		Title:       title,
+		DisplayName: user.DisplayName,
+		Movies:      movies,
+		OpenGraph:   req.OpenGraph == nil || *req.OpenGraph,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.shareRepo.Create(ctx, share); err != nil {
+		return nil, err
+	}
+
+	return s.toShareLink(share), nil
+}
+
+// posterURL looks up a poster in the catalog first and OMDB second, empty if neither has one
+func (s *ShareService) posterURL(ctx context.Context, title string) string {
+	if movie, err := s.movieRepo.FindCatalogMovieByTitle(ctx, title); err == nil && movie != nil && movie.PosterURL != "" && movie.PosterURL != "N/A" {
+		return movie.PosterURL
+	}
+	if movie, err := s.omdbClient.FetchMovie(ctx, title); err == nil && movie.PosterURL != "N/A" {
+		return movie.PosterURL
+	}
+	return ""
+}
+
+// ListShares returns the user's published shares
+func (s *ShareService) ListShares(ctx context.Context, userID primitive.ObjectID) ([]models.ShareLink, error) {
+	shares, err := s.shareRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]models.ShareLink, 0, len(shares))
+	for i := range shares {
+		links = append(links, *s.toShareLink(&shares[i]))
+	}
+	return links, nil
+}
+
+// DeleteShare unpublishes one of the user's shares
+func (s *ShareService) DeleteShare(ctx context.Context, userID primitive.ObjectID, token string) error {
+	deleted, err := s.shareRepo.Delete(ctx, userID, token)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// GetShare returns a published snapshot, without the image card
+func (s *ShareService) GetShare(ctx context.Context, token string) (*models.Share, error) {
+	share, err := s.shareRepo.FindByToken(ctx, token, false)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, ErrShareNotFound
+	}
+	return share, nil
+}
+
+// GetCard returns the PNG image card of a share, rendering and storing it the first time
+func (s *ShareService) GetCard(ctx context.Context, token string) ([]byte, error) {
+	share, err := s.shareRepo.FindByToken(ctx, token, true)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil || !share.OpenGraph {
+		return nil, ErrShareNotFound
+	}
+	if len(share.Card) > 0 {
+		return share.Card, nil
+	}
+
+	count := len(share.Movies)
+	if count > shareCardPosters {
+		count = shareCardPosters
+	}
+	posters := make([]image.Image, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		if share.Movies[i].PosterURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			poster, err := data_access.FetchPoster(ctx, share.Movies[i].PosterURL)
+			if err != nil {
+				fmt.Printf("Error fetching poster for %s: %v\n", share.Movies[i].MovieTitle, err)
+				return
+			}
+			posters[i] = poster
+		}(i)
+	}
+	wg.Wait()
+
+	card, err := helper.RenderShareCard(posters)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering share card: %v", err)
+	}
+	if err := s.shareRepo.SetCard(ctx, share.ID, card); err != nil {
+		fmt.Printf("Error storing share card %s: %v\n", share.Token, err)
+	}
+	return card, nil
+}
+
+// ShareURL returns the public link of a share
+func (s *ShareService) ShareURL(token string) string {
+	return s.publicBaseURL + "/s/" + token
+}
+
+// CardURL returns the public link of a share's image card
+func (s *ShareService) CardURL(token string) string {
+	return s.ShareURL(token) + "/card.png"
+}
+
+func (s *ShareService) toShareLink(share *models.Share) *models.ShareLink {
+	link := &models.ShareLink{
+		Token:     share.Token,
+		URL:       s.ShareURL(share.Token),
+		Title:     share.Title,
+		CreatedAt: share.CreatedAt,
+	}
+	if share.OpenGraph {
+		link.ImageURL = s.CardURL(share.Token)
+	}
+	return link
+}
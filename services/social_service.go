@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+var (
+	ErrFollowSelf     = errors.New("cannot follow yourself")
+	ErrFollowNotFound = errors.New("follow not found")
+)
+
+// SocialService manages the follow graph and the views built on the rankings of the
+// people a user follows
+type SocialService struct {
+	userRepo   *data_access.UserRepository
+	followRepo *data_access.FollowRepository
+}
+
+func NewSocialService(userRepo *data_access.UserRepository, followRepo *data_access.FollowRepository) *SocialService {
+	return &SocialService{
+		userRepo:   userRepo,
+		followRepo: followRepo,
+	}
+}
+
+// Follow follows another user. Users with a public profile are followed straight away,
+// anyone else receives a follow request.
+func (s *SocialService) Follow(ctx context.Context, followerID, followeeID primitive.ObjectID) (*models.FollowResponse, error) {
+	if followerID == followeeID {
+		return nil, ErrFollowSelf
+	}
+
+	followee, err := s.userRepo.FindRankedUser(ctx, followeeID)
+	if err != nil {
+		return nil, err
+	}
+	if followee == nil || followee.Disabled || followee.Role == models.RoleGuest {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now()
+	follow := &models.Follow{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		Status:     models.FollowPending,
+		CreatedAt:  now,
+	}
+	if followee.Privacy.ProfileVisibility == "" || followee.Privacy.ProfileVisibility == models.VisibilityPublic {
+		follow.Status = models.FollowAccepted
+		follow.AcceptedAt = &now
+	}
+
+	stored, err := s.followRepo.Create(ctx, follow)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FollowResponse{Status: stored.Status}, nil
+}
+
+// Unfollow removes a follow or withdraws a pending request
+func (s *SocialService) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	deleted, err := s.followRepo.Delete(ctx, followerID, followeeID, models.FollowPending, models.FollowAccepted)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFollowNotFound
+	}
+	return nil
+}
+
+// AcceptRequest accepts a pending follow request sent to the user
+func (s *SocialService) AcceptRequest(ctx context.Context, userID, followerID primitive.ObjectID) error {
+	accepted, err := s.followRepo.Accept(ctx, followerID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrFollowNotFound
+	}
+	return nil
+}
+
+// DeclineRequest declines a pending follow request sent to the user
+func (s *SocialService) DeclineRequest(ctx context.Context, userID, followerID primitive.ObjectID) error {
+	deleted, err := s.followRepo.Delete(ctx, followerID, userID, models.FollowPending)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFollowNotFound
+	}
+	return nil
+}
+
+// RemoveFollower stops an accepted follower from following the user
+func (s *SocialService) RemoveFollower(ctx context.Context, userID, followerID primitive.ObjectID) error {
+	deleted, err := s.followRepo.Delete(ctx, followerID, userID, models.FollowAccepted)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFollowNotFound
+	}
+	return nil
+}
+
+// ListFollowers returns a page of the user's accepted followers
+func (s *SocialService) ListFollowers(ctx context.Context, userID primitive.ObjectID, page, pageSize int) (*models.FollowListResponse, error) {
+	follows, total, err := s.followRepo.ListFollowers(ctx, userID, models.FollowAccepted, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	return s.toFollowList(ctx, follows, total, page, pageSize, func(f models.Follow) primitive.ObjectID { return f.FollowerID })
+}
+
+// ListRequests returns a page of the follow requests waiting for the user's decision
+func (s *SocialService) ListRequests(ctx context.Context, userID primitive.ObjectID, page, pageSize int) (*models.FollowListResponse, error) {
+	follows, total, err := s.followRepo.ListFollowers(ctx, userID, models.FollowPending, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	return s.toFollowList(ctx, follows, total, page, pageSize, func(f models.Follow) primitive.ObjectID { return f.FollowerID })
+}
+
+// ListFollowing returns a page of the users the user follows or asked to follow
+func (s *SocialService) ListFollowing(ctx context.Context, userID primitive.ObjectID, status string, page, pageSize int) (*models.FollowListResponse, error) {
+	follows, total, err := s.followRepo.ListFollowing(ctx, userID, status, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	return s.toFollowList(ctx, follows, total, page, pageSize, func(f models.Follow) primitive.ObjectID { return f.FolloweeID })
+}
+
+// toFollowList adds the display name and avatar of the other user of every follow
+func (s *SocialService) toFollowList(ctx context.Context, follows []models.Follow, total int64, page, pageSize int, other func(models.Follow) primitive.ObjectID) (*models.FollowListResponse, error) {
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, other(follow))
+	}
+	profiles, err := s.userRepo.FindPublicProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.User, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.ID] = profile
+	}
+
+	users := make([]models.FollowUser, 0, len(follows))
+	for _, follow := range follows {
+		profile, ok := byID[other(follow)]
+		if !ok {
+			continue
+		}
+		since := follow.CreatedAt
+		if follow.AcceptedAt != nil {
+			since = *follow.AcceptedAt
+		}
+		users = append(users, models.FollowUser{
+			UserID:      profile.ID,
+			DisplayName: profile.DisplayName,
+			AvatarURL:   profile.AvatarURL,
+			Status:      follow.Status,
+			Since:       since,
+		})
+	}
+
+	return &models.FollowListResponse{
+		Users:    users,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetTopList returns another user's highest rated battled movies if the viewer may see them
+func (s *SocialService) GetTopList(ctx context.Context, viewerID, ownerID primitive.ObjectID, limit int) (*models.UserTopListResponse, error) {
+	owner, err := s.userRepo.FindRankedUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil || owner.Disabled || owner.Role == models.RoleGuest {
+		return nil, ErrUserNotFound
+	}
+
+	visible, err := canViewRankings(ctx, s.followRepo, owner, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrRankingsPrivate
+	}
+
+	movies := owner.MovieRankings
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].ELORating != movies[j].ELORating {
+			return movies[i].ELORating > movies[j].ELORating
+		}
+		return movies[i].MovieTitle < movies[j].MovieTitle
+	})
+	if len(movies) > limit {
+		movies = movies[:limit]
+	}
+	if movies == nil {
+		movies = []models.MovieRanking{}
+	}
+
+	return &models.UserTopListResponse{
+		UserID:      owner.ID,
+		DisplayName: owner.DisplayName,
+		Movies:      movies,
+	}, nil
+}
+
+// FriendsConsensus combines the rankings of everyone the user follows into one list.
+// Each friend's ratings are normalised first so that no single friend's rating scale
+// dominates, then movies are ordered by their average normalised preference.
+func (s *SocialService) FriendsConsensus(ctx context.Context, userID primitive.ObjectID, minFriends, limit int) (*models.FriendsConsensusResponse, error) {
+	followingIDs, err := s.followRepo.FollowingIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	friends, err := s.userRepo.FindRankedUsers(ctx, followingIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	type consensusRecord struct {
+		preference float64
+		elo        float64
+		rankedBy   int
+	}
+	records := make(map[string]*consensusRecord)
+	included := 0
+	for i := range friends {
+		friend := &friends[i]
+		// Every friend here is followed with an accepted follow, so only private rankings are hidden
+		if friend.Disabled || friend.Privacy.RankingsVisibility == models.VisibilityPrivate {
+			continue
+		}
+
+		prefs := preferencesFromRankings(friend.MovieRankings)
+		if len(prefs) == 0 {
+			continue
+		}
+		included++
+		for _, ranking := range friend.MovieRankings {
+			record, ok := records[ranking.MovieTitle]
+			if !ok {
+				record = &consensusRecord{}
+				records[ranking.MovieTitle] = record
+			}
+			record.preference += prefs[ranking.MovieTitle]
+			record.elo += float64(ranking.ELORating)
+			record.rankedBy++
+		}
+	}
+
+	movies := []models.ConsensusMovie{}
+	for title, record := range records {
+		if record.rankedBy < minFriends {
+			continue
+		}
+		movies = append(movies, models.ConsensusMovie{
+			MovieTitle: title,
+			Score:      math.Round(record.preference/float64(record.rankedBy)*1000) / 1000,
+			AverageELO: math.Round(record.elo/float64(record.rankedBy)*10) / 10,
+			RankedBy:   record.rankedBy,
+		})
+	}
+
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].Score != movies[j].Score {
+			return movies[i].Score > movies[j].Score
+		}
+		if movies[i].RankedBy != movies[j].RankedBy {
+			return movies[i].RankedBy > movies[j].RankedBy
+		}
+		return movies[i].MovieTitle < movies[j].MovieTitle
+	})
+	if len(movies) > limit {
+		movies = movies[:limit]
+	}
+	for i := range movies {
+		movies[i].Rank = i + 1
+	}
+
+	return &models.FriendsConsensusResponse{
+		Movies:  movies,
+		Friends: included,
+	}, nil
+}
+
+// canViewRankings reports whether the viewer may see the owner's rankings. "friends"
+// rankings are visible to the owner's accepted followers.
+func canViewRankings(ctx context.Context, followRepo *data_access.FollowRepository, owner *models.User, viewerID primitive.ObjectID) (bool, error) {
+	if owner.ID == viewerID {
+		return true, nil
+	}
+
+	switch owner.Privacy.RankingsVisibility {
+	case "", models.VisibilityPublic:
+		return true, nil
+	case models.VisibilityFriends:
+		following, err := followRepo.IsAcceptedFollower(ctx, viewerID, owner.ID)
+		if err != nil {
+			return false, fmt.Errorf("error checking follow: %v", err)
+		}
+		return following, nil
+	default:
+		return false, nil
+	}
+}
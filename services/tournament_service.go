@@ -0,0 +1,506 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"movie-vs-backend/data_access"
+	"movie-vs-backend/models"
+)
+
+// ID of the grand final, and of the rematch played when the losers bracket champion wins it
+const (
+	grandFinalID      = "F1"
+	grandFinalResetID = "F2"
+)
+
+var (
+	ErrTournamentNotFound        = errors.New("tournament not found")
+	ErrInvalidTournament         = errors.New("invalid tournament settings")
+	ErrNotEnoughTournamentMovies = errors.New("not enough movies for the tournament")
+	ErrTournamentFinished        = errors.New("tournament is finished")
+	ErrInvalidTournamentResult   = errors.New("match is not playable or winner is not in it")
+	ErrTournamentConflict        = errors.New("tournament was updated concurrently")
+)
+
+// TournamentService runs seeded single and double elimination brackets over a user's
+// movies. Every match played is also submitted as a regular battle.
+type TournamentService struct {
+	tournamentRepo *data_access.TournamentRepository
+	battleRepo     *data_access.BattleRepository
+	gameService    *GameService
+}
+
+func NewTournamentService(tournamentRepo *data_access.TournamentRepository, battleRepo *data_access.BattleRepository, gameService *GameService) *TournamentService {
+	return &TournamentService{
+		tournamentRepo: tournamentRepo,
+		battleRepo:     battleRepo,
+		gameService:    gameService,
+	}
+}
+
+// CreateTournament seeds a bracket with the user's highest rated movies from the source
+func (s *TournamentService) CreateTournament(ctx context.Context, userID primitive.ObjectID, req *models.CreateTournamentRequest) (*models.TournamentResponse, error) {
+	format := req.Format
+	if format == "" {
+		format = models.TournamentSingleElimination
+	}
+	source := req.Source
+	if source == "" {
+		source = models.TournamentSourceTop
+	}
+
+	query := models.RankingQuery{Sort: models.RankingSortELO, Limit: req.Size}
+	var name string
+	var decade int
+	switch source {
+	case models.TournamentSourceTop:
+		name = fmt.Sprintf("Top %d", req.Size)
+	case models.TournamentSourceGenre:
+		genre := strings.TrimSpace(req.Genre)
+		if genre == "" {
+			return nil, ErrInvalidTournament
+		}
+		query.Genre = genre
+		name = fmt.Sprintf("Best of %s", genre)
+	case models.TournamentSourceDecade:
+		if req.Decade <= 0 || req.Decade%10 != 0 {
+			return nil, ErrInvalidTournament
+		}
+		decade = req.Decade
+		query.YearFrom = decade
+		query.YearTo = decade + 9
+		name = fmt.Sprintf("Best of the %ds", decade)
+	default:
+		return nil, ErrInvalidTournament
+	}
+	if title := strings.TrimSpace(req.Name); title != "" {
+		name = title
+	}
+
+	rankings, _, err := s.battleRepo.QueryRankings(ctx, userID, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(rankings) < req.Size {
+		return nil, ErrNotEnoughTournamentMovies
+	}
+
+	// Rankings come highest rated first, which is seed order
+	entrants := make([]models.TournamentEntrant, 0, req.Size)
+	for i, ranked := range rankings {
+		entrants = append(entrants, models.TournamentEntrant{
+			Seed:      i + 1,
+			Movie:     entrantMovie(ranked),
+			ELORating: ranked.Ranking.ELORating,
+		})
+	}
+
+	now := time.Now()
+	tournament := &models.Tournament{
+		UserID:    userID,
+		Name:      name,
+		Format:    format,
+		Source:    source,
+		Genre:     query.Genre,
+		Decade:    decade,
+		Entrants:  entrants,
+		Matches:   buildBracket(format, req.Size),
+		Status:    models.TournamentActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.tournamentRepo.Create(ctx, tournament); err != nil {
+		return nil, err
+	}
+
+	return toTournamentResponse(tournament), nil
+}
+
+// entrantMovie uses the catalog details of a ranked movie when there are any. The ID is
+// always the ranking's so that battles update the user's rating of it.
+func entrantMovie(ranked models.RankedMovie) models.Movie {
+	var movie models.Movie
+	if ranked.Movie != nil {
+		movie = *ranked.Movie
+	} else {
+		movie.Title = ranked.Ranking.MovieTitle
+		movie.Genre = strings.Join(ranked.Ranking.Genres, ", ")
+		if ranked.Ranking.Year > 0 {
+			movie.Year = strconv.Itoa(ranked.Ranking.Year)
+		}
+	}
+	movie.ID = ranked.Ranking.MovieID
+	return movie
+}
+
+// GetTournament returns one of the user's tournaments with its bracket tree
+func (s *TournamentService) GetTournament(ctx context.Context, userID, tournamentID primitive.ObjectID) (*models.TournamentResponse, error) {
+	tournament, err := s.findTournament(ctx, userID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	return toTournamentResponse(tournament), nil
+}
+
+// ListTournaments returns a page of the user's tournaments
+func (s *TournamentService) ListTournaments(ctx context.Context, userID primitive.ObjectID, page, pageSize int) (*models.TournamentListResponse, error) {
+	tournaments, total, err := s.tournamentRepo.ListByUser(ctx, userID, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.TournamentSummary, 0, len(tournaments))
+	for i := range tournaments {
+		tournament := &tournaments[i]
+		summary := models.TournamentSummary{
+			ID:        tournament.ID,
+			Name:      tournament.Name,
+			Format:    tournament.Format,
+			Size:      len(tournament.Entrants),
+			Status:    tournament.Status,
+			CreatedAt: tournament.CreatedAt,
+			UpdatedAt: tournament.UpdatedAt,
+		}
+		if champion := entrantBySeed(tournament, tournament.Champion); champion != nil {
+			summary.Champion = champion.Movie.Title
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return &models.TournamentListResponse{
+		Tournaments: summaries,
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+// DeleteTournament removes one of the user's tournaments. Battles already played keep
+// counting towards the user's rankings.
+func (s *TournamentService) DeleteTournament(ctx context.Context, userID, tournamentID primitive.ObjectID) error {
+	deleted, err := s.tournamentRepo.Delete(ctx, userID, tournamentID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTournamentNotFound
+	}
+	return nil
+}
+
+// NextBattle returns the next match of the tournament to play
+func (s *TournamentService) NextBattle(ctx context.Context, userID, tournamentID primitive.ObjectID) (*models.TournamentBattleResponse, error) {
+	tournament, err := s.findTournament(ctx, userID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	match := nextMatch(tournament)
+	if tournament.Status == models.TournamentCompleted || match == nil {
+		return nil, ErrTournamentFinished
+	}
+
+	a, b := entrantBySeed(tournament, match.A), entrantBySeed(tournament, match.B)
+	return &models.TournamentBattleResponse{
+		TournamentID: tournament.ID,
+		MatchID:      match.ID,
+		Bracket:      match.Bracket,
+		Round:        match.Round,
+		MovieA:       a.Movie,
+		MovieB:       b.Movie,
+		SeedA:        a.Seed,
+		SeedB:        b.Seed,
+	}, nil
+}
+
+// SubmitBattle records the winner of a match, moves both movies on through the bracket
+// and submits the match as a regular battle so it feeds the user's ELO
+func (s *TournamentService) SubmitBattle(ctx context.Context, userID, tournamentID primitive.ObjectID, req *models.SubmitTournamentBattleRequest) (*models.TournamentResponse, error) {
+	tournament, err := s.findTournament(ctx, userID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status == models.TournamentCompleted {
+		return nil, ErrTournamentFinished
+	}
+
+	match := findMatch(tournament, req.MatchID)
+	if match == nil || match.A == 0 || match.B == 0 || match.Winner != 0 {
+		return nil, ErrInvalidTournamentResult
+	}
+	a, b := entrantBySeed(tournament, match.A), entrantBySeed(tournament, match.B)
+	var winner, loser *models.TournamentEntrant
+	switch req.WinnerTitle {
+	case a.Movie.Title:
+		winner, loser = a, b
+	case b.Movie.Title:
+		winner, loser = b, a
+	default:
+		return nil, ErrInvalidTournamentResult
+	}
+
+	// Claim the result before touching ratings so a repeated submission cannot count twice
+	recordMatchResult(tournament, match.ID, winner.Seed, loser.Seed, time.Now())
+	saved, err := s.tournamentRepo.Update(ctx, tournament)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrTournamentConflict
+	}
+
+	battle := &models.SubmitBattleRequest{MovieA: a.Movie, MovieB: b.Movie, Winner: winner.Movie}
+	if _, err := s.gameService.SubmitBattleFrom(ctx, userID, battle, models.BattleSourceTournament); err != nil {
+		return nil, fmt.Errorf("error recording tournament battle: %v", err)
+	}
+
+	return toTournamentResponse(tournament), nil
+}
+
+func (s *TournamentService) findTournament(ctx context.Context, userID, tournamentID primitive.ObjectID) (*models.Tournament, error) {
+	tournament, err := s.tournamentRepo.FindByID(ctx, userID, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding tournament: %v", err)
+	}
+	if tournament == nil {
+		return nil, ErrTournamentNotFound
+	}
+	return tournament, nil
+}
+
+// seedOrder returns the seeds in bracket order for a power of two field, so that the top
+// seeds can only meet in the latest rounds: 1, 8, 4, 5, 2, 7, 3, 6 for eight
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// buildBracket lays out every match of a tournament of the given power of two size.
+// Matches are returned in an order they can be played in.
+func buildBracket(format string, size int) []models.TournamentMatch {
+	rounds := 0
+	for n := size; n > 1; n /= 2 {
+		rounds++
+	}
+
+	newRound := func(bracket, prefix string, round, count int) []*models.TournamentMatch {
+		matches := make([]*models.TournamentMatch, count)
+		for i := range matches {
+			matches[i] = &models.TournamentMatch{
+				ID:      fmt.Sprintf("%s%d-%d", prefix, round, i+1),
+				Bracket: bracket,
+				Round:   round,
+			}
+		}
+		return matches
+	}
+	link := func(from *models.TournamentMatch, to *models.TournamentMatch, slot int, loser bool) {
+		target := &models.TournamentSlot{MatchID: to.ID, Slot: slot}
+		if loser {
+			from.LoserTo = target
+		} else {
+			from.WinnerTo = target
+		}
+	}
+
+	winners := make([][]*models.TournamentMatch, rounds+1)
+	for round := 1; round <= rounds; round++ {
+		winners[round] = newRound(models.BracketWinners, "W", round, size>>round)
+		if round > 1 {
+			for i, match := range winners[round-1] {
+				link(match, winners[round][i/2], i%2, false)
+			}
+		}
+	}
+	seeds := seedOrder(size)
+	for i, match := range winners[1] {
+		match.A, match.B = seeds[2*i], seeds[2*i+1]
+	}
+
+	if format != models.TournamentDoubleElimination {
+		var ordered []models.TournamentMatch
+		for round := 1; round <= rounds; round++ {
+			for _, match := range winners[round] {
+				ordered = append(ordered, *match)
+			}
+		}
+		return ordered
+	}
+
+	// The losers bracket alternates between rounds where its survivors meet the losers of
+	// the next winners round and rounds where its survivors play each other
+	losers := make([][]*models.TournamentMatch, 2*(rounds-1)+1)
+	losers[1] = newRound(models.BracketLosers, "L", 1, size/4)
+	for i, match := range winners[1] {
+		link(match, losers[1][i/2], i%2, true)
+	}
+	for j := 1; 2*j <= 2*(rounds-1); j++ {
+		dropIn := newRound(models.BracketLosers, "L", 2*j, size>>(j+1))
+		losers[2*j] = dropIn
+		for i, match := range losers[2*j-1] {
+			link(match, dropIn[i], 0, false)
+		}
+		// Losers drop in reversed to put off rematches
+		for i, match := range winners[j+1] {
+			link(match, dropIn[len(dropIn)-1-i], 1, true)
+		}
+
+		if 2*j+1 <= 2*(rounds-1) {
+			losers[2*j+1] = newRound(models.BracketLosers, "L", 2*j+1, size>>(j+2))
+			for i, match := range dropIn {
+				link(match, losers[2*j+1][i/2], i%2, false)
+			}
+		}
+	}
+
+	final := &models.TournamentMatch{ID: grandFinalID, Bracket: models.BracketFinal, Round: 1}
+	link(winners[rounds][0], final, 0, false)
+	link(losers[len(losers)-1][0], final, 1, false)
+
+	var ordered []models.TournamentMatch
+	appendRound := func(matches []*models.TournamentMatch) {
+		for _, match := range matches {
+			ordered = append(ordered, *match)
+		}
+	}
+	appendRound(winners[1])
+	appendRound(losers[1])
+	for round := 2; round <= rounds; round++ {
+		appendRound(winners[round])
+		appendRound(losers[2*(round-1)])
+		if 2*(round-1)+1 < len(losers) {
+			appendRound(losers[2*(round-1)+1])
+		}
+	}
+	ordered = append(ordered, *final)
+	return ordered
+}
+
+// recordMatchResult stores the winner of a match and moves both entrants on. The match
+// without a next match decides the champion, unless it is a grand final won by the losers
+// bracket champion: both then have one loss and a deciding rematch is added.
+func recordMatchResult(tournament *models.Tournament, matchID string, winner, loser int, now time.Time) {
+	match := findMatch(tournament, matchID)
+	match.Winner = winner
+	match.PlayedAt = &now
+	tournament.UpdatedAt = now
+
+	if match.WinnerTo != nil {
+		placeEntrant(tournament, *match.WinnerTo, winner)
+	}
+	if match.LoserTo != nil {
+		placeEntrant(tournament, *match.LoserTo, loser)
+	}
+	if match.WinnerTo != nil {
+		return
+	}
+
+	if match.ID == grandFinalID && winner == match.B {
+		tournament.Matches = append(tournament.Matches, models.TournamentMatch{
+			ID:      grandFinalResetID,
+			Bracket: models.BracketFinal,
+			Round:   2,
+			A:       match.A,
+			B:       match.B,
+		})
+		return
+	}
+
+	tournament.Champion = winner
+	tournament.Status = models.TournamentCompleted
+	tournament.CompletedAt = &now
+}
+
+func placeEntrant(tournament *models.Tournament, slot models.TournamentSlot, seed int) {
+	match := findMatch(tournament, slot.MatchID)
+	if slot.Slot == 0 {
+		match.A = seed
+	} else {
+		match.B = seed
+	}
+}
+
+func findMatch(tournament *models.Tournament, matchID string) *models.TournamentMatch {
+	for i := range tournament.Matches {
+		if tournament.Matches[i].ID == matchID {
+			return &tournament.Matches[i]
+		}
+	}
+	return nil
+}
+
+// nextMatch returns the first match whose entrants are known that has not been played
+func nextMatch(tournament *models.Tournament) *models.TournamentMatch {
+	for i := range tournament.Matches {
+		match := &tournament.Matches[i]
+		if match.A != 0 && match.B != 0 && match.Winner == 0 {
+			return match
+		}
+	}
+	return nil
+}
+
+func entrantBySeed(tournament *models.Tournament, seed int) *models.TournamentEntrant {
+	if seed < 1 || seed > len(tournament.Entrants) {
+		return nil
+	}
+	return &tournament.Entrants[seed-1]
+}
+
+// toTournamentResponse groups a tournament's matches into its bracket tree
+func toTournamentResponse(tournament *models.Tournament) *models.TournamentResponse {
+	response := &models.TournamentResponse{
+		Tournament: *tournament,
+		Brackets:   []models.TournamentBracket{},
+		Champion:   entrantBySeed(tournament, tournament.Champion),
+	}
+
+	rounds := make(map[string]map[int][]models.TournamentMatchView)
+	for _, match := range tournament.Matches {
+		if match.Winner != 0 {
+			response.Played++
+		} else {
+			response.Remaining++
+		}
+		if rounds[match.Bracket] == nil {
+			rounds[match.Bracket] = make(map[int][]models.TournamentMatchView)
+		}
+		rounds[match.Bracket][match.Round] = append(rounds[match.Bracket][match.Round], models.TournamentMatchView{
+			ID:       match.ID,
+			A:        entrantBySeed(tournament, match.A),
+			B:        entrantBySeed(tournament, match.B),
+			Winner:   match.Winner,
+			PlayedAt: match.PlayedAt,
+		})
+	}
+
+	for _, name := range []string{models.BracketWinners, models.BracketLosers, models.BracketFinal} {
+		byRound, ok := rounds[name]
+		if !ok {
+			continue
+		}
+		bracket := models.TournamentBracket{Name: name}
+		for round, matches := range byRound {
+			bracket.Rounds = append(bracket.Rounds, models.TournamentRound{Round: round, Matches: matches})
+		}
+		sort.Slice(bracket.Rounds, func(i, j int) bool { return bracket.Rounds[i].Round < bracket.Rounds[j].Round })
+		response.Brackets = append(response.Brackets, bracket)
+	}
+
+	return response
+}